@@ -1,6 +1,9 @@
 package api
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 func (c *Client) GetAppReleases(ctx context.Context, appName string, limit int) ([]Release, error) {
 	query := `
@@ -66,3 +69,58 @@ func (c *Client) GetAppRelease(ctx context.Context, appName string, id string) (
 
 	return data.App.Release, nil
 }
+
+// GetAppReleaseByVersion returns the given version of the named app's
+// releases, along with the config it was released with.
+func (c *Client) GetAppReleaseByVersion(ctx context.Context, appName string, version int) (*Release, error) {
+	query := `
+		query ($appName: String!, $version: Int!) {
+			app(name: $appName) {
+				release(version: $version) {
+					id
+					version
+					description
+					reason
+					status
+					imageRef
+					stable
+					createdAt
+					config {
+						definition
+					}
+				}
+			}
+		}
+	`
+
+	req := c.NewRequest(query)
+
+	req.Var("appName", appName)
+	req.Var("version", version)
+
+	data, err := c.RunWithContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.App.Release == nil {
+		return nil, fmt.Errorf("release v%d of %s not found", version, appName)
+	}
+
+	return data.App.Release, nil
+}
+
+// GetAppReleaseConfig returns the config the given version of the named app
+// was released with.
+func (c *Client) GetAppReleaseConfig(ctx context.Context, appName string, version int) (*AppConfig, error) {
+	release, err := c.GetAppReleaseByVersion(ctx, appName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if release.Config == nil {
+		return nil, fmt.Errorf("release v%d of %s not found", version, appName)
+	}
+
+	return release.Config, nil
+}
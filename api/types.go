@@ -625,6 +625,7 @@ type Release struct {
 	EvaluationID       string
 	CreatedAt          time.Time
 	ImageRef           string
+	Config             *AppConfig
 }
 
 type Build struct {
@@ -223,7 +223,7 @@ func BuildCommand(parent *Command, fn RunFn, usageText string, shortHelpText str
 
 }
 
-const defaultConfigFilePath = "./fly.toml"
+const defaultConfigFilePath = "."
 
 func requireSession(cmd *Command) Initializer {
 	return Initializer{
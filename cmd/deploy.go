@@ -16,6 +16,7 @@ import (
 	"github.com/superfly/flyctl/api"
 	"github.com/superfly/flyctl/cmd/presenters"
 	"github.com/superfly/flyctl/cmdctx"
+	"github.com/superfly/flyctl/flyctl"
 	"github.com/superfly/flyctl/internal/build/imgsrc"
 	"github.com/superfly/flyctl/internal/cmdfmt"
 	"github.com/superfly/flyctl/internal/cmdutil"
@@ -40,7 +41,10 @@ func runDeploy(cmdCtx *cmdctx.CmdContext) error {
 			return fmt.Errorf("unable to fetch existing configuration file: %s", err)
 		}
 
-		cmdCtx.AppConfig.Definition = cfg.Definition
+		cmdCtx.AppConfig = flyctl.NewAppConfig()
+		if err := cmdCtx.AppConfig.SetDefinition(cfg.Definition); err != nil {
+			return fmt.Errorf("unable to decode existing configuration: %w", err)
+		}
 	}
 
 	if extraEnv := cmdCtx.Config.GetStringSlice("env"); len(extraEnv) > 0 {
@@ -51,7 +55,7 @@ func runDeploy(cmdCtx *cmdctx.CmdContext) error {
 		cmdCtx.AppConfig.SetEnvVariables(parsedEnv)
 	}
 
-	parsedCfg, err := cmdCtx.Client.API().ParseConfig(ctx, cmdCtx.AppName, cmdCtx.AppConfig.Definition)
+	parsedCfg, err := cmdCtx.Client.API().ParseConfig(ctx, cmdCtx.AppName, cmdCtx.AppConfig.Definition.Map())
 	if err != nil {
 		if parsedCfg == nil {
 			// No error data has been returned
@@ -63,7 +67,9 @@ func runDeploy(cmdCtx *cmdctx.CmdContext) error {
 		}
 		return err
 	}
-	cmdCtx.AppConfig.Definition = parsedCfg.Definition
+	if err := cmdCtx.AppConfig.SetDefinition(parsedCfg.Definition); err != nil {
+		return err
+	}
 	cmdfmt.PrintDone(cmdCtx.Out, "Validating app configuration done")
 
 	if parsedCfg.Valid && len(parsedCfg.Services) > 0 {
@@ -174,8 +180,8 @@ func runDeploy(cmdCtx *cmdctx.CmdContext) error {
 	if val := cmdCtx.Config.GetString("strategy"); val != "" {
 		input.Strategy = api.StringPointer(strings.ToUpper(val))
 	}
-	if cmdCtx.AppConfig != nil && len(cmdCtx.AppConfig.Definition) > 0 {
-		input.Definition = api.DefinitionPtr(cmdCtx.AppConfig.Definition)
+	if cmdCtx.AppConfig != nil && cmdCtx.AppConfig.HasDefinition() {
+		input.Definition = api.DefinitionPtr(cmdCtx.AppConfig.Definition.Map())
 	}
 
 	release, releaseCommand, err := cmdCtx.Client.API().DeployImage(ctx, input)
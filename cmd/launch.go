@@ -80,6 +80,45 @@ func newLaunchCommand(client *client.Client) *Command {
 		Description: "Perform builds remotely without using the local docker daemon",
 		Default:     true,
 	})
+	launchCmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "dry-run",
+		Description: "Show what launch would do without creating the app or changing any files",
+		Default:     false,
+	})
+	launchCmd.AddStringFlag(StringFlagOpts{
+		Name:        "answers",
+		Description: "Path to a YAML file which answers the prompts of launch, so that it runs unattended",
+	})
+	launchCmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "overwrite",
+		Description: "Overwrite existing files with the ones launch generates without prompting. Use --overwrite=false to keep them",
+		Default:     false,
+	})
+	launchCmd.AddStringSliceFlag(StringSliceFlagOpts{
+		Name:        "secret",
+		Description: "Set the value of a secret the app needs without prompting, as in KEY=VALUE",
+	})
+	launchCmd.AddBoolFlag(BoolFlagOpts{
+		Name:        "postgres",
+		Description: "Set up a Postgres database without prompting. Use --postgres=false to skip it",
+		Default:     false,
+	})
+	launchCmd.AddStringFlag(StringFlagOpts{
+		Name:        "postgres-vm-size",
+		Description: "The VM size of the Postgres database launch sets up",
+	})
+	launchCmd.AddIntFlag(IntFlagOpts{
+		Name:        "postgres-volume-size",
+		Description: "The volume size, in GB, of the Postgres database launch sets up",
+	})
+	launchCmd.AddIntFlag(IntFlagOpts{
+		Name:        "postgres-cluster-size",
+		Description: "The initial cluster size of the Postgres database launch sets up",
+	})
+	launchCmd.AddStringSliceFlag(StringSliceFlagOpts{
+		Name:        "scanners",
+		Description: "Scanner rules files, or directories of them, to detect frameworks with in addition to the ones in the scanners directory of the flyctl config directory",
+	})
 
 	return launchCmd
 }
@@ -94,19 +133,32 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 	}
 	cmdCtx.WorkingDir = dir
 
-	orgSlug := cmdCtx.Config.GetString("org")
+	answers, err := loadLaunchAnswers(cmdCtx)
+	if err != nil {
+		return err
+	}
+
+	orgSlug := answers.Org
+	dryRun := cmdCtx.Config.GetBool("dry-run")
 
 	// start a remote builder for the personal org if necessary
 	eagerBuilderOrg := orgSlug
 	if orgSlug == "" {
 		eagerBuilderOrg = "personal"
 	}
-	go imgsrc.EagerlyEnsureRemoteBuilder(ctx, cmdCtx.Client.API(), eagerBuilderOrg)
+	if !dryRun {
+		go imgsrc.EagerlyEnsureRemoteBuilder(ctx, cmdCtx.Client.API(), eagerBuilderOrg)
+	}
 
 	appConfig := flyctl.NewAppConfig()
 
 	var importedConfig bool
-	configFilePath := filepath.Join(dir, "fly.toml")
+	configFilePath, err := flyctl.ResolveConfigFileFromPath(dir)
+	if err != nil {
+		return err
+	}
+	configFileName := filepath.Base(configFilePath)
+
 	if exists, _ := flyctl.ConfigFileExistsAtPath(configFilePath); exists {
 		cfg, err := flyctl.LoadAppConfig(configFilePath)
 		if err != nil {
@@ -116,21 +168,24 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 		var deployExisting bool
 
 		if cfg.AppName != "" {
-			fmt.Println("An existing fly.toml file was found for app", cfg.AppName)
+			fmt.Printf("An existing %s file was found for app %s\n", configFileName, cfg.AppName)
 			deployExisting, err = shouldDeployExistingApp(cmdCtx, cfg.AppName)
 			if err != nil {
 				return err
 			}
 		} else {
-			fmt.Println("An existing fly.toml file was found")
+			fmt.Printf("An existing %s file was found\n", configFileName)
 		}
 
-		if deployExisting {
+		if deployExisting && dryRun {
+			fmt.Printf("App %s is not running; launch would deploy it\n", cfg.AppName)
+			return nil
+		} else if deployExisting {
 			fmt.Println("App is not running, deploy...")
 			cmdCtx.AppName = cfg.AppName
 			cmdCtx.AppConfig = cfg
 			return runDeploy(cmdCtx)
-		} else if cmdCtx.Config.GetBool("copy-config") || confirm("Would you like to copy its configuration to the new app?") {
+		} else if answers.confirm(answers.CopyConfig, "copy_config", "--copy-config", "Would you like to copy its configuration to the new app?") {
 			appConfig.Definition = cfg.Definition
 			importedConfig = true
 		}
@@ -153,7 +208,17 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 	} else {
 		fmt.Println("Scanning source code")
 
-		if si, err := sourcecode.Scan(dir); err != nil {
+		ruleSources := cmdCtx.Config.GetStringSlice("scanners")
+		if rulesDir := filepath.Join(flyctl.ConfigDir(), "scanners"); helpers.DirectoryExists(rulesDir) {
+			ruleSources = append([]string{rulesDir}, ruleSources...)
+		}
+
+		rules, err := sourcecode.LoadRules(ruleSources...)
+		if err != nil {
+			return err
+		}
+
+		if si, err := sourcecode.Scan(dir, rules...); err != nil {
 			return err
 		} else {
 			srcInfo = si
@@ -193,11 +258,27 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 		}
 	}
 
+	offersDeploy := !cmdCtx.Config.GetBool("no-deploy") && !cmdCtx.Config.GetBool("now")
+	orgs := func() ([]api.Organization, error) {
+		return cmdCtx.Client.API().GetOrganizations(ctx, nil)
+	}
+
+	if err := answers.check(dir, srcInfo, offersDeploy, orgs); err != nil {
+		return err
+	}
+
+	if dryRun {
+		noDeploy, now := cmdCtx.Config.GetBool("no-deploy"), cmdCtx.Config.GetBool("now")
+		plan := newLaunchPlan(answers, dir, configFilePath, appConfig, srcInfo, noDeploy, now, helpers.FileExists)
+
+		return plan.print(os.Stdout)
+	}
+
 	if srcInfo != nil {
 		for _, f := range srcInfo.Files {
 			path := filepath.Join(dir, f.Path)
 
-			if helpers.FileExists(path) && !confirmOverwrite(path) {
+			if helpers.FileExists(path) && !answers.overwrite(path) {
 				continue
 			}
 
@@ -219,8 +300,8 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 
 	appName := ""
 
-	if !cmdCtx.Config.GetBool("generate-name") {
-		appName = cmdCtx.Config.GetString("name")
+	if !answers.GenerateName {
+		appName = answers.Name
 
 		if appName == "" {
 			// Prompt the user for the app name
@@ -246,8 +327,7 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 		go imgsrc.EagerlyEnsureRemoteBuilder(ctx, cmdCtx.Client.API(), org.Slug)
 	}
 
-	regionCode := cmdCtx.Config.GetString("region")
-	region, err := selectRegion(ctx, cmdCtx.Client.API(), regionCode)
+	region, err := selectRegion(ctx, cmdCtx.Client.API(), answers.Region)
 	if err != nil {
 		return err
 	}
@@ -264,7 +344,9 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 		return err
 	}
 	if !importedConfig {
-		appConfig.Definition = app.Config.Definition
+		if err := appConfig.SetDefinition(app.Config.Definition); err != nil {
+			return err
+		}
 	}
 
 	cmdCtx.AppName = app.Name
@@ -272,45 +354,7 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 	cmdCtx.AppConfig = appConfig
 
 	if srcInfo != nil {
-		if srcInfo.Port > 0 {
-			appConfig.SetInternalPort(srcInfo.Port)
-		}
-
-		for envName, envVal := range srcInfo.Env {
-			if envVal == "APP_FQDN" {
-				appConfig.SetEnvVariable(envName, app.Name+".fly.dev")
-			} else {
-				appConfig.SetEnvVariable(envName, envVal)
-			}
-		}
-
-		if len(srcInfo.Statics) > 0 {
-			appConfig.SetStatics(srcInfo.Statics)
-		}
-
-		if len(srcInfo.Volumes) > 0 {
-			appConfig.SetVolumes(srcInfo.Volumes)
-		}
-
-		for procName, procCommand := range srcInfo.Processes {
-			appConfig.SetProcess(procName, procCommand)
-		}
-
-		if srcInfo.ReleaseCmd != "" {
-			appConfig.SetReleaseCommand(srcInfo.ReleaseCmd)
-		}
-
-		if srcInfo.DockerCommand != "" {
-			appConfig.SetDockerCommand(srcInfo.DockerCommand)
-		}
-
-		if srcInfo.DockerEntrypoint != "" {
-			appConfig.SetDockerEntrypoint(srcInfo.DockerEntrypoint)
-		}
-
-		if srcInfo.KillSignal != "" {
-			appConfig.SetKillSignal(srcInfo.KillSignal)
-		}
+		applySourceInfo(appConfig, srcInfo, app.Name)
 	}
 
 	fmt.Printf("Created app %s in organization %s\n", app.Name, org.Slug)
@@ -326,7 +370,9 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 
 			// If a secret should be a random default, just generate it without displaying
 			// Otherwise, prompt to type it in
-			if secret.Generate {
+			if answer, ok := answers.Secrets[secret.Key]; ok {
+				val = answer
+			} else if secret.Generate {
 				if val, err = helpers.RandString(64); err != nil {
 					return fmt.Errorf("could not generate random string: %w", err)
 				}
@@ -404,13 +450,8 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 		}
 	}
 
-	if srcInfo != nil && len(srcInfo.BuildArgs) > 0 {
-		appConfig.Build = &flyctl.Build{}
-		appConfig.Build.Args = srcInfo.BuildArgs
-	}
-
 	// Finally, write the config
-	if err := writeAppConfig(filepath.Join(dir, "fly.toml"), appConfig); err != nil {
+	if err := writeAppConfig(configFilePath, appConfig); err != nil {
 		return err
 	}
 
@@ -418,7 +459,7 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 		return nil
 	}
 
-	if !cmdCtx.Config.GetBool("no-deploy") && !cmdCtx.Config.GetBool("now") && !srcInfo.SkipDatabase && confirm("Would you like to setup a Postgresql database now?") {
+	if !cmdCtx.Config.GetBool("no-deploy") && !cmdCtx.Config.GetBool("now") && !srcInfo.SkipDatabase && answers.confirm(answers.Postgres, "postgres", "--postgres", "Would you like to setup a Postgresql database now?") {
 
 		app, err := cmdCtx.Client.API().GetApp(ctx, cmdCtx.AppName)
 
@@ -432,6 +473,16 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 		cmdCtx.Config.Set("region", region.Code)
 		cmdCtx.Config.Set("organization", org.Slug)
 
+		if answers.PostgresVMSize != "" {
+			cmdCtx.Config.Set("vm-size", answers.PostgresVMSize)
+		}
+		if answers.PostgresVolumeSize != 0 {
+			cmdCtx.Config.Set("volume-size", answers.PostgresVolumeSize)
+		}
+		if answers.PostgresClusterSize != 0 {
+			cmdCtx.Config.Set("initial-cluster-size", answers.PostgresClusterSize)
+		}
+
 		err = runCreatePostgresCluster(cmdCtx)
 
 		if err != nil {
@@ -476,7 +527,7 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 
 	if !cmdCtx.Config.GetBool("no-deploy") &&
 		!srcInfo.SkipDeploy &&
-		(cmdCtx.Config.GetBool("now") || confirm("Would you like to deploy now?")) {
+		(cmdCtx.Config.GetBool("now") || answers.confirm(answers.Deploy, "deploy", "--now or --no-deploy", "Would you like to deploy now?")) {
 		return runDeploy(cmdCtx)
 	}
 
@@ -490,6 +541,55 @@ func runLaunch(cmdCtx *cmdctx.CmdContext) error {
 	return nil
 }
 
+// applySourceInfo applies the configuration the launch scanner detected to
+// appConfig.
+func applySourceInfo(appConfig *flyctl.AppConfig, srcInfo *sourcecode.SourceInfo, appName string) {
+	if srcInfo.Port > 0 {
+		appConfig.SetInternalPort(srcInfo.Port)
+	}
+
+	for envName, envVal := range srcInfo.Env {
+		if envVal == "APP_FQDN" {
+			appConfig.SetEnvVariable(envName, appName+".fly.dev")
+		} else {
+			appConfig.SetEnvVariable(envName, envVal)
+		}
+	}
+
+	if len(srcInfo.Statics) > 0 {
+		appConfig.SetStatics(srcInfo.Statics)
+	}
+
+	if len(srcInfo.Volumes) > 0 {
+		appConfig.SetVolumes(srcInfo.Volumes)
+	}
+
+	for procName, procCommand := range srcInfo.Processes {
+		appConfig.SetProcess(procName, procCommand)
+	}
+
+	if srcInfo.ReleaseCmd != "" {
+		appConfig.SetReleaseCommand(srcInfo.ReleaseCmd)
+	}
+
+	if srcInfo.DockerCommand != "" {
+		appConfig.SetDockerCommand(srcInfo.DockerCommand)
+	}
+
+	if srcInfo.DockerEntrypoint != "" {
+		appConfig.SetDockerEntrypoint(srcInfo.DockerEntrypoint)
+	}
+
+	if srcInfo.KillSignal != "" {
+		appConfig.SetKillSignal(srcInfo.KillSignal)
+	}
+
+	if len(srcInfo.BuildArgs) > 0 {
+		appConfig.Build = &flyctl.Build{}
+		appConfig.Build.Args = srcInfo.BuildArgs
+	}
+}
+
 func execInitCommand(ctx context.Context, command sourcecode.InitCommand) (err error) {
 	binary, err := exec.LookPath(command.Command)
 	if err != nil {
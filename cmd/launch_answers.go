@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/superfly/flyctl/api"
+	"github.com/superfly/flyctl/cmdctx"
+	"github.com/superfly/flyctl/helpers"
+	"github.com/superfly/flyctl/internal/cmdutil"
+	"github.com/superfly/flyctl/internal/sourcecode"
+)
+
+// launchAnswers answer the prompts of launch, so that it runs unattended, as
+// in CI or scaffolding scripts. They're read from the YAML file the answers
+// flag names, as in:
+//
+//	name: my-app
+//	org: personal
+//	region: ams
+//	overwrite: true
+//	secrets:
+//	  RAILS_MASTER_KEY: ...
+//	postgres: false
+//	deploy: true
+//
+// and the respective flags override them.
+type launchAnswers struct {
+	Name                string            `yaml:"name"`
+	GenerateName        bool              `yaml:"generate_name"`
+	Org                 string            `yaml:"org"`
+	Region              string            `yaml:"region"`
+	CopyConfig          *bool             `yaml:"copy_config"`
+	Overwrite           *bool             `yaml:"overwrite"`
+	Secrets             map[string]string `yaml:"secrets"`
+	Postgres            *bool             `yaml:"postgres"`
+	PostgresVMSize      string            `yaml:"postgres_vm_size"`
+	PostgresVolumeSize  int               `yaml:"postgres_volume_size"`
+	PostgresClusterSize int               `yaml:"postgres_cluster_size"`
+	Deploy              *bool             `yaml:"deploy"`
+
+	canPrompt bool
+	// missing lists the answers launch needed but couldn't prompt for, along
+	// with the flags which supply them.
+	missing []string
+}
+
+// loadLaunchAnswers reads the answers of the answers file, if any, and the
+// flags of launch.
+func loadLaunchAnswers(cmdCtx *cmdctx.CmdContext) (*launchAnswers, error) {
+	a := &launchAnswers{
+		canPrompt: cmdCtx.IO.CanPrompt(),
+	}
+
+	if path := cmdCtx.Config.GetString("answers"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading launch answers: %w", err)
+		}
+
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+
+		if err := dec.Decode(a); err != nil {
+			return nil, fmt.Errorf("failed parsing launch answers of %s: %w", path, err)
+		}
+	}
+
+	if v := cmdCtx.Config.GetString("name"); v != "" {
+		a.Name = v
+	}
+	if cmdCtx.Config.GetBool("generate-name") {
+		a.GenerateName = true
+	}
+	if v := cmdCtx.Config.GetString("org"); v != "" {
+		a.Org = v
+	}
+	if v := cmdCtx.Config.GetString("region"); v != "" {
+		a.Region = v
+	}
+	if cmdCtx.Config.GetBool("copy-config") {
+		a.CopyConfig = api.BoolPointer(true)
+	}
+
+	flags := cmdCtx.Command.Flags()
+	if flags.Changed("overwrite") {
+		a.Overwrite = api.BoolPointer(cmdCtx.Config.GetBool("overwrite"))
+	}
+	if flags.Changed("postgres") {
+		a.Postgres = api.BoolPointer(cmdCtx.Config.GetBool("postgres"))
+	}
+	if v := cmdCtx.Config.GetString("postgres-vm-size"); v != "" {
+		a.PostgresVMSize = v
+	}
+	if v := cmdCtx.Config.GetInt("postgres-volume-size"); v != 0 {
+		a.PostgresVolumeSize = v
+	}
+	if v := cmdCtx.Config.GetInt("postgres-cluster-size"); v != 0 {
+		a.PostgresClusterSize = v
+	}
+
+	secrets, err := cmdutil.ParseKVStringsToMap(cmdCtx.Config.GetStringSlice("secret"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret: %w", err)
+	}
+	for k, v := range secrets {
+		if a.Secrets == nil {
+			a.Secrets = map[string]string{}
+		}
+		a.Secrets[k] = v
+	}
+
+	return a, nil
+}
+
+// need records that launch needs the named answer, which the given flags
+// supply, but can't prompt for it.
+func (a *launchAnswers) need(name, flags string) {
+	a.missing = append(a.missing, missingAnswer(name, flags))
+}
+
+func missingAnswer(name, flags string) string {
+	return fmt.Sprintf("%s (%s)", name, flags)
+}
+
+// confirm returns the given answer, if any. Otherwise, it prompts with
+// message, or records the answer as missing in case it can't prompt.
+func (a *launchAnswers) confirm(answer *bool, name, flags, message string) bool {
+	switch {
+	case answer != nil:
+		return *answer
+	case a.canPrompt:
+		return confirm(message)
+	default:
+		a.need(name, flags)
+
+		return false
+	}
+}
+
+// overwrite reports whether launch should overwrite the existing file at
+// path.
+func (a *launchAnswers) overwrite(path string) bool {
+	if a.Overwrite != nil {
+		return *a.Overwrite
+	}
+
+	return confirmOverwrite(path)
+}
+
+// check records the answers launch would prompt for, after scanning dir, but
+// which are missing, and fails listing the ones recorded so far, if any, in
+// case launch can't prompt. offersDeploy reports whether launch offers to
+// deploy, while orgs lists the organizations of the user; it's only called in
+// case no organization is answered.
+func (a *launchAnswers) check(dir string, srcInfo *sourcecode.SourceInfo, offersDeploy bool, orgs func() ([]api.Organization, error)) error {
+	if a.canPrompt {
+		return nil
+	}
+
+	var overwrites bool
+	if srcInfo != nil {
+		for _, f := range srcInfo.Files {
+			if helpers.FileExists(filepath.Join(dir, f.Path)) {
+				overwrites = true
+
+				break
+			}
+		}
+	}
+
+	var picksOrg bool
+	if a.Org == "" {
+		userOrgs, err := orgs()
+		if err != nil {
+			return err
+		}
+
+		// launch picks the personal organization of users who belong to no
+		// other one without prompting
+		picksOrg = len(userOrgs) != 1 || userOrgs[0].Type != "PERSONAL"
+	}
+
+	a.missing = append(a.missing, a.missingAnswers(srcInfo, overwrites, picksOrg, offersDeploy)...)
+
+	if len(a.missing) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("launch can't prompt for answers here; supply the following ones in the --answers file or with the respective flags:\n  %s",
+		strings.Join(a.missing, "\n  "))
+}
+
+// missingAnswers returns the answers launch would prompt for but which are
+// missing, along with the flags which supply them, given the source it
+// scanned, if any, and whether it would overwrite existing files, pick an
+// organization and offer to deploy.
+func (a *launchAnswers) missingAnswers(srcInfo *sourcecode.SourceInfo, overwrites, picksOrg, offersDeploy bool) (missing []string) {
+	need := func(name, flags string) {
+		missing = append(missing, missingAnswer(name, flags))
+	}
+
+	if overwrites && a.Overwrite == nil {
+		need("overwrite", "--overwrite")
+	}
+
+	if !a.GenerateName && a.Name == "" {
+		need("name", "--name or --generate-name")
+	}
+
+	if a.Org == "" && picksOrg {
+		need("org", "--org")
+	}
+
+	if a.Region == "" {
+		need("region", "--region")
+	}
+
+	if srcInfo == nil {
+		return
+	}
+
+	for _, secret := range srcInfo.Secrets {
+		if _, ok := a.Secrets[secret.Key]; !ok && !secret.Generate && secret.Value == "" {
+			need("secrets."+secret.Key, fmt.Sprintf("--secret %s=VALUE", secret.Key))
+		}
+	}
+
+	if offersDeploy && !srcInfo.SkipDatabase {
+		switch {
+		case a.Postgres == nil:
+			need("postgres", "--postgres")
+		case *a.Postgres:
+			if a.PostgresVMSize == "" {
+				need("postgres_vm_size", "--postgres-vm-size")
+			}
+			if a.PostgresVolumeSize == 0 {
+				need("postgres_volume_size", "--postgres-volume-size")
+			}
+			if a.PostgresClusterSize == 0 {
+				need("postgres_cluster_size", "--postgres-cluster-size")
+			}
+		}
+	}
+
+	if offersDeploy && !srcInfo.SkipDeploy && a.Deploy == nil {
+		need("deploy", "--now or --no-deploy")
+	}
+
+	return
+}
@@ -0,0 +1,349 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/api"
+	"github.com/superfly/flyctl/cmdctx"
+	"github.com/superfly/flyctl/internal/sourcecode"
+	"github.com/superfly/flyctl/pkg/iostreams"
+)
+
+// launchContext returns the context of a launch run with the given flags,
+// bound to a configuration of its own, as the root command binds them.
+func launchContext(t *testing.T, flags map[string]string) *cmdctx.CmdContext {
+	t.Helper()
+
+	cmd := &cobra.Command{Use: "launch"}
+
+	fs := cmd.Flags()
+	for _, name := range []string{"answers", "name", "org", "region", "postgres-vm-size"} {
+		fs.String(name, "", "")
+	}
+	for _, name := range []string{"generate-name", "copy-config", "overwrite", "postgres", "now", "no-deploy"} {
+		fs.Bool(name, false, "")
+	}
+	for _, name := range []string{"postgres-volume-size", "postgres-cluster-size"} {
+		fs.Int(name, 0, "")
+	}
+	fs.StringSlice("secret", nil, "")
+
+	for name, value := range flags {
+		require.NoError(t, fs.Set(name, value))
+	}
+
+	cfg := viper.New()
+	require.NoError(t, cfg.BindPFlags(fs))
+
+	io, _, _, _ := iostreams.Test()
+
+	return &cmdctx.CmdContext{
+		IO:      io,
+		Config:  cfg,
+		Command: cmd,
+	}
+}
+
+func TestLoadLaunchAnswers(t *testing.T) {
+	const answers = `
+name: from-file
+org: personal
+region: ams
+overwrite: true
+secrets:
+  RAILS_MASTER_KEY: file-key
+  API_TOKEN: file-token
+postgres: true
+postgres_vm_size: shared-cpu-1x
+postgres_volume_size: 10
+deploy: false
+`
+
+	cases := map[string]struct {
+		file  string
+		flags map[string]string
+		want  launchAnswers
+		err   string
+	}{
+		"none": {},
+		"flags": {
+			flags: map[string]string{
+				"generate-name": "true",
+				"region":        "fra",
+				"overwrite":     "false",
+				"postgres":      "false",
+				"secret":        "A=1,B=2",
+			},
+			want: launchAnswers{
+				GenerateName: true,
+				Region:       "fra",
+				Overwrite:    api.BoolPointer(false),
+				Postgres:     api.BoolPointer(false),
+				Secrets:      map[string]string{"A": "1", "B": "2"},
+			},
+		},
+		"file": {
+			file: answers,
+			want: launchAnswers{
+				Name:               "from-file",
+				Org:                "personal",
+				Region:             "ams",
+				Overwrite:          api.BoolPointer(true),
+				Secrets:            map[string]string{"RAILS_MASTER_KEY": "file-key", "API_TOKEN": "file-token"},
+				Postgres:           api.BoolPointer(true),
+				PostgresVMSize:     "shared-cpu-1x",
+				PostgresVolumeSize: 10,
+				Deploy:             api.BoolPointer(false),
+			},
+		},
+		"flags override the file": {
+			file: answers,
+			flags: map[string]string{
+				"name":                 "from-flag",
+				"org":                  "acme",
+				"copy-config":          "true",
+				"overwrite":            "false",
+				"postgres":             "false",
+				"postgres-volume-size": "20",
+				"secret":               "API_TOKEN=flag-token",
+			},
+			want: launchAnswers{
+				Name:               "from-flag",
+				Org:                "acme",
+				Region:             "ams",
+				CopyConfig:         api.BoolPointer(true),
+				Overwrite:          api.BoolPointer(false),
+				Secrets:            map[string]string{"RAILS_MASTER_KEY": "file-key", "API_TOKEN": "flag-token"},
+				Postgres:           api.BoolPointer(false),
+				PostgresVMSize:     "shared-cpu-1x",
+				PostgresVolumeSize: 20,
+				Deploy:             api.BoolPointer(false),
+			},
+		},
+		"unset bool flags keep the file's answers": {
+			file:  "overwrite: true\npostgres: true\n",
+			flags: map[string]string{"now": "true"},
+			want: launchAnswers{
+				Overwrite: api.BoolPointer(true),
+				Postgres:  api.BoolPointer(true),
+			},
+		},
+		"unknown answer": {
+			file: "name: app\nregoin: ams\n",
+			err:  "field regoin not found in type cmd.launchAnswers",
+		},
+		"invalid answer": {
+			file: "postgres: maybe\n",
+			err:  "cannot unmarshal !!str `maybe` into bool",
+		},
+		"invalid secret": {
+			flags: map[string]string{"secret": "NO_VALUE"},
+			err:   "invalid secret",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			flags := map[string]string{}
+			for k, v := range c.flags {
+				flags[k] = v
+			}
+
+			if c.file != "" {
+				path := filepath.Join(t.TempDir(), "answers.yml")
+				require.NoError(t, os.WriteFile(path, []byte(c.file), 0644))
+				flags["answers"] = path
+			}
+
+			got, err := loadLaunchAnswers(launchContext(t, flags))
+			if c.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), c.err)
+
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, &c.want, got)
+		})
+	}
+}
+
+func TestLoadLaunchAnswersMissingFile(t *testing.T) {
+	_, err := loadLaunchAnswers(launchContext(t, map[string]string{
+		"answers": filepath.Join(t.TempDir(), "missing.yml"),
+	}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed reading launch answers")
+}
+
+func TestMissingAnswers(t *testing.T) {
+	srcInfo := &sourcecode.SourceInfo{
+		Secrets: []sourcecode.Secret{
+			{Key: "RAILS_MASTER_KEY"},
+			{Key: "SECRET_KEY_BASE", Generate: true},
+			{Key: "APP_KEY", Value: "base64:c2VjcmV0"},
+		},
+	}
+
+	cases := map[string]struct {
+		answers      launchAnswers
+		srcInfo      *sourcecode.SourceInfo
+		overwrites   bool
+		picksOrg     bool
+		offersDeploy bool
+		want         []string
+	}{
+		"without source": {
+			picksOrg:     true,
+			offersDeploy: true,
+			want: []string{
+				"name (--name or --generate-name)",
+				"org (--org)",
+				"region (--region)",
+			},
+		},
+		"everything": {
+			srcInfo:      srcInfo,
+			overwrites:   true,
+			picksOrg:     true,
+			offersDeploy: true,
+			want: []string{
+				"overwrite (--overwrite)",
+				"name (--name or --generate-name)",
+				"org (--org)",
+				"region (--region)",
+				"secrets.RAILS_MASTER_KEY (--secret RAILS_MASTER_KEY=VALUE)",
+				"postgres (--postgres)",
+				"deploy (--now or --no-deploy)",
+			},
+		},
+		"answered": {
+			answers: launchAnswers{
+				GenerateName: true,
+				Region:       "ams",
+				Overwrite:    api.BoolPointer(false),
+				Secrets:      map[string]string{"RAILS_MASTER_KEY": "key"},
+				Postgres:     api.BoolPointer(false),
+				Deploy:       api.BoolPointer(true),
+			},
+			srcInfo:      srcInfo,
+			overwrites:   true,
+			picksOrg:     true,
+			offersDeploy: true,
+			want:         []string{"org (--org)"},
+		},
+		"answered org": {
+			answers:  launchAnswers{Name: "app", Org: "acme", Region: "ams"},
+			picksOrg: true,
+		},
+		"nothing to overwrite or pick": {
+			answers: launchAnswers{Name: "app", Region: "ams"},
+		},
+		"postgres sizes": {
+			answers: launchAnswers{
+				Name:           "app",
+				Region:         "ams",
+				Postgres:       api.BoolPointer(true),
+				PostgresVMSize: "shared-cpu-1x",
+				Deploy:         api.BoolPointer(false),
+			},
+			srcInfo:      &sourcecode.SourceInfo{},
+			offersDeploy: true,
+			want: []string{
+				"postgres_volume_size (--postgres-volume-size)",
+				"postgres_cluster_size (--postgres-cluster-size)",
+			},
+		},
+		"no deploy offered": {
+			answers: launchAnswers{Name: "app", Region: "ams"},
+			srcInfo: &sourcecode.SourceInfo{},
+		},
+		"source skips database and deploy": {
+			answers:      launchAnswers{Name: "app", Region: "ams"},
+			srcInfo:      &sourcecode.SourceInfo{SkipDatabase: true, SkipDeploy: true},
+			offersDeploy: true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := c.answers.missingAnswers(c.srcInfo, c.overwrites, c.picksOrg, c.offersDeploy)
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestLaunchAnswersCheck(t *testing.T) {
+	personal := []api.Organization{{Slug: "personal", Type: "PERSONAL"}}
+	shared := []api.Organization{{Slug: "personal", Type: "PERSONAL"}, {Slug: "acme", Type: "SHARED"}}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM scratch\n"), 0644))
+
+	dockerfile := &sourcecode.SourceInfo{
+		Files:        []sourcecode.SourceFile{{Path: "Dockerfile"}},
+		SkipDatabase: true,
+	}
+
+	cases := map[string]struct {
+		answers launchAnswers
+		srcInfo *sourcecode.SourceInfo
+		orgs    []api.Organization
+		orgsErr error
+		err     string
+	}{
+		"prompts": {
+			answers: launchAnswers{canPrompt: true},
+			orgsErr: errors.New("not called"),
+		},
+		"answered": {
+			answers: launchAnswers{Name: "app", Region: "ams", Overwrite: api.BoolPointer(true), Deploy: api.BoolPointer(true)},
+			srcInfo: dockerfile,
+			orgs:    personal,
+		},
+		"answered org isn't looked up": {
+			answers: launchAnswers{Name: "app", Org: "acme", Region: "ams"},
+			orgsErr: errors.New("not called"),
+		},
+		"missing": {
+			// answers launch needed before scanning are listed first
+			answers: launchAnswers{Name: "app", missing: []string{"copy_config (--copy-config)"}},
+			srcInfo: dockerfile,
+			orgs:    shared,
+			err: "launch can't prompt for answers here; supply the following ones in the --answers file or with the respective flags:\n" +
+				"  copy_config (--copy-config)\n" +
+				"  overwrite (--overwrite)\n" +
+				"  org (--org)\n" +
+				"  region (--region)\n" +
+				"  deploy (--now or --no-deploy)",
+		},
+		"failed org lookup": {
+			answers: launchAnswers{Name: "app", Region: "ams"},
+			orgsErr: errors.New("unauthorized"),
+			err:     "unauthorized",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := c.answers.check(dir, c.srcInfo, true, func() ([]api.Organization, error) {
+				return c.orgs, c.orgsErr
+			})
+
+			if c.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, c.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/superfly/flyctl/flyctl"
+	"github.com/superfly/flyctl/helpers"
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/sourcecode"
+)
+
+// launchPlan describes what launch would do, as answers answer its prompts.
+// It's built without changing or prompting for anything, so that it can be
+// printed on dry runs.
+type launchPlan struct {
+	AppName string
+	Org     string
+	Region  string
+
+	// Source is the source launch detected, if any.
+	Source *sourcecode.SourceInfo
+
+	// Files lists the paths of the files launch would create or modify,
+	// along with what it would do to them. The same goes for the secrets it
+	// would set, the volumes it would create and the commands it would run.
+	Files    []launchStep
+	Secrets  []launchStep
+	Volumes  []launchStep
+	Commands []launchStep
+	// Then lists what launch would do once the app is created.
+	Then []launchStep
+
+	ConfigPath string
+	Config     *flyctl.AppConfig
+}
+
+// launchStep is a single step of a launchPlan.
+type launchStep struct {
+	Name   string
+	Action string
+}
+
+// newLaunchPlan returns the plan of the launch of the source srcInfo describes
+// in dir, which would write appConfig to configFilePath. exists reports
+// whether the file at the given path exists.
+//
+// appConfig is completed with srcInfo the way launch completes it. In case it
+// defines nothing, it's based on the definition the platform creates new apps
+// with.
+func newLaunchPlan(answers *launchAnswers, dir, configFilePath string, appConfig *flyctl.AppConfig, srcInfo *sourcecode.SourceInfo, noDeploy, now bool, exists func(string) bool) *launchPlan {
+	plan := &launchPlan{
+		AppName:    answers.Name,
+		Org:        answers.Org,
+		Region:     answers.Region,
+		ConfigPath: configFilePath,
+		Config:     appConfig,
+	}
+
+	switch {
+	case answers.GenerateName:
+		plan.AppName = "<generated>"
+	case plan.AppName == "":
+		plan.AppName = "<prompted for>"
+	}
+	if plan.Org == "" {
+		plan.Org = "<prompted for>"
+	}
+	if plan.Region == "" {
+		plan.Region = "<prompted for>"
+	}
+
+	if srcInfo != nil {
+		if srcInfo.Family != "" {
+			plan.Source = srcInfo
+		}
+
+		for _, f := range srcInfo.Files {
+			path := filepath.Join(dir, f.Path)
+
+			action := "create"
+			if exists(path) {
+				action = decision(answers.Overwrite, "overwrite", "keep", "overwrite, if confirmed")
+			}
+			plan.Files = append(plan.Files, launchStep{path, action})
+		}
+
+		if len(srcInfo.DockerfileAppendix) > 0 {
+			plan.Files = append(plan.Files, launchStep{
+				filepath.Join(dir, "Dockerfile"),
+				"append " + strings.Join(srcInfo.DockerfileAppendix, "; "),
+			})
+		}
+	}
+
+	action := "create"
+	if exists(configFilePath) {
+		action = "overwrite"
+	}
+	plan.Files = append(plan.Files, launchStep{configFilePath, action})
+
+	if appConfig.Definition.IsEmpty() {
+		appConfig.Definition = defaultDefinition()
+	}
+	appConfig.AppName = plan.AppName
+
+	if srcInfo == nil {
+		return plan
+	}
+
+	applySourceInfo(appConfig, srcInfo, plan.AppName)
+
+	for _, secret := range srcInfo.Secrets {
+		var source string
+		switch _, answered := answers.Secrets[secret.Key]; {
+		case answered:
+			source = "answered"
+		case secret.Generate:
+			source = "generated randomly"
+		case secret.Value != "":
+			source = "detected from the source"
+		default:
+			source = "prompted for"
+		}
+		plan.Secrets = append(plan.Secrets, launchStep{secret.Key, source})
+	}
+
+	for _, vol := range srcInfo.Volumes {
+		plan.Volumes = append(plan.Volumes, launchStep{
+			vol.Source,
+			fmt.Sprintf("1GB in %s, mounted at %s", plan.Region, vol.Destination),
+		})
+	}
+
+	for _, cmd := range srcInfo.InitCommands {
+		plan.Commands = append(plan.Commands, launchStep{commandLine(cmd), cmd.Description})
+	}
+
+	if !noDeploy && !now && !srcInfo.SkipDatabase {
+		action := decision(answers.Postgres, "create and attach", "skip", "offer to create and attach")
+		plan.Then = append(plan.Then, launchStep{
+			"Postgres",
+			fmt.Sprintf("%s the Postgres cluster %s-db", action, plan.AppName),
+		})
+
+		if answers.Postgres == nil || *answers.Postgres {
+			for _, cmd := range srcInfo.PostgresInitCommands {
+				if cmd.Condition {
+					plan.Then = append(plan.Then, launchStep{"", "then run " + commandLine(cmd)})
+				}
+			}
+		}
+	}
+
+	var deploy string
+	switch {
+	case noDeploy || srcInfo.SkipDeploy:
+		deploy = "skip"
+	case now:
+		deploy = "deploy right away"
+	default:
+		deploy = decision(answers.Deploy, "deploy right away", "skip", "offer to deploy")
+	}
+	plan.Then = append(plan.Then, launchStep{"Deploy", deploy})
+
+	return plan
+}
+
+// defaultDefinition returns the definition the platform creates new apps
+// with, which launch bases the configs of the apps it creates on.
+func defaultDefinition() app.Definition {
+	def, _ := app.DefinitionFromMap(map[string]interface{}{
+		"kill_signal":  "SIGINT",
+		"kill_timeout": 5,
+		"experimental": map[string]interface{}{
+			"auto_rollback": true,
+		},
+		"services": []interface{}{
+			map[string]interface{}{
+				"internal_port": 8080,
+				"processes":     []interface{}{"app"},
+				"protocol":      "tcp",
+				"concurrency": map[string]interface{}{
+					"type":       "connections",
+					"hard_limit": 25,
+					"soft_limit": 20,
+				},
+				"ports": []interface{}{
+					map[string]interface{}{"port": 80, "handlers": []interface{}{"http"}, "force_https": true},
+					map[string]interface{}{"port": 443, "handlers": []interface{}{"tls", "http"}},
+				},
+				"tcp_checks": []interface{}{
+					map[string]interface{}{"grace_period": "1s", "interval": "15s", "restart_limit": 0, "timeout": "2s"},
+				},
+			},
+		},
+	})
+
+	return def
+}
+
+// print prints p to w.
+func (p *launchPlan) print(w io.Writer) error {
+	fmt.Fprintln(w, "\nDry run: launch would do the following; nothing has been created or changed.")
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	section := func(title string) {
+		tw.Flush()
+		fmt.Fprintf(w, "\n%s:\n", title)
+	}
+	steps := func(title string, steps []launchStep, path bool) {
+		if len(steps) == 0 {
+			return
+		}
+
+		section(title)
+		for _, step := range steps {
+			name := step.Name
+			if path {
+				name = helpers.PathRelativeToCWD(name)
+			}
+			fmt.Fprintf(tw, "  %s\t%s\n", name, step.Action)
+		}
+	}
+
+	section("App")
+	fmt.Fprintf(tw, "  Name\t%s\n", p.AppName)
+	fmt.Fprintf(tw, "  Organization\t%s\n", p.Org)
+	fmt.Fprintf(tw, "  Region\t%s\n", p.Region)
+
+	if p.Source != nil {
+		section("Detected source")
+		printSourceInfo(tw, p.Source)
+	}
+
+	steps("Files", p.Files, true)
+	steps("Secrets", p.Secrets, false)
+	steps("Volumes", p.Volumes, false)
+	steps("Commands", p.Commands, false)
+	steps("Then", p.Then, false)
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%s:\n", helpers.PathRelativeToCWD(p.ConfigPath))
+
+	return p.Config.WriteTo(w, flyctl.ConfigFormatFromPath(p.ConfigPath))
+}
+
+// printSourceInfo prints the build and runtime configuration of srcInfo.
+func printSourceInfo(w *tabwriter.Writer, srcInfo *sourcecode.SourceInfo) {
+	family := srcInfo.Family
+	if srcInfo.Version != "" {
+		family += " " + srcInfo.Version
+	}
+	fmt.Fprintf(w, "  Family\t%s\n", family)
+
+	if srcInfo.Builder != "" {
+		fmt.Fprintf(w, "  Builder\t%s\n", srcInfo.Builder)
+	}
+	if len(srcInfo.Buildpacks) > 0 {
+		fmt.Fprintf(w, "  Buildpacks\t%s\n", strings.Join(srcInfo.Buildpacks, " "))
+	}
+	for _, k := range sortedKeys(srcInfo.BuildArgs) {
+		fmt.Fprintf(w, "  Build arg\t%s=%s\n", k, srcInfo.BuildArgs[k])
+	}
+	if srcInfo.Port > 0 {
+		fmt.Fprintf(w, "  Port\t%d\n", srcInfo.Port)
+	}
+	if srcInfo.ReleaseCmd != "" {
+		fmt.Fprintf(w, "  Release command\t%s\n", srcInfo.ReleaseCmd)
+	}
+	for _, static := range srcInfo.Statics {
+		fmt.Fprintf(w, "  Statics\t%s served from %s\n", static.UrlPrefix, static.GuestPath)
+	}
+}
+
+// decision returns yes or no, as answer answers, or prompt in case it's
+// unanswered.
+func decision(answer *bool, yes, no, prompt string) string {
+	switch {
+	case answer == nil:
+		return prompt
+	case *answer:
+		return yes
+	default:
+		return no
+	}
+}
+
+func commandLine(cmd sourcecode.InitCommand) string {
+	return strings.Join(append([]string{cmd.Command}, cmd.Args...), " ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
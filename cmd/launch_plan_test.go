@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/api"
+	"github.com/superfly/flyctl/flyctl"
+	"github.com/superfly/flyctl/internal/sourcecode"
+)
+
+func TestNewLaunchPlan(t *testing.T) {
+	const dir = "/src"
+
+	srcInfo := &sourcecode.SourceInfo{
+		Family: "Rails",
+		Port:   3000,
+		Files:  []sourcecode.SourceFile{{Path: "Dockerfile"}, {Path: "bin/start"}},
+		Secrets: []sourcecode.Secret{
+			{Key: "RAILS_MASTER_KEY"},
+			{Key: "SECRET_KEY_BASE", Generate: true},
+			{Key: "APP_KEY", Value: "base64:c2VjcmV0"},
+			{Key: "API_TOKEN"},
+		},
+		Volumes:      []sourcecode.Volume{{Source: "data", Destination: "/data"}},
+		InitCommands: []sourcecode.InitCommand{{Command: "bin/rails", Args: []string{"generate", "dockerfile"}, Description: "Generating the Dockerfile"}},
+		PostgresInitCommands: []sourcecode.InitCommand{
+			{Command: "bundle", Args: []string{"add", "pg"}, Condition: true},
+			{Command: "bundle", Args: []string{"add", "mysql2"}},
+		},
+	}
+
+	cases := map[string]struct {
+		answers  launchAnswers
+		srcInfo  *sourcecode.SourceInfo
+		existing []string
+		noDeploy bool
+		now      bool
+
+		files    []launchStep
+		secrets  []launchStep
+		volumes  []launchStep
+		commands []launchStep
+		then     []launchStep
+	}{
+		"blank app": {
+			answers: launchAnswers{GenerateName: true, Region: "ams"},
+			files:   []launchStep{{"/src/fly.toml", "create"}},
+		},
+		"unanswered": {
+			srcInfo:  srcInfo,
+			existing: []string{"/src/Dockerfile", "/src/fly.toml"},
+			files: []launchStep{
+				{"/src/Dockerfile", "overwrite, if confirmed"},
+				{"/src/bin/start", "create"},
+				{"/src/fly.toml", "overwrite"},
+			},
+			secrets: []launchStep{
+				{"RAILS_MASTER_KEY", "prompted for"},
+				{"SECRET_KEY_BASE", "generated randomly"},
+				{"APP_KEY", "detected from the source"},
+				{"API_TOKEN", "prompted for"},
+			},
+			volumes:  []launchStep{{"data", "1GB in <prompted for>, mounted at /data"}},
+			commands: []launchStep{{"bin/rails generate dockerfile", "Generating the Dockerfile"}},
+			then: []launchStep{
+				{"Postgres", "offer to create and attach the Postgres cluster <prompted for>-db"},
+				{"", "then run bundle add pg"},
+				{"Deploy", "offer to deploy"},
+			},
+		},
+		"answered": {
+			answers: launchAnswers{
+				Name:      "app",
+				Region:    "ams",
+				Overwrite: api.BoolPointer(false),
+				Secrets:   map[string]string{"RAILS_MASTER_KEY": "key", "SECRET_KEY_BASE": "base"},
+				Postgres:  api.BoolPointer(false),
+				Deploy:    api.BoolPointer(true),
+			},
+			srcInfo:  srcInfo,
+			existing: []string{"/src/Dockerfile"},
+			files: []launchStep{
+				{"/src/Dockerfile", "keep"},
+				{"/src/bin/start", "create"},
+				{"/src/fly.toml", "create"},
+			},
+			secrets: []launchStep{
+				{"RAILS_MASTER_KEY", "answered"},
+				{"SECRET_KEY_BASE", "answered"},
+				{"APP_KEY", "detected from the source"},
+				{"API_TOKEN", "prompted for"},
+			},
+			volumes:  []launchStep{{"data", "1GB in ams, mounted at /data"}},
+			commands: []launchStep{{"bin/rails generate dockerfile", "Generating the Dockerfile"}},
+			then: []launchStep{
+				{"Postgres", "skip the Postgres cluster app-db"},
+				{"Deploy", "deploy right away"},
+			},
+		},
+		"overwrite and postgres": {
+			answers: launchAnswers{
+				Name:      "app",
+				Overwrite: api.BoolPointer(true),
+				Postgres:  api.BoolPointer(true),
+				Deploy:    api.BoolPointer(false),
+			},
+			srcInfo: &sourcecode.SourceInfo{
+				Files:                []sourcecode.SourceFile{{Path: "Dockerfile"}},
+				DockerfileAppendix:   []string{"ENV A=1", "ENV B=2"},
+				PostgresInitCommands: srcInfo.PostgresInitCommands,
+			},
+			existing: []string{"/src/Dockerfile"},
+			files: []launchStep{
+				{"/src/Dockerfile", "overwrite"},
+				{"/src/Dockerfile", "append ENV A=1; ENV B=2"},
+				{"/src/fly.toml", "create"},
+			},
+			then: []launchStep{
+				{"Postgres", "create and attach the Postgres cluster app-db"},
+				{"", "then run bundle add pg"},
+				{"Deploy", "skip"},
+			},
+		},
+		"no deploy": {
+			answers:  launchAnswers{Name: "app", Deploy: api.BoolPointer(true)},
+			srcInfo:  &sourcecode.SourceInfo{},
+			noDeploy: true,
+			files:    []launchStep{{"/src/fly.toml", "create"}},
+			then:     []launchStep{{"Deploy", "skip"}},
+		},
+		"now": {
+			answers: launchAnswers{Name: "app"},
+			srcInfo: &sourcecode.SourceInfo{},
+			now:     true,
+			files:   []launchStep{{"/src/fly.toml", "create"}},
+			then:    []launchStep{{"Deploy", "deploy right away"}},
+		},
+		"source skips database and deploy": {
+			answers: launchAnswers{Name: "app", Deploy: api.BoolPointer(true)},
+			srcInfo: &sourcecode.SourceInfo{SkipDatabase: true, SkipDeploy: true},
+			files:   []launchStep{{"/src/fly.toml", "create"}},
+			then:    []launchStep{{"Deploy", "skip"}},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			exists := func(path string) bool {
+				for _, e := range c.existing {
+					if filepath.FromSlash(e) == path {
+						return true
+					}
+				}
+
+				return false
+			}
+
+			got := newLaunchPlan(&c.answers, dir, filepath.Join(dir, "fly.toml"), flyctl.NewAppConfig(), c.srcInfo, c.noDeploy, c.now, exists)
+
+			assert.Equal(t, c.files, got.Files, "files")
+			assert.Equal(t, c.secrets, got.Secrets, "secrets")
+			assert.Equal(t, c.volumes, got.Volumes, "volumes")
+			assert.Equal(t, c.commands, got.Commands, "commands")
+			assert.Equal(t, c.then, got.Then, "then")
+		})
+	}
+}
+
+func TestLaunchPlanConfig(t *testing.T) {
+	answers := &launchAnswers{Name: "app", Region: "ams"}
+	srcInfo := &sourcecode.SourceInfo{Port: 3000}
+	never := func(string) bool { return false }
+
+	// new apps get the default services, on the port the source listens on
+	plan := newLaunchPlan(answers, "/src", "/src/fly.toml", flyctl.NewAppConfig(), srcInfo, false, false, never)
+
+	def := plan.Config.Definition
+	require.Len(t, def.Services, 1)
+	assert.Equal(t, 3000, def.Services[0].InternalPort)
+	assert.Len(t, def.Services[0].Ports, 2)
+	assert.Equal(t, "app", plan.Config.AppName)
+
+	var b bytes.Buffer
+	require.NoError(t, plan.print(&b))
+	assert.Contains(t, b.String(), "internal_port = 3000")
+	assert.NotContains(t, b.String(), "default services")
+
+	// copied configs are kept as they are
+	copied := flyctl.NewAppConfig()
+	copied.Definition.KillSignal = "SIGTERM"
+
+	plan = newLaunchPlan(answers, "/src", "/src/fly.toml", copied, nil, false, false, never)
+	assert.Equal(t, "SIGTERM", plan.Config.Definition.KillSignal)
+	assert.Empty(t, plan.Config.Definition.Services)
+}
@@ -50,7 +50,6 @@ func NewRootCmd(client *client.Client) *cobra.Command {
 
 	rootCmd.AddCommand(
 		newCertificatesCommand(client),
-		newConfigCommand(client),
 		newDashboardCommand(client),
 		newInfoCommand(client),
 		newIPAddressesCommand(client),
@@ -217,7 +217,9 @@ func runTurboku(cmdCtx *cmdctx.CmdContext) error {
 	// Generate an app config to write to fly.toml
 	appConfig := flyctl.NewAppConfig()
 
-	appConfig.Definition = app.Config.Definition
+	if err := appConfig.SetDefinition(app.Config.Definition); err != nil {
+		return err
+	}
 	procfile := ""
 
 	// Add each process to a Procfile and fly.toml
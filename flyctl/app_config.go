@@ -1,32 +1,33 @@
 package flyctl
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
-	"time"
+	"strings"
 
-	"github.com/BurntSushi/toml"
 	"github.com/superfly/flyctl/helpers"
+	"github.com/superfly/flyctl/internal/app"
 	"github.com/superfly/flyctl/internal/sourcecode"
+	"github.com/superfly/flyctl/terminal"
 )
 
 type ConfigFormat string
 
 const (
 	TOMLFormat        ConfigFormat = ".toml"
+	YAMLFormat        ConfigFormat = ".yaml"
+	JSONFormat        ConfigFormat = ".json"
 	UnsupportedFormat              = ""
 )
 
 type AppConfig struct {
 	AppName    string
 	Build      *Build
-	Definition map[string]interface{}
+	Definition app.Definition
 }
 
 type Build struct {
@@ -44,9 +45,7 @@ type Build struct {
 }
 
 func NewAppConfig() *AppConfig {
-	return &AppConfig{
-		Definition: map[string]interface{}{},
-	}
+	return &AppConfig{}
 }
 
 func LoadAppConfig(configFile string) (*AppConfig, error) {
@@ -55,28 +54,55 @@ func LoadAppConfig(configFile string) (*AppConfig, error) {
 		return nil, err
 	}
 
-	appConfig := AppConfig{
-		Definition: map[string]interface{}{},
+	appConfig := AppConfig{}
+
+	format := ConfigFormatFromPath(fullConfigFilePath)
+	if format == UnsupportedFormat {
+		return nil, errors.New("Unsupported config file format")
 	}
 
-	file, err := os.Open(fullConfigFilePath)
+	data, err := os.ReadFile(fullConfigFilePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	switch ConfigFormatFromPath(fullConfigFilePath) {
-	case TOMLFormat:
-		err = appConfig.unmarshalTOML(file)
-	default:
-		return nil, errors.New("Unsupported config file format")
+	err = appConfig.unmarshal(data, format)
+
+	// as with the configs commands load, fields which fail to load hold zero
+	// values, so that commands which don't consume them keep working
+	var verr *app.ValidationError
+	if errors.As(err, &verr) {
+		for _, fe := range verr.Errors {
+			terminal.Warnf("app config %s: %s\n", configFile, fe)
+		}
+
+		err = nil
 	}
 
 	return &appConfig, err
 }
 
 func (ac *AppConfig) HasDefinition() bool {
-	return len(ac.Definition) > 0
+	return !ac.Definition.IsEmpty()
+}
+
+// SetDefinition replaces the definition with the untyped one given, such as
+// the one the API returns. As the API accepted it already, fields which fail
+// to decode are warned about and kept as they were.
+func (ac *AppConfig) SetDefinition(m map[string]interface{}) error {
+	var err error
+	ac.Definition, err = app.DefinitionFromMap(m)
+
+	var verr *app.ValidationError
+	if errors.As(err, &verr) {
+		for _, fe := range verr.Errors {
+			terminal.Warnf("app config: %s\n", fe)
+		}
+
+		err = nil
+	}
+
+	return err
 }
 
 func (ac *AppConfig) HasBuilder() bool {
@@ -110,21 +136,20 @@ func (ac *AppConfig) DockerBuildTarget() string {
 
 func (ac *AppConfig) WriteTo(w io.Writer, format ConfigFormat) error {
 	switch format {
-	case TOMLFormat:
-		return ac.marshalTOML(w)
+	case TOMLFormat, YAMLFormat, JSONFormat:
+		return app.Marshal(w, ac.raw(), format.appFormat())
 	}
 
 	return fmt.Errorf("Unsupported format: %s", format)
 }
 
-func (ac *AppConfig) unmarshalTOML(r io.Reader) error {
-	var data map[string]interface{}
-
-	if _, err := toml.DecodeReader(r, &data); err != nil {
+func (ac *AppConfig) unmarshal(data []byte, format ConfigFormat) error {
+	raw, err := app.Unmarshal(data, format.appFormat())
+	if err != nil {
 		return err
 	}
 
-	return ac.unmarshalNativeMap(data)
+	return ac.unmarshalNativeMap(raw)
 }
 
 func (ac *AppConfig) unmarshalNativeMap(data map[string]interface{}) error {
@@ -190,25 +215,15 @@ func (ac *AppConfig) unmarshalNativeMap(data map[string]interface{}) error {
 
 	delete(data, "build")
 
-	ac.Definition = data
+	var err error
+	ac.Definition, err = app.DefinitionFromMap(data)
 
-	return nil
+	return err
 }
 
-func (ac AppConfig) marshalTOML(w io.Writer) error {
-	encoder := toml.NewEncoder(w)
-
-	fmt.Fprintf(w, "# fly.toml file generated for %s on %s\n\n", ac.AppName, time.Now().Format(time.RFC3339))
-
-	rawData := map[string]interface{}{
-		"app": ac.AppName,
-	}
-
-	if err := encoder.Encode(rawData); err != nil {
-		return err
-	}
-
-	rawData = ac.Definition
+func (ac AppConfig) raw() map[string]interface{} {
+	rawData := ac.Definition.Map()
+	rawData["app"] = ac.AppName
 
 	if ac.Build != nil {
 		buildData := map[string]interface{}{}
@@ -233,25 +248,13 @@ func (ac AppConfig) marshalTOML(w io.Writer) error {
 		if ac.Build.Dockerfile != "" {
 			buildData["dockerfile"] = ac.Build.Dockerfile
 		}
-		rawData["build"] = buildData
-	}
-
-	if len(ac.Definition) > 0 {
-		// roundtrip through json encoder to convert float64 numbers to json.Number, otherwise numbers are floats in toml
-		var buf bytes.Buffer
-		json.NewEncoder(&buf).Encode(ac.Definition)
-		d := json.NewDecoder(&buf)
-		d.UseNumber()
-		if err := d.Decode(&ac.Definition); err != nil {
-			return err
-		}
-
-		if err := encoder.Encode(ac.Definition); err != nil {
-			return err
+		if ac.Build.DockerBuildTarget != "" {
+			buildData["build_target"] = ac.Build.DockerBuildTarget
 		}
+		rawData["build"] = buildData
 	}
 
-	return nil
+	return rawData
 }
 
 func (ac *AppConfig) WriteToFile(filename string) error {
@@ -270,171 +273,61 @@ func (ac *AppConfig) WriteToFile(filename string) error {
 
 // HasServices - Does this config have a services section
 func (ac *AppConfig) HasServices() bool {
-	_, ok := ac.Definition["services"].([]interface{})
-
-	return ok
+	return len(ac.Definition.Services) > 0
 }
 
 func (ac *AppConfig) SetInternalPort(port int) bool {
-	if services, ok := ac.Definition["services"].([]interface{}); ok {
-		if len(services) == 0 {
-			return false
-		}
-
-		if service, ok := services[0].(map[string]interface{}); ok {
-			service["internal_port"] = port
-			return true
-		}
-	}
-
-	return false
+	return ac.Definition.SetInternalPort(port)
 }
 
 func (ac *AppConfig) GetInternalPort() (int, error) {
-	tmpservices, ok := ac.Definition["services"]
-
-	if !ok {
+	if len(ac.Definition.Services) == 0 {
 		return -1, errors.New("could not find internal port setting")
 	}
 
-	services, ok := tmpservices.([]map[string]interface{})
-
-	if ok {
-		internalport, ok := services[0]["internal_port"].(int64)
-		if ok {
-			return int(internalport), nil
-		}
-		internalportfloat, ok := services[0]["internal_port"].(float64)
-		if ok {
-			return int(internalportfloat), nil
-		}
+	if port := ac.Definition.Services[0].InternalPort; port > 0 {
+		return port, nil
 	}
+
 	return 8080, nil
 }
 
 func (ac *AppConfig) SetEnvVariables(vals map[string]string) {
-	var env map[string]string
-
-	if rawEnv, ok := ac.Definition["env"]; ok {
-		if castEnv, ok := rawEnv.(map[string]string); ok {
-			env = castEnv
-		}
-	}
-	if env == nil {
-		env = map[string]string{}
-	}
-
-	for k, v := range vals {
-		env[k] = v
-	}
-
-	ac.Definition["env"] = env
+	ac.Definition.SetEnvVariables(vals)
 }
 
 func (ac *AppConfig) SetReleaseCommand(cmd string) {
-	var deploy map[string]string
-
-	if rawDeploy, ok := ac.Definition["deploy"]; ok {
-		if castDeploy, ok := rawDeploy.(map[string]string); ok {
-			deploy = castDeploy
-		}
-	}
-
-	if deploy == nil {
-		deploy = map[string]string{}
-	}
-
-	deploy["release_command"] = cmd
-
-	ac.Definition["deploy"] = deploy
+	ac.Definition.SetReleaseCommand(cmd)
 }
 
 func (ac *AppConfig) SetDockerCommand(cmd string) {
-	var experimental map[string]string
-
-	if rawExperimental, ok := ac.Definition["experimental"]; ok {
-		if castExperimental, ok := rawExperimental.(map[string]string); ok {
-			experimental = castExperimental
-		}
-	}
-
-	if experimental == nil {
-		experimental = map[string]string{}
-	}
-
-	experimental["cmd"] = cmd
-
-	ac.Definition["experimental"] = experimental
+	ac.Definition.SetDockerCommand(cmd)
 }
 
 func (ac *AppConfig) SetKillSignal(signal string) {
-	ac.Definition["kill_signal"] = signal
+	ac.Definition.KillSignal = signal
 }
 
 func (ac *AppConfig) SetDockerEntrypoint(entrypoint string) {
-	var experimental map[string]string
-
-	if rawExperimental, ok := ac.Definition["experimental"]; ok {
-		if castExperimental, ok := rawExperimental.(map[string]string); ok {
-			experimental = castExperimental
-		}
-	}
-
-	if experimental == nil {
-		experimental = map[string]string{}
-	}
-
-	experimental["entrypoint"] = entrypoint
-
-	ac.Definition["experimental"] = experimental
+	ac.Definition.SetDockerEntrypoint(entrypoint)
 }
 
 func (ac *AppConfig) SetEnvVariable(name, value string) {
-	var env map[string]string
-
-	if rawEnv, ok := ac.Definition["env"]; ok {
-		if castEnv, ok := rawEnv.(map[string]string); ok {
-			env = castEnv
-		}
-	}
-
-	if env == nil {
-		env = map[string]string{}
-	}
-
-	env[name] = value
-
-	ac.Definition["env"] = env
+	ac.Definition.SetEnvVariable(name, value)
 }
 
 func (ac *AppConfig) SetProcess(name, value string) {
-	var processes map[string]string
-
-	if rawProcesses, ok := ac.Definition["processes"]; ok {
-		if castProcesses, ok := rawProcesses.(map[string]string); ok {
-			processes = castProcesses
-		}
-	}
-
-	if processes == nil {
-		processes = map[string]string{}
-	}
-
-	processes[name] = value
-
-	ac.Definition["processes"] = processes
+	ac.Definition.SetProcess(name, value)
 }
 
 func (ac *AppConfig) SetStatics(statics []sourcecode.Static) {
-	ac.Definition["statics"] = statics
+	ac.Definition.SetStatics(statics)
 }
 
 func (ac *AppConfig) SetVolumes(volumes []sourcecode.Volume) {
-	ac.Definition["mounts"] = volumes
+	ac.Definition.SetVolumes(volumes)
 }
 
-const defaultConfigFileName = "fly.toml"
-
 func ResolveConfigFileFromPath(p string) (string, error) {
 	p, err := filepath.Abs(p)
 	if err != nil {
@@ -452,21 +345,36 @@ func ResolveConfigFileFromPath(p string) (string, error) {
 	}
 
 	// Ok, something exists. Is it a file - yes? return the path
-	if pd.IsDir() {
-		return path.Join(p, defaultConfigFileName), nil
+	if !pd.IsDir() {
+		return p, nil
 	}
 
-	return p, nil
+	// It's a directory; pick the first config file it contains, in order of preference
+	for _, name := range app.ConfigFileNames {
+		if candidate := path.Join(p, name); helpers.FileExists(candidate) {
+			return candidate, nil
+		}
+	}
+
+	return path.Join(p, app.DefaultConfigFileName), nil
 }
 
 func ConfigFormatFromPath(p string) ConfigFormat {
 	switch path.Ext(p) {
 	case ".toml":
 		return TOMLFormat
+	case ".yaml", ".yml":
+		return YAMLFormat
+	case ".json":
+		return JSONFormat
 	}
 	return UnsupportedFormat
 }
 
+func (f ConfigFormat) appFormat() app.Format {
+	return app.ParseFormat(strings.TrimPrefix(string(f), "."))
+}
+
 func ConfigFileExistsAtPath(p string) (bool, error) {
 	p, err := ResolveConfigFileFromPath(p)
 	if err != nil {
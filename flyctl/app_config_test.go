@@ -1,6 +1,8 @@
 package flyctl
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/BurntSushi/toml"
@@ -52,5 +54,46 @@ func TestLoadTOMLAppConfigWithServices(t *testing.T) {
 	delete(rawData, "build")
 
 	assert.NoError(t, err)
-	assert.Equal(t, p.Definition, rawData)
+	assert.Equal(t, p.Definition.Map(), rawData)
+}
+
+func TestLoadTOMLAppConfigWithStringPorts(t *testing.T) {
+	path := "../example-buildpack/fly.toml"
+	p, err := LoadAppConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 80, p.Definition.Services[0].Ports[0].Port)
+}
+
+func TestLoadTOMLAppConfigWithInvalidFields(t *testing.T) {
+	path := "../internal/app/testdata/invalid.toml"
+	p, err := LoadAppConfig(path)
+	assert.NoError(t, err)
+	assert.Zero(t, p.Definition.Services[0].InternalPort)
+}
+
+func TestLoadYAMLAppConfigWithBuilderNameAndArgs(t *testing.T) {
+	path := "./testdata/build-with-args.yaml"
+	p, err := LoadAppConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, p.AppName, "test-app")
+	assert.Equal(t, p.Build.Builder, "builder/name")
+	assert.Equal(t, p.Build.Args, map[string]string{"A": "B", "C": "D"})
+}
+
+func TestResolveConfigFileFromPathPrefersTOML(t *testing.T) {
+	dir := t.TempDir()
+
+	p, err := ResolveConfigFileFromPath(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "fly.toml"), p)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "fly.json"), []byte("{}"), 0o644))
+	p, err = ResolveConfigFileFromPath(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "fly.json"), p)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "fly.toml"), nil, 0o644))
+	p, err = ResolveConfigFileFromPath(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "fly.toml"), p)
 }
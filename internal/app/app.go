@@ -3,15 +3,12 @@
 package app
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"time"
-
-	"github.com/BurntSushi/toml"
+	"path/filepath"
+	"strings"
 
 	"github.com/superfly/flyctl/helpers"
 	"github.com/superfly/flyctl/internal/sourcecode"
@@ -20,34 +17,141 @@ import (
 // DefaultConfigFileName denotes the default application configuration file name.
 const DefaultConfigFileName = "fly.toml"
 
-// LoadConfig loads the app config at the given path.
-func LoadConfig(path string) (cfg *Config, err error) {
+// LoadConfig loads the app config at the given path. The config may be encoded
+// in any of the supported formats, as implied by the extension of path.
+//
+// The ${VAR} and ${VAR:-default} references the values of the config contain
+// are resolved from the process environment and the .env file next to the
+// config, if any. Configs which set escape_dollars = true at their top level
+// may use $$ for a literal $; in all others, $$ is left as it is. References
+// to unset variables in env, processes, the release command and the
+// experimental cmd and entrypoint are kept for the machines' shell to expand.
+func LoadConfig(path string) (*Config, error) {
+	return LoadConfigWithOptions(path, LoadOptions{})
+}
+
+// LoadOptions wraps the options LoadConfigWithOptions accepts.
+type LoadOptions struct {
+	// Environment selects the overlay to merge onto the config, if any.
+	Environment string
+
+	// EnvFile is the path to the .env-style file variables are resolved from
+	// in addition to the process environment. It defaults to the .env file
+	// next to the config. A missing file is not an error.
+	EnvFile string
+
+	// LookupEnv looks up process environment variables. It defaults to
+	// os.LookupEnv.
+	LookupEnv func(string) (string, bool)
+
+	// Verbatim leaves variable references unresolved, as is required when
+	// the config is to be written back.
+	Verbatim bool
+}
+
+// LoadConfigWithOptions loads the app config at the given path like LoadConfig
+// does, according to the given options.
+func LoadConfigWithOptions(path string, opts LoadOptions) (cfg *Config, err error) {
+	var (
+		raw map[string]interface{}
+		pos positions
+	)
+	if raw, pos, err = readConfigFile(path); err != nil {
+		return
+	}
+
+	var overlay string
+	if opts.Environment != "" {
+		if overlay, err = mergeOverlay(path, opts.Environment, raw, pos); err != nil {
+			return
+		}
+	}
+
 	cfg = &Config{
-		Definition: map[string]interface{}{},
+		Path:        path,
+		Environment: opts.Environment,
+		OverlayPath: overlay,
+		positions:   pos,
 	}
 
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	if opts.Verbatim {
+		err = cfg.unmarshalNativeMap(raw, nil)
+
+		return
 	}
-	defer func() {
-		if e := file.Close(); err == nil {
-			err = e
-		}
-	}()
 
-	cfg.Path = path
-	err = cfg.unmarshalTOML(file)
+	envFile := opts.EnvFile
+	if envFile == "" {
+		envFile = EnvFilePath(path)
+	}
+
+	var vars *variables
+	if vars, err = newVariables(envFile, opts.LookupEnv); err != nil {
+		cfg = nil
+
+		return
+	}
+
+	escapes, _ := raw[escapeDollarsKey].(bool)
+
+	in := &interpolator{
+		vars:    vars,
+		escapes: escapes,
+	}
+	in.interpolateTable("", raw)
+
+	cfg.EnvFilePath = vars.filePath
+	cfg.resolutions = in.resolved
+	err = cfg.unmarshalNativeMap(raw, in)
 
 	return
 }
 
+// readConfigFile returns the untyped representation of the app config file at
+// path along with the positions of its fields.
+func readConfigFile(path string) (raw map[string]interface{}, pos positions, err error) {
+	format := FormatFromPath(path)
+	if format == "" {
+		err = fmt.Errorf("unsupported app config file %s: expected a .toml, .yaml, .yml or .json file", path)
+
+		return
+	}
+
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return
+	}
+
+	return decodeConfig(data, format)
+}
+
 // Config wraps the properties of app configuration.
 type Config struct {
 	AppName    string
 	Build      *Build
-	Definition map[string]interface{}
+	Definition Definition
 	Path       string
+
+	// Notify lists the URLs of the webhooks deployment notifications are
+	// posted to, as the urls of the [notify] section specify them.
+	Notify []string
+
+	// Environment is the name of the environment whose overlay, found at
+	// OverlayPath, has been merged onto the config at Path.
+	Environment string
+	OverlayPath string
+
+	// EnvFilePath is the path to the .env file variables were resolved from,
+	// if any.
+	EnvFilePath string
+
+	// EscapeDollars is set for configs in which $$ stands for a literal $, as
+	// the escape_dollars key at their top level opts in to.
+	EscapeDollars bool
+
+	positions   positions
+	resolutions map[string][]Resolution
+	fieldErrors []*FieldError
 }
 
 type Build struct {
@@ -57,15 +161,38 @@ type Build struct {
 	// Or...
 	Builtin  string
 	Settings map[string]interface{}
+	// BuiltinSource is the file, directory or URL user-defined builtins are
+	// loaded from, in addition to the default ones.
+	BuiltinSource string
 	// Or...
 	Image string
 	// Or...
 	Dockerfile        string
 	DockerBuildTarget string
+	// Strategy names the image builder to build with, instead of the first
+	// one which applies.
+	Strategy string
+}
+
+// Err returns the ValidationError describing the fields of c which failed to
+// load, if any. Those fields hold zero values.
+func (c *Config) Err() error {
+	if len(c.fieldErrors) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Errors: c.fieldErrors}
 }
 
 func (c *Config) HasDefinition() bool {
-	return len(c.Definition) > 0
+	return !c.Definition.IsEmpty()
+}
+
+// Position returns the location the named field, as in
+// services[0].internal_port, is defined at. The returned Position is invalid
+// for configs which weren't read from a file.
+func (c *Config) Position(field string) Position {
+	return c.positions.lookup(field)
 }
 
 func (ac *Config) HasBuilder() bool {
@@ -90,6 +217,21 @@ func (c *Config) Dockerfile() string {
 	return c.Build.Dockerfile
 }
 
+// BuiltinSource returns the source user-defined builtins are loaded from, if
+// any. Paths are relative to the directory of the config.
+func (c *Config) BuiltinSource() string {
+	if c.Build == nil || c.Build.BuiltinSource == "" {
+		return ""
+	}
+
+	src := c.Build.BuiltinSource
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") || filepath.IsAbs(src) || c.Path == "" {
+		return src
+	}
+
+	return filepath.Join(filepath.Dir(c.Path), src)
+}
+
 func (c *Config) DockerBuildTarget() string {
 	if c.Build == nil {
 		return ""
@@ -97,21 +239,52 @@ func (c *Config) DockerBuildTarget() string {
 	return c.Build.DockerBuildTarget
 }
 
+// EncodeTo writes c to w in the format implied by the extension of c.Path,
+// defaulting to TOML.
 func (c *Config) EncodeTo(w io.Writer) error {
-	return c.marshalTOML(w)
+	format := FormatFromPath(c.Path)
+	if format == "" {
+		format = FormatTOML
+	}
+
+	return c.Encode(w, format)
 }
 
-func (c *Config) unmarshalTOML(r io.Reader) (err error) {
-	var data map[string]interface{}
+// Encode writes c to w in the given format.
+func (c *Config) Encode(w io.Writer, format Format) error {
+	return Marshal(w, c.raw(), format)
+}
 
-	if _, err = toml.DecodeReader(r, &data); err == nil {
-		err = c.unmarshalNativeMap(data)
+func (c *Config) unmarshal(data []byte, format Format) (err error) {
+	var raw map[string]interface{}
+	if raw, c.positions, err = decodeConfig(data, format); err != nil {
+		return
+	}
+
+	return c.unmarshalNativeMap(raw, nil)
+}
+
+func decodeConfig(data []byte, format Format) (raw map[string]interface{}, pos positions, err error) {
+	if raw, err = Unmarshal(data, format); err != nil {
+		return
+	}
+
+	if format == FormatTOML {
+		pos = tomlPositions(data)
+	} else {
+		pos = yamlPositions(data)
+	}
+
+	if pos == nil {
+		pos = positions{}
 	}
 
 	return
 }
 
-func (c *Config) unmarshalNativeMap(data map[string]interface{}) error {
+// unmarshalNativeMap decodes the untyped config data. The interpolator in,
+// which may be nil, is the one which resolved the variables of data.
+func (c *Config) unmarshalNativeMap(data map[string]interface{}, in *interpolator) (err error) {
 	if name, ok := (data["app"]).(string); ok {
 		c.AppName = name
 	}
@@ -120,12 +293,56 @@ func (c *Config) unmarshalNativeMap(data map[string]interface{}) error {
 	c.Build = unmarshalBuild(data)
 	delete(data, "build")
 
-	for k := range c.Definition {
-		delete(c.Definition, k)
+	c.Notify = unmarshalNotify(data)
+	delete(data, "notify")
+
+	var (
+		errs   []*FieldError
+		coerce map[string]bool
+	)
+	if in != nil {
+		errs, coerce = in.errs, in.whole
+	}
+
+	if v, ok := data[escapeDollarsKey]; ok {
+		if c.EscapeDollars, ok = v.(bool); !ok {
+			errs = append(errs, &FieldError{
+				Field:   escapeDollarsKey,
+				Message: fmt.Sprintf("expected a boolean, got %s", typeName(v)),
+			})
+		}
+	}
+	delete(data, escapeDollarsKey)
+
+	c.Definition, err = decodeDefinition(data, coerce)
+	if verr, ok := err.(*ValidationError); ok {
+		// fields with unresolved variables don't decode either; don't report them twice
+		for _, fe := range verr.Errors {
+			if !hasField(errs, fe.Field) {
+				errs = append(errs, fe)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		verr := &ValidationError{Errors: errs}
+		c.positions.locate(verr)
+		c.fieldErrors = errs
+
+		err = verr
 	}
-	c.Definition = data
 
-	return nil
+	return
+}
+
+func hasField(errs []*FieldError, field string) bool {
+	for _, fe := range errs {
+		if fe.Field == field {
+			return true
+		}
+	}
+
+	return false
 }
 
 func unmarshalBuild(data map[string]interface{}) *Build {
@@ -158,6 +375,8 @@ func unmarshalBuild(data map[string]interface{}) *Build {
 			}
 		case "builtin":
 			b.Builtin = fmt.Sprint(v)
+		case "builtin_source":
+			b.BuiltinSource = fmt.Sprint(v)
 		case "settings":
 			if settingsMap, ok := v.(map[string]interface{}); ok {
 				for settingK, settingV := range settingsMap {
@@ -170,33 +389,43 @@ func unmarshalBuild(data map[string]interface{}) *Build {
 			b.Dockerfile = fmt.Sprint(v)
 		case "build_target":
 			b.DockerBuildTarget = fmt.Sprint(v)
+		case "strategy":
+			b.Strategy = fmt.Sprint(v)
 		default:
 			b.Args[k] = fmt.Sprint(v)
 		}
 	}
 
-	if b.Builder == "" && b.Builtin == "" && b.Image == "" && b.Dockerfile == "" && len(b.Args) == 0 {
+	if b.Builder == "" && b.Builtin == "" && b.BuiltinSource == "" && b.Image == "" && b.Dockerfile == "" && b.Strategy == "" && len(b.Args) == 0 {
 		return nil
 	}
 
 	return b
 }
 
-func (c *Config) marshalTOML(w io.Writer) error {
-	var b bytes.Buffer
-
-	encoder := toml.NewEncoder(&b)
-	fmt.Fprintf(w, "# fly.toml file generated for %s on %s\n\n", c.AppName, time.Now().Format(time.RFC3339))
-
-	rawData := map[string]interface{}{
-		"app": c.AppName,
+func unmarshalNotify(data map[string]interface{}) (urls []string) {
+	notifyConfig, ok := (data["notify"]).(map[string]interface{})
+	if !ok {
+		return nil
 	}
 
-	if err := encoder.Encode(rawData); err != nil {
-		return err
+	if urlSlice, ok := toSlice(notifyConfig["urls"]); ok {
+		for _, url := range urlSlice {
+			urls = append(urls, fmt.Sprint(url))
+		}
 	}
 
-	rawData = c.Definition
+	return
+}
+
+// raw returns the untyped representation of c.
+func (c *Config) raw() map[string]interface{} {
+	rawData := c.Definition.Map()
+	rawData["app"] = c.AppName
+
+	if c.EscapeDollars {
+		rawData[escapeDollarsKey] = true
+	}
 
 	if c.Build != nil {
 		buildData := map[string]interface{}{}
@@ -215,35 +444,31 @@ func (c *Config) marshalTOML(w io.Writer) error {
 				buildData["settings"] = c.Build.Settings
 			}
 		}
+		if c.Build.BuiltinSource != "" {
+			buildData["builtin_source"] = c.Build.BuiltinSource
+		}
 		if c.Build.Image != "" {
 			buildData["image"] = c.Build.Image
 		}
 		if c.Build.Dockerfile != "" {
 			buildData["dockerfile"] = c.Build.Dockerfile
 		}
-		rawData["build"] = buildData
-	}
-
-	if len(c.Definition) > 0 {
-		// roundtrip through json encoder to convert float64 numbers to json.Number, otherwise numbers are floats in toml
-		var buf bytes.Buffer
-		if err := json.NewEncoder(&buf).Encode(c.Definition); err != nil {
-			return err
+		if c.Build.DockerBuildTarget != "" {
+			buildData["build_target"] = c.Build.DockerBuildTarget
 		}
-
-		d := json.NewDecoder(&buf)
-		d.UseNumber()
-		if err := d.Decode(&c.Definition); err != nil {
-			return err
+		if c.Build.Strategy != "" {
+			buildData["strategy"] = c.Build.Strategy
 		}
+		rawData["build"] = buildData
+	}
 
-		if err := encoder.Encode(c.Definition); err != nil {
-			return err
+	if len(c.Notify) > 0 {
+		rawData["notify"] = map[string]interface{}{
+			"urls": c.Notify,
 		}
 	}
 
-	_, err := b.WriteTo(w)
-	return err
+	return rawData
 }
 
 func (c *Config) WriteToFile(filename string) (err error) {
@@ -261,174 +486,68 @@ func (c *Config) WriteToFile(filename string) (err error) {
 		}
 	}()
 
-	err = c.EncodeTo(file)
+	format := FormatFromPath(filename)
+	if format == "" {
+		format = FormatTOML
+	}
+	err = c.Encode(file, format)
 
 	return
 }
 
 // HasServices - Does this config have a services section
 func (c *Config) HasServices() bool {
-	_, ok := c.Definition["services"].([]interface{})
-
-	return ok
+	return len(c.Definition.Services) > 0
 }
 
 func (c *Config) SetInternalPort(port int) bool {
-	services, ok := c.Definition["services"].([]interface{})
-	if !ok {
-		return false
-	}
-
-	if len(services) == 0 {
-		return false
-	}
-
-	if service, ok := services[0].(map[string]interface{}); ok {
-		service["internal_port"] = port
-
-		return true
-	}
-
-	return false
+	return c.Definition.SetInternalPort(port)
 }
 
 func (c *Config) InternalPort() (int, error) {
-	tmpservices, ok := c.Definition["services"]
-	if !ok {
+	if len(c.Definition.Services) == 0 {
 		return -1, errors.New("could not find internal port setting")
 	}
 
-	services, ok := tmpservices.([]map[string]interface{})
-	if ok {
-		internalport, ok := services[0]["internal_port"].(int64)
-		if ok {
-			return int(internalport), nil
-		}
-		internalportfloat, ok := services[0]["internal_port"].(float64)
-		if ok {
-			return int(internalportfloat), nil
-		}
+	if port := c.Definition.Services[0].InternalPort; port > 0 {
+		return port, nil
 	}
+
 	return 8080, nil
 }
 
 func (c *Config) SetEnvVariables(vals map[string]string) {
-	var env map[string]string
-
-	if rawEnv, ok := c.Definition["env"]; ok {
-		if castEnv, ok := rawEnv.(map[string]string); ok {
-			env = castEnv
-		}
-	}
-	if env == nil {
-		env = map[string]string{}
-	}
-
-	for k, v := range vals {
-		env[k] = v
-	}
-
-	c.Definition["env"] = env
+	c.Definition.SetEnvVariables(vals)
 }
 
 func (c *Config) SetReleaseCommand(cmd string) {
-	var deploy map[string]string
-
-	if rawDeploy, ok := c.Definition["deploy"]; ok {
-		if castDeploy, ok := rawDeploy.(map[string]string); ok {
-			deploy = castDeploy
-		}
-	}
-
-	if deploy == nil {
-		deploy = map[string]string{}
-	}
-
-	deploy["release_command"] = cmd
-
-	c.Definition["deploy"] = deploy
+	c.Definition.SetReleaseCommand(cmd)
 }
 
 func (c *Config) SetDockerCommand(cmd string) {
-	var experimental map[string]string
-
-	if rawExperimental, ok := c.Definition["experimental"]; ok {
-		if castExperimental, ok := rawExperimental.(map[string]string); ok {
-			experimental = castExperimental
-		}
-	}
-
-	if experimental == nil {
-		experimental = map[string]string{}
-	}
-
-	experimental["cmd"] = cmd
-
-	c.Definition["experimental"] = experimental
+	c.Definition.SetDockerCommand(cmd)
 }
 
 func (c *Config) SetKillSignal(signal string) {
-	c.Definition["kill_signal"] = signal
+	c.Definition.KillSignal = signal
 }
 
 func (c *Config) SetDockerEntrypoint(entrypoint string) {
-	var experimental map[string]string
-
-	if rawExperimental, ok := c.Definition["experimental"]; ok {
-		if castExperimental, ok := rawExperimental.(map[string]string); ok {
-			experimental = castExperimental
-		}
-	}
-
-	if experimental == nil {
-		experimental = map[string]string{}
-	}
-
-	experimental["entrypoint"] = entrypoint
-
-	c.Definition["experimental"] = experimental
+	c.Definition.SetDockerEntrypoint(entrypoint)
 }
 
 func (c *Config) SetEnvVariable(name, value string) {
-	var env map[string]string
-
-	if rawEnv, ok := c.Definition["env"]; ok {
-		if castEnv, ok := rawEnv.(map[string]string); ok {
-			env = castEnv
-		}
-	}
-
-	if env == nil {
-		env = map[string]string{}
-	}
-
-	env[name] = value
-
-	c.Definition["env"] = env
+	c.Definition.SetEnvVariable(name, value)
 }
 
 func (c *Config) SetProcess(name, value string) {
-	var processes map[string]string
-
-	if rawProcesses, ok := c.Definition["processes"]; ok {
-		if castProcesses, ok := rawProcesses.(map[string]string); ok {
-			processes = castProcesses
-		}
-	}
-
-	if processes == nil {
-		processes = map[string]string{}
-	}
-
-	processes[name] = value
-
-	c.Definition["processes"] = processes
+	c.Definition.SetProcess(name, value)
 }
 
 func (c *Config) SetStatics(statics []sourcecode.Static) {
-	c.Definition["statics"] = statics
+	c.Definition.SetStatics(statics)
 }
 
 func (c *Config) SetVolumes(volumes []sourcecode.Volume) {
-	c.Definition["mounts"] = volumes
+	c.Definition.SetVolumes(volumes)
 }
@@ -0,0 +1,519 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// rawDecoder is implemented by types which decode themselves from the untyped
+// value found in a config file.
+type rawDecoder interface {
+	decodeRaw(interface{}) error
+}
+
+// rawEncoder is implemented by types which encode themselves into the untyped
+// value written to a config file. A nil result omits the value.
+type rawEncoder interface {
+	encodeRaw() interface{}
+}
+
+// decodeHook is implemented by structs which need to inspect the raw table
+// they were decoded from.
+type decodeHook interface {
+	afterDecode(map[string]interface{})
+}
+
+// encodeHook is implemented by structs which need to adjust the raw table
+// they were encoded into.
+type encodeHook interface {
+	beforeEncode(map[string]interface{})
+}
+
+// rawFields keeps the raw values of a table which their typed fields wouldn't
+// encode back the way they were read: the ones which failed to decode, the
+// scalars which decoded to zero values, which encoding omits otherwise, and
+// values of string maps which weren't strings,
+// such as the 1.5 of env = { A = 1.5 }. The structs of the model embed it.
+// Values of string maps are kept by the name of the map and their key, joined
+// by a dot.
+type rawFields map[string]interface{}
+
+func (r *rawFields) keepRaw(key string, raw interface{}) {
+	if *r == nil {
+		*r = rawFields{}
+	}
+
+	(*r)[key] = raw
+}
+
+func (r rawFields) rawField(key string) (interface{}, bool) {
+	raw, ok := r[key]
+
+	return raw, ok
+}
+
+func (r *rawFields) clearRaw() {
+	*r = nil
+}
+
+// rawKeeper is implemented by pointers to the structs embedding rawFields.
+type rawKeeper interface {
+	keepRaw(key string, raw interface{})
+}
+
+// rawSource is implemented by the structs embedding rawFields.
+type rawSource interface {
+	rawField(key string) (interface{}, bool)
+}
+
+// decoder decodes untyped config values into the typed model. Rather than
+// stopping at the first invalid field, it collects an error for each one.
+type decoder struct {
+	errs []*FieldError
+
+	// coerce is the set of paths of the fields whose string values may be
+	// parsed into booleans, as is the case for the ones which consisted of a
+	// single variable reference. Numeric strings, like port = "80", which the
+	// API accepts, are parsed into integers regardless.
+	coerce map[string]bool
+}
+
+func (d *decoder) fail(path, format string, v ...interface{}) {
+	d.errs = append(d.errs, &FieldError{
+		Field:   path,
+		Message: fmt.Sprintf(format, v...),
+	})
+}
+
+func (d *decoder) decode(path string, raw interface{}, dst interface{}) {
+	d.decodeValue(path, raw, reflect.ValueOf(dst).Elem())
+}
+
+func (d *decoder) decodeValue(path string, raw interface{}, v reflect.Value) {
+	if raw == nil {
+		return
+	}
+
+	if rd, ok := v.Addr().Interface().(rawDecoder); ok {
+		if err := rd.decodeRaw(raw); err != nil {
+			d.fail(path, "%v", err)
+		}
+
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		d.decodeValue(path, raw, elem.Elem())
+		v.Set(elem)
+	case reflect.Struct:
+		if m, ok := toMap(raw); ok {
+			d.decodeStruct(path, m, v)
+		} else {
+			d.fail(path, "expected a table, got %s", typeName(raw))
+		}
+	case reflect.Slice:
+		d.decodeSlice(path, raw, v)
+	case reflect.Map:
+		d.decodeMap(path, raw, v)
+	case reflect.String:
+		if s, ok := raw.(string); ok {
+			v.SetString(s)
+		} else {
+			d.fail(path, "expected a string, got %s", typeName(raw))
+		}
+	case reflect.Int:
+		if s, ok := raw.(string); ok {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				raw = n
+			}
+		}
+
+		if n, ok := toInt64(raw); ok {
+			v.SetInt(n)
+		} else {
+			d.fail(path, "expected an integer, got %s", typeName(raw))
+		}
+	case reflect.Bool:
+		if s, ok := raw.(string); ok && d.coerce[path] {
+			if b, err := strconv.ParseBool(s); err == nil {
+				raw = b
+			}
+		}
+
+		if b, ok := raw.(bool); ok {
+			v.SetBool(b)
+		} else {
+			d.fail(path, "expected a boolean, got %s", typeName(raw))
+		}
+	default:
+		panic(fmt.Sprintf("app: can't decode into %s", v.Type()))
+	}
+}
+
+func (d *decoder) decodeStruct(path string, m map[string]interface{}, v reflect.Value) {
+	t := v.Type()
+
+	known := make(map[string]bool, t.NumField())
+
+	var extra reflect.Value
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		switch name := f.Tag.Get("toml"); {
+		case f.PkgPath != "":
+			continue // unexported
+		case f.Name == "Extra":
+			extra = v.Field(i)
+		case name != "" && name != "-":
+			known[name] = true
+
+			if raw, ok := m[name]; ok {
+				fieldPath := joinPath(path, name)
+
+				errs := len(d.errs)
+				d.decodeValue(fieldPath, raw, v.Field(i))
+				failed := d.failed(fieldPath, errs)
+
+				if keeper, ok := v.Addr().Interface().(rawKeeper); ok {
+					if failed {
+						// keep what failed to decode, such as unresolved
+						// variable references, as it was
+						v.Field(i).Set(reflect.Zero(f.Type))
+					}
+
+					keepRaw(keeper, name, raw, v.Field(i), failed)
+				}
+			}
+		}
+	}
+
+	if extra.IsValid() {
+		for k, raw := range m {
+			if known[k] {
+				continue
+			}
+
+			if extra.IsNil() {
+				extra.Set(reflect.MakeMap(extra.Type()))
+			}
+			extra.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(raw))
+		}
+	}
+
+	if hook, ok := v.Addr().Interface().(decodeHook); ok {
+		hook.afterDecode(m)
+	}
+}
+
+// failed reports whether decoding the field at path failed, judging by the
+// errors recorded after the first errs ones.
+func (d *decoder) failed(path string, errs int) bool {
+	for _, fe := range d.errs[errs:] {
+		if fe.Field == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keepRaw keeps the parts of raw, which the field name of keeper was decoded
+// from into f, that f wouldn't encode back. Fields which failed to decode are
+// kept as a whole, as are the integers which were written as strings.
+func keepRaw(keeper rawKeeper, name string, raw interface{}, f reflect.Value, failed bool) {
+	switch kind := f.Kind(); {
+	case failed:
+		keeper.keepRaw(name, raw)
+	case kind == reflect.Int:
+		if _, ok := raw.(string); ok || f.IsZero() {
+			keeper.keepRaw(name, raw)
+		}
+	case kind == reflect.String, kind == reflect.Bool:
+		if f.IsZero() {
+			keeper.keepRaw(name, raw)
+		}
+	case kind == reflect.Map:
+		m, _ := toMap(raw)
+		for k, e := range m {
+			if _, ok := e.(string); !ok {
+				keeper.keepRaw(name+"."+k, e)
+			}
+		}
+	}
+}
+
+func (d *decoder) decodeSlice(path string, raw interface{}, v reflect.Value) {
+	items, ok := toSlice(raw)
+	if !ok {
+		if m, isMap := raw.(map[string]interface{}); isMap && v.Type().Elem().Kind() == reflect.Struct {
+			// a single table stands in for an array of one
+			items, ok = []interface{}{m}, true
+		}
+	}
+
+	if !ok {
+		d.fail(path, "expected an array, got %s", typeName(raw))
+
+		return
+	}
+
+	s := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, item := range items {
+		d.decodeValue(fmt.Sprintf("%s[%d]", path, i), item, s.Index(i))
+	}
+	v.Set(s)
+}
+
+func (d *decoder) decodeMap(path string, raw interface{}, v reflect.Value) {
+	m, ok := toMap(raw)
+	if !ok {
+		d.fail(path, "expected a table, got %s", typeName(raw))
+
+		return
+	}
+
+	if v.Type().Elem().Kind() != reflect.String {
+		panic(fmt.Sprintf("app: can't decode into %s", v.Type()))
+	}
+
+	out := reflect.MakeMapWithSize(v.Type(), len(m))
+	for k, e := range m {
+		switch e.(type) {
+		case map[string]interface{}, []interface{}, []map[string]interface{}:
+			d.fail(joinPath(path, k), "expected a scalar, got %s", typeName(e))
+		default:
+			out.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(scalarString(e)))
+		}
+	}
+	v.Set(out)
+}
+
+// encode returns the untyped representation of v, which should be a pointer
+// to one of the model's structs.
+func encode(v interface{}) interface{} {
+	return encodeValue(reflect.ValueOf(v), false)
+}
+
+// encodeValue returns the untyped representation of v. Zero values result to
+// nil, unless keepZero is set, which is the case for values pointers point to.
+func encodeValue(v reflect.Value, keepZero bool) interface{} {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+
+		return encodeValue(v.Elem(), true)
+	}
+
+	if re, ok := v.Interface().(rawEncoder); ok {
+		return re.encodeRaw()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return encodeStruct(v)
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil
+		}
+
+		if v.Type().Elem().Kind() == reflect.Struct {
+			out := make([]map[string]interface{}, v.Len())
+			for i := range out {
+				out[i] = encodeStruct(v.Index(i))
+			}
+
+			return out
+		}
+
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(out, v)
+
+		return out.Interface()
+	case reflect.Map:
+		if v.Len() == 0 {
+			return nil
+		}
+
+		out := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			out[k.String()] = v.MapIndex(k).Interface()
+		}
+
+		return out
+	case reflect.String:
+		if s := v.String(); s != "" || keepZero {
+			return s
+		}
+	case reflect.Int:
+		if n := v.Int(); n != 0 || keepZero {
+			return n
+		}
+	case reflect.Bool:
+		if b := v.Bool(); b || keepZero {
+			return b
+		}
+	default:
+		panic(fmt.Sprintf("app: can't encode %s", v.Type()))
+	}
+
+	return nil
+}
+
+func encodeStruct(v reflect.Value) map[string]interface{} {
+	t := v.Type()
+	m := make(map[string]interface{}, t.NumField())
+
+	var extra reflect.Value
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		switch name := f.Tag.Get("toml"); {
+		case f.PkgPath != "":
+			continue // unexported
+		case f.Name == "Extra":
+			extra = v.Field(i)
+		case name != "" && name != "-":
+			e := encodeValue(v.Field(i), false)
+			if src, ok := v.Interface().(rawSource); ok {
+				e = restoreRaw(src, name, v.Field(i), e)
+			}
+
+			if e != nil {
+				m[name] = e
+			}
+		}
+	}
+
+	if extra.IsValid() {
+		for _, k := range extra.MapKeys() {
+			if _, exists := m[k.String()]; !exists {
+				m[k.String()] = extra.MapIndex(k).Interface()
+			}
+		}
+	}
+
+	if v.CanAddr() {
+		if hook, ok := v.Addr().Interface().(encodeHook); ok {
+			hook.beforeEncode(m)
+		}
+	}
+
+	return m
+}
+
+// restoreRaw returns e, the encoded field name of src, with the raw values src
+// kept for it restored, in case f, the field, still holds what they decoded
+// into.
+func restoreRaw(src rawSource, name string, f reflect.Value, e interface{}) interface{} {
+	if raw, ok := src.rawField(name); ok {
+		switch {
+		case e == nil:
+			return raw
+		case f.Kind() == reflect.Int && scalarString(raw) == scalarString(e):
+			return raw
+		}
+	}
+
+	if m, ok := e.(map[string]interface{}); ok && f.Kind() == reflect.Map {
+		for k, s := range m {
+			if raw, ok := src.rawField(name + "." + k); ok && scalarString(raw) == s {
+				m[k] = raw
+			}
+		}
+	}
+
+	return e
+}
+
+// scalarString returns the string representation of the scalar v. Unlike
+// fmt.Sprint, it writes floats without exponents.
+func scalarString(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toMap(v interface{}) (map[string]interface{}, bool) {
+	m, ok := v.(map[string]interface{})
+
+	return m, ok
+}
+
+func toSlice(v interface{}) ([]interface{}, bool) {
+	switch v := v.(type) {
+	case []interface{}:
+		return v, true
+	case []map[string]interface{}:
+		s := make([]interface{}, len(v))
+		for i := range v {
+			s[i] = v[i]
+		}
+		return s, true
+	case []string:
+		s := make([]interface{}, len(v))
+		for i := range v {
+			s[i] = v[i]
+		}
+		return s, true
+	default:
+		return nil, false
+	}
+}
+
+// toInt64 converts the numeric types the TOML and JSON decoders produce to an
+// int64. Floats qualify only when they carry no fractional part, as is the
+// case with integers the API returns.
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		if v == math.Trunc(v) {
+			return int64(v), true
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, true
+		}
+	}
+
+	return 0, false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case int, int64:
+		return "integer"
+	case float64:
+		return "float"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "table"
+	case []interface{}, []map[string]interface{}, []string:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func joinPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+
+	return parent + "." + key
+}
@@ -1,10 +1,15 @@
 package app
 
 import (
+	"bytes"
+	"errors"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLoadTOMLAppConfigWithAppName(t *testing.T) {
@@ -39,6 +44,26 @@ func TestLoadTOMLAppConfigWithDockerfile(t *testing.T) {
 	assert.Equal(t, p.Build.Dockerfile, "./Dockerfile")
 }
 
+func TestLoadTOMLAppConfigWithBuildStrategy(t *testing.T) {
+	const path = "./testdata/build-strategy.toml"
+
+	p, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, p.Build.Strategy, "dockerfile")
+	assert.Empty(t, p.Build.Args)
+}
+
+func TestLoadTOMLAppConfigWithBuiltinSource(t *testing.T) {
+	const path = "./testdata/builtin-source.toml"
+
+	p, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, p.Build.Builtin, "rails")
+	assert.Equal(t, p.Build.BuiltinSource, "builtins/rails.toml")
+	assert.Equal(t, p.BuiltinSource(), filepath.Join("testdata", "builtins", "rails.toml"))
+	assert.Empty(t, p.Build.Args)
+}
+
 func TestLoadTOMLAppConfigWithBuilderNameAndArgs(t *testing.T) {
 	const path = "./testdata/build-with-args.toml"
 
@@ -57,5 +82,198 @@ func TestLoadTOMLAppConfigWithServices(t *testing.T) {
 	delete(rawData, "build")
 
 	assert.NoError(t, err)
-	assert.Equal(t, p.Definition, rawData)
+	assert.Equal(t, p.Definition.Map(), rawData)
+}
+
+func TestLoadTOMLAppConfigTyped(t *testing.T) {
+	const path = "./testdata/full.toml"
+
+	p, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	def := p.Definition
+	assert.Equal(t, "SIGINT", def.KillSignal)
+	assert.Equal(t, 5, *def.KillTimeout)
+	assert.Equal(t, map[string]string{"PORT": "8080"}, def.Env)
+	assert.Equal(t, "bin/sidekiq", def.Processes["worker"])
+	assert.Equal(t, []string{"bin/start", "--verbose"}, def.Experimental.Cmd.Values)
+	assert.False(t, *def.Experimental.AutoRollback)
+	assert.Equal(t, "bin/rails db:migrate", def.Deploy.ReleaseCommand)
+	assert.Equal(t, []Mount{{Source: "data", Destination: "/data"}}, def.Mounts)
+	assert.Equal(t, "/public", def.Statics[0].URLPrefix)
+
+	require.Len(t, def.Services, 1)
+	svc := def.Services[0]
+	assert.Equal(t, 8080, svc.InternalPort)
+	assert.Equal(t, 25, svc.Concurrency.HardLimit)
+	assert.Equal(t, []Port{
+		{Port: 80, Handlers: []string{"http"}, ForceHTTPS: true},
+		{Port: 443, Handlers: []string{"tls", "http"}},
+	}, svc.Ports)
+	assert.Equal(t, 15*time.Second, svc.TCPChecks[0].Interval.Duration)
+	assert.Equal(t, 2*time.Second, svc.TCPChecks[0].Timeout.Duration)
+	assert.Equal(t, 10*time.Second, svc.HTTPChecks[0].Interval.Duration)
+	assert.Equal(t, "example.com", svc.HTTPChecks[0].Headers["Host"])
+
+	assert.Contains(t, svc.Extra, "script_checks")
+	assert.Contains(t, def.Extra, "metrics")
+}
+
+func TestTOMLAppConfigRoundTrip(t *testing.T) {
+	const path = "./testdata/full.toml"
+
+	p, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, p.EncodeTo(&b))
+
+	var got Config
+	require.NoError(t, got.unmarshal(b.Bytes(), FormatTOML))
+
+	rawData := map[string]interface{}{}
+	_, err = toml.DecodeFile(path, &rawData)
+	require.NoError(t, err)
+	delete(rawData, "app")
+
+	var written map[string]interface{}
+	_, err = toml.Decode(b.String(), &written)
+	require.NoError(t, err)
+	delete(written, "app")
+
+	assert.Equal(t, rawData, written)
+	assert.Equal(t, p.Definition.Map(), got.Definition.Map())
+}
+
+func TestTOMLAppConfigRoundTripKeepsRawScalars(t *testing.T) {
+	const path = "./testdata/zero-values.toml"
+
+	p, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	// env values are strings, the way scalars are written in the config
+	assert.Equal(t, map[string]string{"A": "1000000", "B": "true", "C": "42", "D": "text"}, p.Definition.Env)
+
+	var b bytes.Buffer
+	require.NoError(t, p.EncodeTo(&b))
+
+	rawData := map[string]interface{}{}
+	_, err = toml.DecodeFile(path, &rawData)
+	require.NoError(t, err)
+
+	var written map[string]interface{}
+	_, err = toml.Decode(b.String(), &written)
+	require.NoError(t, err)
+
+	assert.Equal(t, rawData, written)
+
+	// values set after loading replace the kept ones
+	p.Definition.Services[0].Concurrency.HardLimit = 25
+	p.Definition.Services[0].Ports[0].ForceHTTPS = true
+	p.SetEnvVariable("A", "1")
+
+	m := p.Definition.Map()
+	svc := m["services"].([]map[string]interface{})[0]
+	assert.Equal(t, int64(25), svc["concurrency"].(map[string]interface{})["hard_limit"])
+	assert.Equal(t, true, svc["ports"].([]map[string]interface{})[0]["force_https"])
+	assert.Equal(t, map[string]interface{}{"A": "1", "B": true, "C": int64(42), "D": "text"}, m["env"])
+}
+
+func TestLoadTOMLAppConfigWithStringPorts(t *testing.T) {
+	const path = "../../example-buildpack/fly.toml"
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	ports := cfg.Definition.Services[0].Ports
+	require.Len(t, ports, 2)
+	assert.Equal(t, 80, ports[0].Port)
+	assert.Equal(t, 443, ports[1].Port)
+
+	// the strings are written back as they were, unless changed
+	ports[1].Port = 8443
+
+	m := cfg.Definition.Map()
+	written := m["services"].([]map[string]interface{})[0]["ports"].([]map[string]interface{})
+	assert.Equal(t, "80", written[0]["port"])
+	assert.Equal(t, int64(8443), written[1]["port"])
+}
+
+func TestLoadTOMLAppConfigWithInvalidFields(t *testing.T) {
+	const path = "./testdata/invalid.toml"
+
+	cfg, err := LoadConfig(path)
+
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Errors, 3)
+
+	// the config loads nonetheless, with the invalid fields left out
+	require.NotNil(t, cfg)
+	assert.Equal(t, err, cfg.Err())
+	assert.Zero(t, cfg.Definition.Services[0].InternalPort)
+
+	byField := map[string]*FieldError{}
+	for _, fe := range verr.Errors {
+		byField[fe.Field] = fe
+	}
+
+	assert.Equal(t, Position{Line: 4, Column: 3}, byField["services[0].internal_port"].Position)
+	assert.Equal(t, Position{Line: 8, Column: 5}, byField["services[0].ports[0].handlers"].Position)
+	assert.Equal(t, Position{Line: 12, Column: 5}, byField["services[0].tcp_checks[0].interval"].Position)
+}
+
+func TestDefinitionFromMap(t *testing.T) {
+	// numbers the API returns are decoded as floats
+	def, err := DefinitionFromMap(map[string]interface{}{
+		"kill_timeout": float64(5),
+		"services": []interface{}{
+			map[string]interface{}{
+				"internal_port": float64(8080),
+				"ports": []interface{}{
+					map[string]interface{}{"port": float64(80)},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, *def.KillTimeout)
+	assert.Equal(t, 8080, def.Services[0].InternalPort)
+	assert.Equal(t, 80, def.Services[0].Ports[0].Port)
+
+	// as are integers written as strings
+	def, err = DefinitionFromMap(map[string]interface{}{
+		"services": []interface{}{
+			map[string]interface{}{
+				"ports": []interface{}{
+					map[string]interface{}{"port": "443"},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 443, def.Services[0].Ports[0].Port)
+	assert.Equal(t, "443", def.Map()["services"].([]map[string]interface{})[0]["ports"].([]map[string]interface{})[0]["port"])
+
+	// fields which fail to decode are kept as they were
+	def, err = DefinitionFromMap(map[string]interface{}{
+		"kill_timeout": 5.5,
+	})
+	assert.EqualError(t, err, "invalid app config: kill_timeout: expected an integer, got float")
+	assert.Nil(t, def.KillTimeout)
+	assert.Equal(t, map[string]interface{}{"kill_timeout": 5.5}, def.Map())
+}
+
+func TestSetEnvVariablesKeepsExistingEnv(t *testing.T) {
+	const path = "./testdata/full.toml"
+
+	p, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	p.SetEnvVariables(map[string]string{"LOG_LEVEL": "debug"})
+	p.SetReleaseCommand("bin/release")
+
+	assert.Equal(t, map[string]string{"PORT": "8080", "LOG_LEVEL": "debug"}, p.Definition.Env)
+	assert.Equal(t, "bin/release", p.Definition.Deploy.ReleaseCommand)
 }
@@ -0,0 +1,391 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/superfly/flyctl/internal/sourcecode"
+)
+
+// Definition is the typed representation of everything an app config file
+// carries besides the app name and the build section. It is what gets sent to
+// the API when deploying.
+//
+// Keys this version of flyctl doesn't know about are kept in the Extra maps of
+// the struct they were found in, and explicitly set zero values and non-string
+// env values in the raw form they were read in, so that reading and then
+// writing a config file doesn't drop or alter any data.
+type Definition struct {
+	KillSignal   string            `toml:"kill_signal"`
+	KillTimeout  *int              `toml:"kill_timeout"`
+	Env          map[string]string `toml:"env"`
+	Processes    map[string]string `toml:"processes"`
+	Experimental *Experimental     `toml:"experimental"`
+	Deploy       *Deploy           `toml:"deploy"`
+	Services     []Service         `toml:"services"`
+	Mounts       []Mount           `toml:"mounts"`
+	Statics      []Static          `toml:"statics"`
+
+	Extra map[string]interface{} `toml:"-"`
+	rawFields
+
+	// mountsTable is set when mounts were declared as a single [mounts] table
+	// instead of an array of tables.
+	mountsTable bool
+}
+
+// Experimental wraps the [experimental] section of an app config.
+type Experimental struct {
+	Cmd            StringList `toml:"cmd"`
+	Entrypoint     StringList `toml:"entrypoint"`
+	AutoRollback   *bool      `toml:"auto_rollback"`
+	PrivateNetwork *bool      `toml:"private_network"`
+
+	Extra map[string]interface{} `toml:"-"`
+	rawFields
+}
+
+// Deploy wraps the [deploy] section of an app config.
+type Deploy struct {
+	ReleaseCommand string `toml:"release_command"`
+	Strategy       string `toml:"strategy"`
+
+	Extra map[string]interface{} `toml:"-"`
+	rawFields
+}
+
+// Service wraps a [[services]] section of an app config.
+type Service struct {
+	InternalPort int          `toml:"internal_port"`
+	Protocol     string       `toml:"protocol"`
+	Processes    []string     `toml:"processes"`
+	Concurrency  *Concurrency `toml:"concurrency"`
+	Ports        []Port       `toml:"ports"`
+	TCPChecks    []TCPCheck   `toml:"tcp_checks"`
+	HTTPChecks   []HTTPCheck  `toml:"http_checks"`
+
+	Extra map[string]interface{} `toml:"-"`
+	rawFields
+}
+
+// Concurrency wraps the [services.concurrency] section of an app config.
+type Concurrency struct {
+	Type      string `toml:"type"`
+	HardLimit int    `toml:"hard_limit"`
+	SoftLimit int    `toml:"soft_limit"`
+
+	Extra map[string]interface{} `toml:"-"`
+	rawFields
+}
+
+// Port wraps a [[services.ports]] section of an app config.
+type Port struct {
+	Port       int      `toml:"port"`
+	Handlers   []string `toml:"handlers"`
+	ForceHTTPS bool     `toml:"force_https"`
+
+	Extra map[string]interface{} `toml:"-"`
+	rawFields
+}
+
+// TCPCheck wraps a [[services.tcp_checks]] section of an app config.
+type TCPCheck struct {
+	Interval     Duration `toml:"interval"`
+	Timeout      Duration `toml:"timeout"`
+	GracePeriod  Duration `toml:"grace_period"`
+	RestartLimit *int     `toml:"restart_limit"`
+
+	Extra map[string]interface{} `toml:"-"`
+	rawFields
+}
+
+// HTTPCheck wraps a [[services.http_checks]] section of an app config.
+type HTTPCheck struct {
+	Interval      Duration          `toml:"interval"`
+	Timeout       Duration          `toml:"timeout"`
+	GracePeriod   Duration          `toml:"grace_period"`
+	RestartLimit  *int              `toml:"restart_limit"`
+	Method        string            `toml:"method"`
+	Path          string            `toml:"path"`
+	Protocol      string            `toml:"protocol"`
+	TLSSkipVerify bool              `toml:"tls_skip_verify"`
+	Headers       map[string]string `toml:"headers"`
+
+	Extra map[string]interface{} `toml:"-"`
+	rawFields
+}
+
+// Mount wraps a [mounts] section of an app config.
+type Mount struct {
+	Source      string   `toml:"source"`
+	Destination string   `toml:"destination"`
+	Processes   []string `toml:"processes"`
+
+	Extra map[string]interface{} `toml:"-"`
+	rawFields
+}
+
+// Static wraps a [[statics]] section of an app config.
+type Static struct {
+	GuestPath string `toml:"guest_path"`
+	URLPrefix string `toml:"url_prefix"`
+
+	Extra map[string]interface{} `toml:"-"`
+	rawFields
+}
+
+// DefinitionFromMap decodes the untyped definition m, such as the one the API
+// returns, into its typed counterpart. It does not modify m. As JSON doesn't
+// tell integers and floats apart, the floats of m which carry no fractional
+// part are taken for integers.
+//
+// Fields which fail to decode are reported via a *ValidationError and kept as
+// they were, so that the definition returned along with the error encodes back
+// into m.
+func DefinitionFromMap(m map[string]interface{}) (Definition, error) {
+	m, _ = integers(m).(map[string]interface{})
+
+	return decodeDefinition(m, nil)
+}
+
+// integers returns a copy of v in which the floats carrying no fractional part
+// are converted to integers.
+func integers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = integers(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = integers(e)
+		}
+		return s
+	case float64:
+		if n, ok := toInt64(v); ok {
+			return n
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func decodeDefinition(m map[string]interface{}, coerce map[string]bool) (def Definition, err error) {
+	d := &decoder{
+		coerce: coerce,
+	}
+	d.decode("", m, &def)
+
+	if len(d.errs) > 0 {
+		err = &ValidationError{Errors: d.errs}
+	}
+
+	return
+}
+
+// Map returns the untyped representation of d, which is what the API expects.
+func (d *Definition) Map() map[string]interface{} {
+	m, _ := encode(d).(map[string]interface{})
+	if m == nil {
+		m = map[string]interface{}{}
+	}
+
+	return m
+}
+
+// IsEmpty reports whether d defines nothing.
+func (d *Definition) IsEmpty() bool {
+	return len(d.Map()) == 0
+}
+
+func (d *Definition) afterDecode(raw map[string]interface{}) {
+	_, d.mountsTable = raw["mounts"].(map[string]interface{})
+}
+
+func (d *Definition) beforeEncode(m map[string]interface{}) {
+	if mounts, ok := m["mounts"].([]map[string]interface{}); ok && d.mountsTable && len(mounts) == 1 {
+		m["mounts"] = mounts[0]
+	}
+}
+
+// SetInternalPort sets the internal port of the first service. It reports
+// whether there was a service to set it on.
+func (d *Definition) SetInternalPort(port int) bool {
+	if len(d.Services) == 0 {
+		return false
+	}
+
+	d.Services[0].InternalPort = port
+
+	return true
+}
+
+// SetEnvVariables merges vals into the environment.
+func (d *Definition) SetEnvVariables(vals map[string]string) {
+	for k, v := range vals {
+		d.SetEnvVariable(k, v)
+	}
+}
+
+// SetEnvVariable sets the named environment variable to value.
+func (d *Definition) SetEnvVariable(name, value string) {
+	if d.Env == nil {
+		d.Env = map[string]string{}
+	}
+
+	d.Env[name] = value
+}
+
+// SetProcess sets the command of the named process group.
+func (d *Definition) SetProcess(name, value string) {
+	if d.Processes == nil {
+		d.Processes = map[string]string{}
+	}
+
+	d.Processes[name] = value
+}
+
+// SetReleaseCommand sets the command to run before a release goes live.
+func (d *Definition) SetReleaseCommand(cmd string) {
+	if d.Deploy == nil {
+		d.Deploy = new(Deploy)
+	}
+
+	d.Deploy.ReleaseCommand = cmd
+}
+
+// SetDockerCommand overrides the CMD of the image.
+func (d *Definition) SetDockerCommand(cmd string) {
+	if d.Experimental == nil {
+		d.Experimental = new(Experimental)
+	}
+
+	d.Experimental.Cmd = NewStringList(cmd)
+}
+
+// SetDockerEntrypoint overrides the ENTRYPOINT of the image.
+func (d *Definition) SetDockerEntrypoint(entrypoint string) {
+	if d.Experimental == nil {
+		d.Experimental = new(Experimental)
+	}
+
+	d.Experimental.Entrypoint = NewStringList(entrypoint)
+}
+
+// SetStatics replaces the statics with the ones given.
+func (d *Definition) SetStatics(statics []sourcecode.Static) {
+	d.Statics = make([]Static, len(statics))
+	for i, s := range statics {
+		d.Statics[i] = Static{
+			GuestPath: s.GuestPath,
+			URLPrefix: s.UrlPrefix,
+		}
+	}
+}
+
+// SetVolumes replaces the mounts with the given volumes.
+func (d *Definition) SetVolumes(volumes []sourcecode.Volume) {
+	d.Mounts = make([]Mount, len(volumes))
+	for i, v := range volumes {
+		d.Mounts[i] = Mount{
+			Source:      v.Source,
+			Destination: v.Destination,
+		}
+	}
+}
+
+// StringList is a value fly.toml accepts either as a single string or as an
+// array of strings. The form it was read in is the form it's written back in.
+type StringList struct {
+	Values []string
+	array  bool
+}
+
+// NewStringList returns a StringList which encodes as a single string.
+func NewStringList(s string) StringList {
+	return StringList{Values: []string{s}}
+}
+
+func (sl *StringList) decodeRaw(v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		sl.Values, sl.array = []string{v}, false
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return fmt.Errorf("expected a string or an array of strings, got array of %s", typeName(e))
+			}
+			values = append(values, s)
+		}
+		sl.Values, sl.array = values, true
+	case []string:
+		sl.Values, sl.array = append([]string(nil), v...), true
+	default:
+		return fmt.Errorf("expected a string or an array of strings, got %s", typeName(v))
+	}
+
+	return nil
+}
+
+func (sl StringList) encodeRaw() interface{} {
+	switch {
+	case len(sl.Values) == 0:
+		return nil
+	case len(sl.Values) == 1 && !sl.array:
+		return sl.Values[0]
+	default:
+		return append([]string(nil), sl.Values...)
+	}
+}
+
+// Duration is a check timing value. fly.toml accepts it either as an integer
+// number of milliseconds or as a duration string such as "10s". The form it
+// was read in is the form it's written back in.
+type Duration struct {
+	time.Duration
+	raw interface{}
+}
+
+func (d *Duration) decodeRaw(v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		dur, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		d.Duration = dur
+	default:
+		ms, ok := toInt64(v)
+		if !ok {
+			return fmt.Errorf("expected a duration string or milliseconds, got %s", typeName(v))
+		}
+		d.Duration = time.Duration(ms) * time.Millisecond
+	}
+
+	d.raw = v
+
+	return nil
+}
+
+func (d Duration) encodeRaw() interface{} {
+	switch raw := d.raw.(type) {
+	case nil:
+		if d.Duration == 0 {
+			return nil
+		}
+		return d.Duration.String()
+	case string:
+		if dur, err := time.ParseDuration(raw); err == nil && dur == d.Duration {
+			return raw
+		}
+		return d.Duration.String()
+	default:
+		return d.Duration.Milliseconds()
+	}
+}
@@ -0,0 +1,246 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ChangeKind denotes the kind of a Change.
+type ChangeKind string
+
+const (
+	// ChangeAdded denotes fields only the definition diffed to defines.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved denotes fields only the definition diffed from defines.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeModified denotes fields the definitions define differently.
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change describes the difference between two definitions in a single field.
+type Change struct {
+	Kind  ChangeKind  `json:"kind"`
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// String implements fmt.Stringer for Change.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s: %s", c.Field, formatValue(c.New))
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s: %s", c.Field, formatValue(c.Old))
+	default:
+		return fmt.Sprintf("~ %s: %s => %s", c.Field, formatValue(c.Old), formatValue(c.New))
+	}
+}
+
+func formatValue(v interface{}) string {
+	if data, err := json.Marshal(v); err == nil {
+		return string(data)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// defaults lists the values the platform assumes for fields definitions leave
+// out, by field path. Path elements which index arrays are written as [].
+var defaults = map[string]interface{}{
+	"kill_signal":                              "SIGINT",
+	"kill_timeout":                             int64(5),
+	"services[].protocol":                      "tcp",
+	"services[].concurrency.type":              "connections",
+	"services[].ports[].force_https":           false,
+	"services[].http_checks[].method":          "get",
+	"services[].http_checks[].path":            "/",
+	"services[].http_checks[].protocol":        "http",
+	"services[].http_checks[].tls_skip_verify": false,
+	"experimental.auto_rollback":               false,
+	"experimental.private_network":             false,
+}
+
+// Normalize returns the canonical untyped representation of d, which is what
+// definitions are compared by. In it, numbers are int64s or float64s, check
+// timings are duration strings, and neither fields set to the values the
+// platform assumes by default nor empty tables and arrays are present.
+func (d *Definition) Normalize() map[string]interface{} {
+	// roundtrip through the typed model, which discards the form durations and
+	// other scalars were read in, and then through JSON, which normalizes
+	// numbers
+	canonical, _ := DefinitionFromMap(d.Map())
+	canonical.mountsTable = false
+	clearForms(reflect.ValueOf(&canonical).Elem())
+
+	var buf bytes.Buffer
+	_ = json.NewEncoder(&buf).Encode(canonical.Map())
+
+	dec := json.NewDecoder(&buf)
+	dec.UseNumber()
+
+	var v interface{}
+	_ = dec.Decode(&v)
+
+	out, _ := prune("", normalize(v)).(map[string]interface{})
+	if out == nil {
+		out = map[string]interface{}{}
+	}
+
+	return out
+}
+
+// clearForms resets the forms the durations and other scalars under v were
+// read in, so that durations all encode as duration strings, zero values are
+// omitted and string maps hold strings only.
+func clearForms(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			clearForms(v.Elem())
+		}
+	case reflect.Struct:
+		if d, ok := v.Addr().Interface().(*Duration); ok {
+			d.raw = nil
+
+			return
+		}
+
+		if r, ok := v.Addr().Interface().(interface{ clearRaw() }); ok {
+			r.clearRaw()
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				clearForms(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearForms(v.Index(i))
+		}
+	}
+}
+
+// prune drops the defaults and the empty tables and arrays under v, which is
+// found at the given generic path. It returns nil in case nothing remains.
+func prune(path string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			p := joinPath(path, k)
+
+			if def, ok := defaults[p]; ok && reflect.DeepEqual(def, e) {
+				delete(v, k)
+
+				continue
+			}
+
+			if e = prune(p, e); e == nil {
+				delete(v, k)
+			} else {
+				v[k] = e
+			}
+		}
+
+		if len(v) == 0 {
+			return nil
+		}
+	case []interface{}:
+		out := v[:0]
+		for _, e := range v {
+			if e = prune(path+"[]", e); e != nil {
+				out = append(out, e)
+			}
+		}
+
+		if len(out) == 0 {
+			return nil
+		}
+
+		return out
+	}
+
+	return v
+}
+
+// Diff returns the changes which turn definition from into definition to,
+// ordered by field. Definitions are compared in their normalized form.
+func Diff(from, to *Definition) []Change {
+	var changes []Change
+	diffValues("", from.Normalize(), to.Normalize(), &changes)
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes
+}
+
+func diffValues(path string, from, to interface{}, changes *[]Change) {
+	switch {
+	case from == nil && to == nil:
+		return
+	case from == nil:
+		*changes = append(*changes, Change{Kind: ChangeAdded, Field: path, New: to})
+
+		return
+	case to == nil:
+		*changes = append(*changes, Change{Kind: ChangeRemoved, Field: path, Old: from})
+
+		return
+	}
+
+	om, oIsMap := from.(map[string]interface{})
+	nm, nIsMap := to.(map[string]interface{})
+	if oIsMap && nIsMap {
+		keys := map[string]bool{}
+		for k := range om {
+			keys[k] = true
+		}
+		for k := range nm {
+			keys[k] = true
+		}
+
+		for k := range keys {
+			diffValues(joinPath(path, k), om[k], nm[k], changes)
+		}
+
+		return
+	}
+
+	oldSlice, oIsSlice := from.([]interface{})
+	newSlice, nIsSlice := to.([]interface{})
+	if oIsSlice && nIsSlice && (hasTables(oldSlice) || hasTables(newSlice)) {
+		for i := 0; i < len(oldSlice) || i < len(newSlice); i++ {
+			var o, n interface{}
+			if i < len(oldSlice) {
+				o = oldSlice[i]
+			}
+			if i < len(newSlice) {
+				n = newSlice[i]
+			}
+
+			diffValues(fmt.Sprintf("%s[%d]", path, i), o, n, changes)
+		}
+
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, Change{Kind: ChangeModified, Field: path, Old: from, New: to})
+	}
+}
+
+func hasTables(s []interface{}) bool {
+	for _, e := range s {
+		if _, ok := e.(map[string]interface{}); ok {
+			return true
+		}
+	}
+
+	return false
+}
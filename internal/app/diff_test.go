@@ -0,0 +1,84 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffIgnoresRepresentationalDifferences(t *testing.T) {
+	local, err := DefinitionFromMap(map[string]interface{}{
+		"mounts": map[string]interface{}{
+			"source":      "data",
+			"destination": "/data",
+		},
+		"services": []map[string]interface{}{
+			{
+				"internal_port": int64(8080),
+				"ports": []map[string]interface{}{
+					{"port": int64(80), "handlers": []interface{}{"http"}},
+				},
+				"tcp_checks": []map[string]interface{}{
+					{"interval": "15s", "timeout": int64(2000)},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// the way the API returns the same config
+	deployed, err := DefinitionFromMap(map[string]interface{}{
+		"kill_signal":  "SIGINT",
+		"kill_timeout": float64(5),
+		"env":          map[string]interface{}{},
+		"mounts": []interface{}{
+			map[string]interface{}{"destination": "/data", "source": "data"},
+		},
+		"services": []interface{}{
+			map[string]interface{}{
+				"internal_port": float64(8080),
+				"protocol":      "tcp",
+				"concurrency":   map[string]interface{}{"type": "connections"},
+				"ports": []interface{}{
+					map[string]interface{}{"port": float64(80), "handlers": []interface{}{"http"}, "force_https": false},
+				},
+				"tcp_checks": []interface{}{
+					map[string]interface{}{"interval": float64(15000), "timeout": "2s"},
+				},
+				"script_checks": []interface{}{},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, Diff(&deployed, &local))
+	assert.Equal(t, deployed.Normalize(), local.Normalize())
+}
+
+func TestDiffReportsChanges(t *testing.T) {
+	deployed, err := DefinitionFromMap(map[string]interface{}{
+		"kill_signal": "SIGTERM",
+		"env":         map[string]interface{}{"LOG_LEVEL": "info"},
+		"services": []interface{}{
+			map[string]interface{}{"internal_port": float64(8080)},
+		},
+	})
+	require.NoError(t, err)
+
+	local, err := DefinitionFromMap(map[string]interface{}{
+		"env": map[string]interface{}{"LOG_LEVEL": "debug", "PORT": "8080"},
+		"services": []interface{}{
+			map[string]interface{}{"internal_port": int64(8080)},
+			map[string]interface{}{"internal_port": int64(9090)},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []Change{
+		{Kind: ChangeModified, Field: "env.LOG_LEVEL", Old: "info", New: "debug"},
+		{Kind: ChangeAdded, Field: "env.PORT", New: "8080"},
+		{Kind: ChangeRemoved, Field: "kill_signal", Old: "SIGTERM"},
+		{Kind: ChangeAdded, Field: "services[1]", New: map[string]interface{}{"internal_port": int64(9090)}},
+	}, Diff(&deployed, &local))
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Position denotes a location within an app config file.
+type Position struct {
+	// File is set for locations in files other than the config file proper,
+	// such as environment overlays.
+	File string `json:"file,omitempty"`
+	Line int    `json:"line"`
+	// Column is 0 for locations known by their line only.
+	Column int `json:"column"`
+}
+
+// IsValid reports whether p points somewhere.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String implements fmt.Stringer for Position.
+func (p Position) String() string {
+	if p.File != "" {
+		return fmt.Sprintf("%s, %s", filepath.Base(p.File), Position{Line: p.Line, Column: p.Column})
+	}
+
+	if p.Column == 0 {
+		return fmt.Sprintf("line %d", p.Line)
+	}
+
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// FieldError denotes an invalid value for a single field of an app config.
+type FieldError struct {
+	// Field is the path to the field, as in services[0].ports[1].port.
+	Field string
+	// Position is where the field is defined at. It's only set for fields read
+	// from files.
+	Position Position
+	Message  string
+
+	// unresolved is set for fields whose variable references failed to
+	// resolve.
+	unresolved bool
+}
+
+// Unresolved reports whether e denotes a field whose variable references
+// failed to resolve, rather than an invalid value.
+func (e *FieldError) Unresolved() bool {
+	return e.unresolved
+}
+
+// Error implements error for FieldError.
+func (e *FieldError) Error() string {
+	if e.Position.IsValid() {
+		return fmt.Sprintf("%s (%s): %s", e.Field, e.Position, e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError is returned when an app config contains fields with
+// invalid values.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+// Error implements error for ValidationError.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+
+	return "invalid app config: " + strings.Join(msgs, "; ")
+}
+
+// SyntaxError is returned when an app config file isn't well-formed TOML,
+// YAML or JSON.
+type SyntaxError struct {
+	// Position is where parsing failed at, as far as the parser tells.
+	Position Position
+	Message  string
+}
+
+// Error implements error for SyntaxError.
+func (e *SyntaxError) Error() string {
+	if e.Position.IsValid() {
+		return fmt.Sprintf("syntax error (%s): %s", e.Position, e.Message)
+	}
+
+	return "syntax error: " + e.Message
+}
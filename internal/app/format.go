@@ -0,0 +1,306 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	gotoml "github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format denotes the encoding of an app config file.
+type Format string
+
+const (
+	// FormatTOML denotes TOML encoded app config files, such as fly.toml.
+	FormatTOML Format = "toml"
+	// FormatYAML denotes YAML encoded app config files, such as fly.yaml.
+	FormatYAML Format = "yaml"
+	// FormatJSON denotes JSON encoded app config files, such as fly.json.
+	FormatJSON Format = "json"
+)
+
+// ConfigFileNames lists the names of the app config files we look for in a
+// directory, in order of preference.
+var ConfigFileNames = []string{
+	DefaultConfigFileName,
+	"fly.yaml",
+	"fly.yml",
+	"fly.json",
+}
+
+// FormatFromPath returns the format the file at path is encoded in, as implied
+// by its extension. It returns an empty Format for unsupported extensions.
+func FormatFromPath(path string) Format {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// ParseFormat returns the Format s names. It returns an empty Format for
+// unsupported formats.
+func ParseFormat(s string) Format {
+	switch strings.ToLower(s) {
+	case "toml":
+		return FormatTOML
+	case "yaml", "yml":
+		return FormatYAML
+	case "json":
+		return FormatJSON
+	default:
+		return ""
+	}
+}
+
+// Unmarshal decodes data, which should be an app config encoded in the given
+// format, into its untyped representation. Numbers are decoded the same way
+// regardless of the format; integers result to int64s.
+func Unmarshal(data []byte, format Format) (raw map[string]interface{}, err error) {
+	switch format {
+	case FormatTOML:
+		_, err = toml.Decode(string(data), &raw)
+	case FormatYAML:
+		var v interface{}
+		if err = yaml.Unmarshal(data, &v); err == nil {
+			raw, err = rawTable(normalize(v))
+		}
+	case FormatJSON:
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+
+		var v interface{}
+		if err = d.Decode(&v); err == nil {
+			raw, err = rawTable(normalize(v))
+		}
+	default:
+		err = unsupportedFormatError(format)
+	}
+
+	if err == nil && raw == nil {
+		raw = map[string]interface{}{}
+	}
+
+	if serr := syntaxError(data, format, err); serr != nil {
+		err = serr
+	}
+
+	return
+}
+
+var (
+	goTOMLErrorPattern = regexp.MustCompile(`^\((\d+), (\d+)\): (.*)$`)
+	tomlErrorPattern   = regexp.MustCompile(`^Near line (\d+) \(last key parsed '[^']*'\): (.*)$`)
+	yamlErrorPattern   = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+)
+
+// syntaxError returns the SyntaxError err, which decoding data in the given
+// format failed with, denotes, if any.
+func syntaxError(data []byte, format Format, err error) *SyntaxError {
+	if err == nil {
+		return nil
+	}
+
+	switch format {
+	case FormatTOML:
+		// the decoder proper tells lines only; the one positions are read
+		// with tells columns as well
+		if _, gerr := gotoml.LoadBytes(data); gerr != nil {
+			if m := goTOMLErrorPattern.FindStringSubmatch(gerr.Error()); m != nil {
+				return &SyntaxError{Position: position(m[1], m[2]), Message: m[3]}
+			}
+		}
+
+		if m := tomlErrorPattern.FindStringSubmatch(err.Error()); m != nil {
+			return &SyntaxError{Position: position(m[1], ""), Message: m[2]}
+		}
+
+		return &SyntaxError{Message: err.Error()}
+	case FormatYAML:
+		msg := err.Error()
+		if terr, ok := err.(*yaml.TypeError); ok && len(terr.Errors) > 0 {
+			msg = terr.Errors[0]
+		}
+
+		if m := yamlErrorPattern.FindStringSubmatch(msg); m != nil {
+			return &SyntaxError{Position: position(m[1], ""), Message: m[2]}
+		}
+
+		if strings.HasPrefix(msg, "yaml: ") {
+			return &SyntaxError{Message: strings.TrimPrefix(msg, "yaml: ")}
+		}
+	case FormatJSON:
+		var offset int64
+		switch err := err.(type) {
+		case *json.SyntaxError:
+			offset = err.Offset
+		case *json.UnmarshalTypeError:
+			offset = err.Offset
+		default:
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return &SyntaxError{Message: "unexpected end of JSON input"}
+			}
+
+			return nil
+		}
+
+		return &SyntaxError{Position: offsetPosition(data, offset), Message: err.Error()}
+	}
+
+	return nil
+}
+
+func position(line, column string) (p Position) {
+	p.Line, _ = strconv.Atoi(line)
+	p.Column, _ = strconv.Atoi(column)
+
+	return
+}
+
+// offsetPosition returns the position of the byte of data the JSON decoder
+// failed at, after reading offset bytes.
+func offsetPosition(data []byte, offset int64) Position {
+	switch {
+	case offset > int64(len(data)):
+		offset = int64(len(data))
+	case offset > 0:
+		offset-- // the offending byte is the last one read
+	}
+
+	before := data[:offset]
+
+	return Position{
+		Line:   bytes.Count(before, []byte("\n")) + 1,
+		Column: len(before) - bytes.LastIndexByte(before, '\n'),
+	}
+}
+
+// Marshal writes the untyped app config raw to w in the given format. The app
+// name is written first, preceded by a header comment for formats which
+// support comments.
+func Marshal(w io.Writer, raw map[string]interface{}, format Format) error {
+	name, _ := raw["app"].(string)
+
+	rest := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		if k != "app" {
+			rest[k] = v
+		}
+	}
+
+	header := fmt.Sprintf("# fly.%s file generated for %s on %s\n\n", format, name, time.Now().Format(time.RFC3339))
+
+	var b bytes.Buffer
+
+	switch format {
+	case FormatTOML:
+		b.WriteString(header)
+
+		encoder := toml.NewEncoder(&b)
+		if err := encoder.Encode(map[string]interface{}{"app": name}); err != nil {
+			return err
+		}
+
+		if len(rest) > 0 {
+			if err := encoder.Encode(rest); err != nil {
+				return err
+			}
+		}
+	case FormatYAML:
+		b.WriteString(header)
+
+		for _, v := range []map[string]interface{}{{"app": name}, rest} {
+			if len(v) == 0 {
+				continue
+			}
+
+			// encoding the app name on its own keeps it on top
+			encoder := yaml.NewEncoder(&b)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(v); err != nil {
+				return err
+			}
+			if err := encoder.Close(); err != nil {
+				return err
+			}
+		}
+	case FormatJSON:
+		all := make(map[string]interface{}, len(raw))
+		for k, v := range rest {
+			all[k] = v
+		}
+		all["app"] = name
+
+		data, err := json.MarshalIndent(all, "", "  ")
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	default:
+		return unsupportedFormatError(format)
+	}
+
+	_, err := b.WriteTo(w)
+
+	return err
+}
+
+func unsupportedFormatError(format Format) error {
+	return fmt.Errorf("unsupported app config format %q", format)
+}
+
+func rawTable(v interface{}) (map[string]interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("expected the app config to be a table, got %s", typeName(v))
+	}
+}
+
+// normalize converts the values the YAML and JSON decoders produce to the
+// ones the TOML decoder does, so that all formats share the same semantics.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalize(e)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+		return v
+	case int:
+		return int64(v)
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+		return float64(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
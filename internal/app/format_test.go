@@ -0,0 +1,136 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	cases := map[string]Format{
+		"fly.toml":        FormatTOML,
+		"fly.yaml":        FormatYAML,
+		"fly.yml":         FormatYAML,
+		"/app/fly.JSON":   FormatJSON,
+		"fly.production":  "",
+		"fly":             "",
+		"configs/fly.ini": "",
+	}
+
+	for path, format := range cases {
+		assert.Equal(t, format, FormatFromPath(path), path)
+	}
+}
+
+func TestLoadAppConfigFormatsAreEquivalent(t *testing.T) {
+	want, err := LoadConfig("./testdata/full.toml")
+	require.NoError(t, err)
+
+	for _, path := range []string{"./testdata/full.yaml", "./testdata/full.json"} {
+		got, err := LoadConfig(path)
+		require.NoError(t, err, path)
+
+		assert.Equal(t, want.AppName, got.AppName, path)
+		assert.Equal(t, want.Definition.Map(), got.Definition.Map(), path)
+	}
+}
+
+func TestConvertAppConfigRoundTrip(t *testing.T) {
+	src, err := LoadConfig("./testdata/full.toml")
+	require.NoError(t, err)
+
+	for _, format := range []Format{FormatYAML, FormatJSON} {
+		var converted bytes.Buffer
+		require.NoError(t, src.Encode(&converted, format))
+
+		var mid Config
+		require.NoError(t, mid.unmarshal(converted.Bytes(), format), format)
+
+		var back bytes.Buffer
+		require.NoError(t, mid.Encode(&back, FormatTOML))
+
+		var got Config
+		require.NoError(t, got.unmarshal(back.Bytes(), FormatTOML), format)
+
+		assert.Equal(t, src.AppName, got.AppName, format)
+		assert.Equal(t, src.Definition.Map(), mid.Definition.Map(), format)
+		assert.Equal(t, src.Definition.Map(), got.Definition.Map(), format)
+	}
+}
+
+func TestLoadYAMLAppConfigWithInvalidFields(t *testing.T) {
+	_, err := LoadConfig("./testdata/invalid.yaml")
+
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Errors, 3)
+
+	byField := map[string]*FieldError{}
+	for _, fe := range verr.Errors {
+		byField[fe.Field] = fe
+	}
+
+	assert.Equal(t, Position{Line: 4, Column: 5}, byField["services[0].internal_port"].Position)
+	assert.Equal(t, Position{Line: 7, Column: 9}, byField["services[0].ports[0].handlers"].Position)
+	assert.Equal(t, Position{Line: 10, Column: 9}, byField["services[0].tcp_checks[0].interval"].Position)
+}
+
+func TestUnmarshalSyntaxErrors(t *testing.T) {
+	cases := map[Format]struct {
+		data string
+		pos  Position
+	}{
+		FormatTOML: {
+			data: "app = \"x\"\n\n[env\nA = 1\n",
+			pos:  Position{Line: 3, Column: 2},
+		},
+		FormatYAML: {
+			data: "app: x\nenv:\n  A: 1\n B: 2\n",
+			pos:  Position{Line: 3},
+		},
+		FormatJSON: {
+			data: "{\n  \"app\": \"x\",\n  \"env\": {,}\n}\n",
+			pos:  Position{Line: 3, Column: 11},
+		},
+	}
+
+	for format, c := range cases {
+		_, err := Unmarshal([]byte(c.data), format)
+
+		var serr *SyntaxError
+		require.True(t, errors.As(err, &serr), "%s: %v", format, err)
+		assert.Equal(t, c.pos, serr.Position, format)
+		assert.NotEmpty(t, serr.Message, format)
+	}
+}
+
+func TestLoadAppConfigWithUnsupportedFormat(t *testing.T) {
+	_, err := LoadConfig("./testdata/fly.ini")
+	assert.Error(t, err)
+}
+
+func TestAppConfigNotifyRoundTrip(t *testing.T) {
+	const data = `
+app = "notify"
+
+[notify]
+  urls = ["slack://hooks.slack.com/services/T0/B0/X", "https://example.com/hooks"]
+`
+
+	var cfg Config
+	require.NoError(t, cfg.unmarshal([]byte(data), FormatTOML))
+
+	want := []string{"slack://hooks.slack.com/services/T0/B0/X", "https://example.com/hooks"}
+	assert.Equal(t, want, cfg.Notify)
+	assert.NotContains(t, cfg.Definition.Map(), "notify")
+
+	var buf bytes.Buffer
+	require.NoError(t, cfg.Encode(&buf, FormatYAML))
+
+	var got Config
+	require.NoError(t, got.unmarshal(buf.Bytes(), FormatYAML))
+	assert.Equal(t, want, got.Notify)
+}
@@ -0,0 +1,384 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultEnvFileName denotes the name of the file, found next to the app
+// config, variables referenced by the config are resolved from, in addition to
+// the process environment.
+const DefaultEnvFileName = ".env"
+
+// VariableSource denotes where the value of a variable came from.
+type VariableSource string
+
+const (
+	// SourceEnvironment denotes variables resolved from the process
+	// environment.
+	SourceEnvironment VariableSource = "environment"
+	// SourceEnvFile denotes variables resolved from the env file.
+	SourceEnvFile VariableSource = "env file"
+	// SourceDefault denotes variables which resolved to the default their
+	// reference specifies.
+	SourceDefault VariableSource = "default"
+)
+
+// Resolution describes how a single ${VAR} reference was resolved.
+type Resolution struct {
+	Name   string         `json:"name"`
+	Value  string         `json:"value"`
+	Source VariableSource `json:"source"`
+	// File is the path to the env file for variables resolved from it.
+	File string `json:"file,omitempty"`
+}
+
+// String implements fmt.Stringer for Resolution.
+func (r Resolution) String() string {
+	if r.File != "" {
+		return fmt.Sprintf("$%s from %s", r.Name, filepath.Base(r.File))
+	}
+
+	return fmt.Sprintf("$%s from %s", r.Name, r.Source)
+}
+
+// variables resolves the ${VAR} and ${VAR:-default} references of config
+// files. Process environment variables take precedence over the ones the env
+// file defines.
+type variables struct {
+	lookupEnv func(string) (string, bool)
+	file      map[string]string
+	filePath  string
+}
+
+// newVariables returns variables which resolve from the process environment
+// and the env file at envFile, if it exists.
+func newVariables(envFile string, lookupEnv func(string) (string, bool)) (*variables, error) {
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	v := &variables{
+		lookupEnv: lookupEnv,
+	}
+
+	if envFile == "" {
+		return v, nil
+	}
+
+	data, err := os.ReadFile(envFile)
+	switch {
+	case os.IsNotExist(err):
+		return v, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if v.file, err = parseEnvFile(data); err != nil {
+		return nil, fmt.Errorf("failed parsing %s: %w", envFile, err)
+	}
+	v.filePath = envFile
+
+	return v, nil
+}
+
+func (v *variables) lookup(name string) (string, VariableSource, bool) {
+	if val, ok := v.lookupEnv(name); ok {
+		return val, SourceEnvironment, true
+	}
+
+	if val, ok := v.file[name]; ok {
+		return val, SourceEnvFile, true
+	}
+
+	return "", "", false
+}
+
+// escapeDollarsKey is the top-level key configs opt in to $$ escapes with.
+const escapeDollarsKey = "escape_dollars"
+
+// runtimeFields lists the fields the platform passes to the shell of the app's
+// machines. References to variables which aren't set locally are kept in these,
+// so that they're expanded at runtime the way they were before configs were
+// interpolated.
+var runtimeFields = []string{
+	"env",
+	"processes",
+	"deploy.release_command",
+	"experimental.cmd",
+	"experimental.entrypoint",
+}
+
+func isRuntimeField(path string) bool {
+	for _, field := range runtimeFields {
+		if under(path, field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// interpolator replaces the variable references of untyped configs in place.
+type interpolator struct {
+	vars *variables
+	// escapes is set when $$ stands for a literal $.
+	escapes bool
+
+	errs []*FieldError
+	// resolved maps the paths of the fields which contained references to how
+	// they were resolved.
+	resolved map[string][]Resolution
+	// whole is the set of paths of the fields whose value consisted of a
+	// single reference; these may be coerced to non-string types.
+	whole map[string]bool
+}
+
+func (in *interpolator) interpolateTable(path string, m map[string]interface{}) {
+	for k, v := range m {
+		m[k] = in.interpolate(joinPath(path, k), v)
+	}
+}
+
+func (in *interpolator) interpolate(path string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return in.interpolateString(path, v)
+	case map[string]interface{}:
+		in.interpolateTable(path, v)
+	case []map[string]interface{}:
+		for i, m := range v {
+			in.interpolateTable(fmt.Sprintf("%s[%d]", path, i), m)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = in.interpolate(fmt.Sprintf("%s[%d]", path, i), e)
+		}
+	}
+
+	return v
+}
+
+var referencePattern = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+func (in *interpolator) interpolateString(path, s string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+
+	var (
+		b           strings.Builder
+		resolutions []Resolution
+		references  int
+		literal     bool
+		runtime     = isRuntimeField(path)
+	)
+
+	for i := 0; i < len(s); {
+		switch {
+		case in.escapes && strings.HasPrefix(s[i:], "$$"):
+			b.WriteByte('$') // escaped
+			i += 2
+			literal = true
+		case strings.HasPrefix(s[i:], "${"):
+			match := referencePattern.FindStringSubmatch(s[i:])
+			switch {
+			case match == nil && runtime:
+				// shell expansions such as ${VAR%suffix}
+				b.WriteString("${")
+				i += 2
+				literal = true
+
+				continue
+			case match == nil:
+				if in.escapes {
+					in.fail(path, "invalid variable reference in %q; use $$ for a literal $", s)
+				} else {
+					in.fail(path, "invalid variable reference in %q; set %s = true and use $$ for a literal $", s, escapeDollarsKey)
+				}
+
+				return s
+			}
+
+			name, hasDefault, def := match[1], match[2] != "", match[3]
+
+			val, source, ok := in.vars.lookup(name)
+			switch {
+			case ok && (val != "" || !hasDefault):
+				break
+			case hasDefault:
+				val, source = def, SourceDefault
+			case runtime:
+				b.WriteString(match[0])
+				i += len(match[0])
+				literal = true
+
+				continue
+			default:
+				in.fail(path, "variable %s is not set", name)
+
+				return s
+			}
+
+			res := Resolution{Name: name, Value: val, Source: source}
+			if source == SourceEnvFile {
+				res.File = in.vars.filePath
+			}
+
+			b.WriteString(val)
+			resolutions = append(resolutions, res)
+			references++
+			i += len(match[0])
+		default:
+			b.WriteByte(s[i])
+			i++
+			literal = true
+		}
+	}
+
+	if len(resolutions) > 0 {
+		if in.resolved == nil {
+			in.resolved = map[string][]Resolution{}
+		}
+		in.resolved[path] = resolutions
+
+		if references == 1 && !literal {
+			if in.whole == nil {
+				in.whole = map[string]bool{}
+			}
+			in.whole[path] = true
+		}
+	}
+
+	return b.String()
+}
+
+func (in *interpolator) fail(path, format string, v ...interface{}) {
+	in.errs = append(in.errs, &FieldError{
+		Field:      path,
+		Message:    fmt.Sprintf(format, v...),
+		unresolved: true,
+	})
+}
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseEnvFile parses .env-style files: one NAME=value pair per line, with
+// optionally quoted values, optional export prefixes and # comments.
+func parseEnvFile(data []byte) (map[string]string, error) {
+	vars := map[string]string{}
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected NAME=value", n)
+		}
+
+		name := strings.TrimSpace(line[:eq])
+		if !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", n, name)
+		}
+
+		value := strings.TrimSpace(line[eq+1:])
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid quoted value", n)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+
+		vars[name] = value
+	}
+
+	return vars, s.Err()
+}
+
+// Origin describes where the value of a single field of an app config came
+// from.
+type Origin struct {
+	Field       string       `json:"field"`
+	Value       interface{}  `json:"value"`
+	Position    *Position    `json:"position,omitempty"`
+	Resolutions []Resolution `json:"resolutions,omitempty"`
+}
+
+// Origins returns the origins of the fields c defines, ordered by field.
+// Arrays of scalars are reported as a single field.
+func (c *Config) Origins() (origins []Origin) {
+	leaves := map[string]interface{}{}
+	collectLeaves("", c.raw(), leaves)
+
+	fields := make([]string, 0, len(leaves))
+	for field := range leaves {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		o := Origin{
+			Field: field,
+			Value: leaves[field],
+		}
+
+		if pos := c.positions.lookup(field); pos.IsValid() {
+			o.Position = &pos
+		}
+
+		for path, res := range c.resolutions {
+			if under(path, field) {
+				o.Resolutions = append(o.Resolutions, res...)
+			}
+		}
+
+		origins = append(origins, o)
+	}
+
+	return
+}
+
+func collectLeaves(path string, v interface{}, leaves map[string]interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			collectLeaves(joinPath(path, k), e, leaves)
+		}
+	case map[string]string:
+		for k, e := range v {
+			leaves[joinPath(path, k)] = e
+		}
+	case []map[string]interface{}:
+		for i, m := range v {
+			collectLeaves(fmt.Sprintf("%s[%d]", path, i), m, leaves)
+		}
+	default:
+		leaves[path] = v
+	}
+}
+
+// EnvFilePath returns the path to the env file next to the app config at path.
+func EnvFilePath(path string) string {
+	return filepath.Join(filepath.Dir(path), DefaultEnvFileName)
+}
@@ -0,0 +1,201 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lookupEnv(env map[string]string) func(string) (string, bool) {
+	return func(name string) (v string, ok bool) {
+		v, ok = env[name]
+		return
+	}
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestLoadConfigInterpolatesVariables(t *testing.T) {
+	cfg, err := LoadConfigWithOptions("./testdata/interpolate/fly.toml", LoadOptions{
+		LookupEnv: lookupEnv(map[string]string{
+			"IMAGE_TAG": "v2",
+		}),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "interpolate", cfg.AppName)
+	assert.Equal(t, "registry.fly.io/interpolate:v2", cfg.Image())
+	assert.Equal(t, map[string]string{
+		"PRIMARY_REGION": "iad",
+		"LOG_LEVEL":      "debug",
+		"PRICE":          "$5",
+	}, cfg.Definition.Env)
+	assert.Equal(t, 8080, cfg.Definition.Services[0].InternalPort)
+	assert.Equal(t, "testdata/interpolate/.env", cfg.EnvFilePath)
+}
+
+func TestLoadConfigReportsUnresolvedVariables(t *testing.T) {
+	cfg, err := LoadConfigWithOptions("./testdata/interpolate/unset.toml", LoadOptions{
+		LookupEnv: lookupEnv(nil),
+	})
+
+	// unresolved references are left as they are
+	require.NotNil(t, cfg)
+	assert.Equal(t, "${KILL_SIGNAL}", cfg.Definition.KillSignal)
+
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Errors, 2)
+
+	byField := map[string]*FieldError{}
+	for _, fe := range verr.Errors {
+		byField[fe.Field] = fe
+	}
+
+	assert.Equal(t, "variable KILL_SIGNAL is not set", byField["kill_signal"].Message)
+	assert.Equal(t, Position{Line: 2, Column: 1}, byField["kill_signal"].Position)
+	assert.True(t, byField["kill_signal"].Unresolved())
+	assert.Contains(t, byField["build.image"].Message, "invalid variable reference")
+}
+
+func TestLoadConfigKeepsRuntimeReferences(t *testing.T) {
+	cfg, err := LoadConfigWithOptions("./testdata/interpolate/unset.toml", LoadOptions{
+		LookupEnv: lookupEnv(map[string]string{
+			"KILL_SIGNAL": "SIGTERM",
+			"BROKEN":      "",
+		}),
+	})
+
+	// the image is still broken, but fields the machines' shell expands keep
+	// what isn't set locally for it
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Errors, 1)
+	assert.Equal(t, "build.image", verr.Errors[0].Field)
+
+	def := cfg.Definition
+	assert.Equal(t, "SIGTERM", def.KillSignal)
+	assert.Equal(t, "bin/migrate ${DATABASE_URL}", def.Deploy.ReleaseCommand)
+	assert.Equal(t, "bin/web --port ${LISTEN_PORT} --region iad", def.Processes["web"])
+	assert.Equal(t, map[string]string{
+		"TOKEN":    "${TOKEN}",
+		"HOME_DIR": "${HOME%/}",
+	}, def.Env)
+
+	// and the ones which are set resolve as elsewhere
+	cfg, err = LoadConfigWithOptions("./testdata/interpolate/unset.toml", LoadOptions{
+		LookupEnv: lookupEnv(map[string]string{
+			"DATABASE_URL": "postgres://db",
+		}),
+	})
+	require.Error(t, err)
+	assert.Equal(t, "bin/migrate postgres://db", cfg.Definition.Deploy.ReleaseCommand)
+}
+
+func TestConfigOrigins(t *testing.T) {
+	cfg, err := LoadConfigWithOptions("./testdata/interpolate/fly.toml", LoadOptions{
+		LookupEnv: lookupEnv(map[string]string{
+			"IMAGE_TAG": "v2",
+		}),
+	})
+	require.NoError(t, err)
+
+	byField := map[string]Origin{}
+	for _, o := range cfg.Origins() {
+		byField[o.Field] = o
+	}
+
+	assert.Equal(t, []Resolution{{Name: "IMAGE_TAG", Value: "v2", Source: SourceEnvironment}}, byField["build.image"].Resolutions)
+	assert.Equal(t, []Resolution{{Name: "REGION", Value: "iad", Source: SourceDefault}}, byField["env.PRIMARY_REGION"].Resolutions)
+	assert.Equal(t, []Resolution{{Name: "PORT", Value: "8080", Source: SourceEnvFile, File: "testdata/interpolate/.env"}}, byField["services[0].internal_port"].Resolutions)
+	assert.Empty(t, byField["services[0].protocol"].Resolutions)
+
+	require.NotNil(t, byField["services[0].protocol"].Position)
+	assert.Equal(t, 14, byField["services[0].protocol"].Position.Line)
+}
+
+func TestParseEnvFile(t *testing.T) {
+	vars, err := parseEnvFile([]byte(`
+# comment
+A=1
+export B = "two\nlines"
+C='single $quoted'
+D=unquoted # trailing comment
+E=
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"A": "1",
+		"B": "two\nlines",
+		"C": "single $quoted",
+		"D": "unquoted",
+		"E": "",
+	}, vars)
+
+	_, err = parseEnvFile([]byte("NOT AN ASSIGNMENT"))
+	assert.Error(t, err)
+}
+
+func TestLoadConfigLeavesDollarsWithoutEscapes(t *testing.T) {
+	cfg, err := LoadConfigWithOptions("./testdata/interpolate/dollars.toml", LoadOptions{
+		LookupEnv: lookupEnv(nil),
+	})
+	require.NoError(t, err)
+
+	assert.False(t, cfg.EscapeDollars)
+	assert.Equal(t, map[string]string{
+		"PRICE":  "$$5",
+		"REGION": "iad",
+	}, cfg.Definition.Env)
+}
+
+func TestConvertConfigKeepsReferences(t *testing.T) {
+	const path = "./testdata/interpolate/fly.toml"
+
+	// references in place of integers don't decode but are carried over
+	cfg, err := LoadConfigWithOptions(path, LoadOptions{
+		Verbatim: true,
+	})
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, "services[0].internal_port", verr.Errors[0].Field)
+
+	want, err := Unmarshal(mustReadFile(t, path), FormatTOML)
+	require.NoError(t, err)
+	wantJSON, err := json.Marshal(want)
+	require.NoError(t, err)
+
+	for _, format := range []Format{FormatYAML, FormatJSON, FormatTOML} {
+		var b bytes.Buffer
+		require.NoError(t, cfg.Encode(&b, format))
+
+		got, err := Unmarshal(b.Bytes(), format)
+		require.NoError(t, err, format)
+		gotJSON, err := json.Marshal(got)
+		require.NoError(t, err, format)
+
+		assert.JSONEq(t, string(wantJSON), string(gotJSON), format)
+	}
+}
+
+func TestLoadConfigVerbatim(t *testing.T) {
+	cfg, err := LoadConfigWithOptions("./testdata/interpolate/unset.toml", LoadOptions{
+		Verbatim: true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "${TOKEN}", cfg.Definition.Env["TOKEN"])
+}
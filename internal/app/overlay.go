@@ -0,0 +1,203 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// mergeOverlay merges the overlay of the named environment onto raw, the
+// untyped app config at path, and updates pos, the positions of its fields,
+// accordingly. It returns the path to the overlay. The overlay of the staging
+// environment for fly.toml is fly.staging.toml, found in the same directory.
+// Overlays may be encoded in any of the supported formats.
+//
+// Overlays are merged key by key: tables are merged recursively while any
+// other value, arrays included, replaces the one it overrides. The exception
+// are services, which are matched by their internal port; a service of the
+// overlay is merged onto the service of the base config which listens on the
+// same internal port, or appended in case there's none.
+func mergeOverlay(path, environment string, raw map[string]interface{}, pos positions) (overlay string, err error) {
+	if overlay = OverlayPath(path, environment); overlay == "" {
+		err = fmt.Errorf("no overlay for environment %q found next to %s", environment, path)
+
+		return
+	}
+
+	var (
+		overlayRaw map[string]interface{}
+		overlayPos positions
+	)
+	if overlayRaw, overlayPos, err = readConfigFile(overlay); err != nil {
+		if serr, ok := err.(*SyntaxError); ok {
+			serr.Position.File = overlay
+		}
+
+		err = fmt.Errorf("failed loading overlay for environment %q: %w", environment, err)
+
+		return
+	}
+
+	for p, v := range overlayPos {
+		v.File = overlay
+		overlayPos[p] = v
+	}
+
+	m := &merger{
+		pos:        pos,
+		overlayPos: overlayPos,
+	}
+	m.mergeTable("", "", raw, overlayRaw)
+
+	return
+}
+
+// OverlayPath returns the path to the overlay of the named environment for the
+// app config at path. Overlays encoded in the format of the config are
+// preferred. OverlayPath returns an empty string in case no overlay exists.
+func OverlayPath(path, environment string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	exts := []string{ext}
+	for _, name := range ConfigFileNames {
+		if e := filepath.Ext(name); e != ext {
+			exts = append(exts, e)
+		}
+	}
+
+	for _, e := range exts {
+		candidate := base + "." + environment + e
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// merger merges untyped overlays onto untyped configs, keeping track of where
+// each merged field was defined at.
+type merger struct {
+	pos        positions
+	overlayPos positions
+}
+
+// mergeTable merges the overlay table src, found at srcPath of the overlay,
+// onto the table dst, found at dstPath of the config.
+func (m *merger) mergeTable(dstPath, srcPath string, dst, src map[string]interface{}) {
+	for k, v := range src {
+		dp, sp := joinPath(dstPath, k), joinPath(srcPath, k)
+
+		if dstPath == "" && k == "services" {
+			if merged, ok := m.mergeServices(dst[k], v); ok {
+				dst[k] = merged
+
+				continue
+			}
+		}
+
+		dt, dok := dst[k].(map[string]interface{})
+		st, sok := v.(map[string]interface{})
+		if dok && sok {
+			m.mergeTable(dp, sp, dt, st)
+
+			continue
+		}
+
+		dst[k] = v
+		m.replace(dp, sp)
+	}
+}
+
+// mergeServices merges the services of the overlay onto the ones of the
+// config, matching them by their internal port. It reports false in case
+// either side isn't an array of tables, which leaves the overlay to replace
+// the services wholesale.
+func (m *merger) mergeServices(dst, src interface{}) ([]interface{}, bool) {
+	dstServices, dok := tables(dst)
+	srcServices, sok := tables(src)
+	if (dst != nil && !dok) || !sok {
+		return nil, false
+	}
+
+	merged := make([]interface{}, len(dstServices), len(dstServices)+len(srcServices))
+	for i, svc := range dstServices {
+		merged[i] = svc
+	}
+
+	for i, svc := range srcServices {
+		sp := fmt.Sprintf("services[%d]", i)
+
+		if j := serviceByPort(dstServices, svc["internal_port"]); j >= 0 {
+			dp := fmt.Sprintf("services[%d]", j)
+			m.mergeTable(dp, sp, dstServices[j], svc)
+
+			continue
+		}
+
+		dp := fmt.Sprintf("services[%d]", len(merged))
+		merged = append(merged, svc)
+		m.replace(dp, sp)
+	}
+
+	return merged, true
+}
+
+// replace drops the positions of the fields at and under dstPath in favor of
+// the ones at and under srcPath of the overlay.
+func (m *merger) replace(dstPath, srcPath string) {
+	for p := range m.pos {
+		if under(p, dstPath) {
+			delete(m.pos, p)
+		}
+	}
+
+	for p, v := range m.overlayPos {
+		if under(p, srcPath) {
+			m.pos[dstPath+strings.TrimPrefix(p, srcPath)] = v
+		}
+	}
+}
+
+func under(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	rest := path[len(prefix):]
+
+	return rest == "" || rest[0] == '.' || rest[0] == '['
+}
+
+func serviceByPort(services []map[string]interface{}, port interface{}) int {
+	want, ok := toInt64(port)
+	if !ok {
+		return -1
+	}
+
+	for i, svc := range services {
+		if got, ok := toInt64(svc["internal_port"]); ok && got == want {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func tables(v interface{}) ([]map[string]interface{}, bool) {
+	items, ok := toSlice(v)
+	if !ok {
+		return nil, false
+	}
+
+	out := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		if out[i], ok = item.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+
+	return out, true
+}
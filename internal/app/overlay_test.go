@@ -0,0 +1,82 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigForEnvironment(t *testing.T) {
+	const path = "./testdata/overlay/fly.toml"
+
+	_, err := loadEnvironment(path, "staging")
+
+	// the overlay declares an invalid port on purpose
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Len(t, verr.Errors, 1)
+
+	fe := verr.Errors[0]
+	assert.Equal(t, "services[1].ports[0].port", fe.Field)
+	assert.Equal(t, Position{File: "./testdata/overlay/fly.staging.toml", Line: 22, Column: 5}, fe.Position)
+}
+
+func TestLoadConfigForEnvironmentMergesOverlay(t *testing.T) {
+	const path = "./testdata/overlay/fly.toml"
+
+	cfg, err := loadEnvironment(path, "staging")
+	require.Error(t, err) // see TestLoadConfigForEnvironment
+
+	assert.Equal(t, "staging", cfg.Environment)
+	assert.Equal(t, "overlay-staging", cfg.AppName)
+
+	def := cfg.Definition
+	assert.Equal(t, "SIGINT", def.KillSignal)
+	assert.Equal(t, map[string]string{"LOG_LEVEL": "debug", "PORT": "8080"}, def.Env)
+
+	require.Len(t, def.Mounts, 1)
+	assert.Equal(t, "staging_data", def.Mounts[0].Source)
+
+	require.Len(t, def.Services, 2)
+
+	web := def.Services[0]
+	assert.Equal(t, 8080, web.InternalPort)
+	assert.Equal(t, "tcp", web.Protocol)
+	assert.Equal(t, &Concurrency{Type: "connections", HardLimit: 5, SoftLimit: 20}, web.Concurrency)
+	require.Len(t, web.Ports, 1)
+	assert.Equal(t, 80, web.Ports[0].Port)
+
+	assert.Equal(t, 9090, def.Services[1].InternalPort)
+
+	// fields the overlay doesn't touch point to the config proper
+	assert.Equal(t, Position{Line: 14, Column: 3}, cfg.Position("services[0].protocol"))
+	assert.Equal(t, "fly.staging.toml", filepath.Base(cfg.Position("services[0].concurrency.hard_limit").File))
+	assert.Equal(t, "", cfg.Position("services[0].concurrency.soft_limit").File)
+}
+
+func TestLoadConfigForEnvironmentInAnotherFormat(t *testing.T) {
+	cfg, err := loadEnvironment("./testdata/overlay/fly.toml", "production")
+	require.NoError(t, err)
+
+	assert.Equal(t, "./testdata/overlay/fly.production.yaml", cfg.OverlayPath)
+	assert.Equal(t, "overlay-production", cfg.AppName)
+	assert.Equal(t, "SIGTERM", cfg.Definition.KillSignal)
+	assert.Equal(t, "info", cfg.Definition.Env["LOG_LEVEL"])
+}
+
+func TestLoadConfigForEnvironmentWithoutOverlay(t *testing.T) {
+	_, err := loadEnvironment("./testdata/overlay/fly.toml", "qa")
+	assert.EqualError(t, err, `no overlay for environment "qa" found next to ./testdata/overlay/fly.toml`)
+
+	cfg, err := loadEnvironment("./testdata/overlay/fly.toml", "")
+	require.NoError(t, err)
+	assert.Equal(t, "overlay", cfg.AppName)
+	assert.Empty(t, cfg.OverlayPath)
+}
+
+func loadEnvironment(path, environment string) (*Config, error) {
+	return LoadConfigWithOptions(path, LoadOptions{Environment: environment})
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	gotoml "github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+)
+
+// positions maps field paths, as in services[0].ports[1].port, to the
+// location in the config file they're defined at.
+type positions map[string]Position
+
+// tomlPositions returns the positions of the fields of the given TOML
+// document. It returns nil in case the document can't be parsed, since the
+// decoder proper reports syntax errors.
+func tomlPositions(data []byte) positions {
+	tree, err := gotoml.LoadBytes(data)
+	if err != nil {
+		return nil
+	}
+
+	p := positions{}
+	p.walkTOML("", tree)
+
+	return p
+}
+
+func (p positions) walkTOML(prefix string, tree *gotoml.Tree) {
+	for _, key := range tree.Keys() {
+		path := joinPath(prefix, key)
+		p[path] = fromTOMLPosition(tree.GetPositionPath([]string{key}))
+
+		switch v := tree.GetPath([]string{key}).(type) {
+		case *gotoml.Tree:
+			p.walkTOML(path, v)
+		case []*gotoml.Tree:
+			for i, t := range v {
+				elem := fmt.Sprintf("%s[%d]", path, i)
+				p[elem] = fromTOMLPosition(t.Position())
+				p.walkTOML(elem, t)
+			}
+		}
+	}
+}
+
+// yamlPositions returns the positions of the fields of the given YAML
+// document. Since JSON is a subset of YAML, it handles JSON documents as well.
+func yamlPositions(data []byte) positions {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+
+	p := positions{}
+	p.walkYAML("", doc.Content[0])
+
+	return p
+}
+
+func (p positions) walkYAML(prefix string, node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			path := joinPath(prefix, key.Value)
+			p[path] = Position{Line: key.Line, Column: key.Column}
+			p.walkYAML(path, value)
+		}
+	case yaml.SequenceNode:
+		for i, elem := range node.Content {
+			path := fmt.Sprintf("%s[%d]", prefix, i)
+			p[path] = Position{Line: elem.Line, Column: elem.Column}
+			p.walkYAML(path, elem)
+		}
+	case yaml.AliasNode:
+		if node.Alias != nil {
+			p.walkYAML(prefix, node.Alias)
+		}
+	}
+}
+
+func fromTOMLPosition(pos gotoml.Position) Position {
+	return Position{
+		Line:   pos.Line,
+		Column: pos.Col,
+	}
+}
+
+// lookup returns the position of the field at path or, in case it's unknown,
+// the position of its closest known parent.
+func (p positions) lookup(path string) Position {
+	for path != "" {
+		if pos, ok := p[path]; ok && pos.IsValid() {
+			return pos
+		}
+
+		path = parentPath(path)
+	}
+
+	return Position{}
+}
+
+// locate sets the positions of the field errors verr carries.
+func (p positions) locate(verr *ValidationError) {
+	for _, fe := range verr.Errors {
+		if !fe.Position.IsValid() {
+			fe.Position = p.lookup(fe.Field)
+		}
+	}
+}
+
+func parentPath(path string) string {
+	if strings.HasSuffix(path, "]") {
+		if i := strings.LastIndexByte(path, '['); i >= 0 {
+			return path[:i]
+		}
+	}
+
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		return path[:i]
+	}
+
+	return ""
+}
@@ -0,0 +1,442 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Severity denotes how serious a Diagnostic is.
+type Severity string
+
+const (
+	// SeverityError denotes a problem which would cause a deployment to fail.
+	SeverityError Severity = "error"
+
+	// SeverityWarning denotes a setting which is valid but most likely wrong.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a finding of Validate.
+type Diagnostic struct {
+	Severity Severity  `json:"severity"`
+	Rule     string    `json:"rule"`
+	Field    string    `json:"field,omitempty"`
+	Position *Position `json:"position,omitempty"`
+	Message  string    `json:"message"`
+}
+
+// String implements fmt.Stringer for Diagnostic.
+func (d Diagnostic) String() string {
+	var b strings.Builder
+
+	b.WriteString(d.Field)
+	switch {
+	case d.Position == nil:
+		break
+	case b.Len() > 0:
+		fmt.Fprintf(&b, " (%s)", d.Position)
+	default:
+		b.WriteString(d.Position.String())
+	}
+	if b.Len() > 0 {
+		b.WriteString(": ")
+	}
+	b.WriteString(d.Message)
+
+	return b.String()
+}
+
+// HasErrors reports whether any of diags is an error.
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks c against the constraints the platform imposes without
+// consulting the API. The field errors encountered while loading c are
+// reported as well. Diagnostics are ordered by their position in the file.
+func (c *Config) Validate() (diags []Diagnostic) {
+	invalid := map[string]bool{}
+	for _, fe := range c.fieldErrors {
+		d := Diagnostic{
+			Severity: SeverityError,
+			Rule:     "schema",
+			Field:    fe.Field,
+			Message:  fe.Message,
+		}
+		if fe.Position.IsValid() {
+			pos := fe.Position
+			d.Position = &pos
+		}
+
+		diags = append(diags, d)
+		invalid[fe.Field] = true
+	}
+
+	for _, rule := range rules {
+		r := &report{
+			cfg:  c,
+			rule: rule.name,
+		}
+		rule.check(&c.Definition, r)
+
+		// fields which failed to decode hold zero values; don't report them twice
+		for _, d := range r.diags {
+			if !invalid[d.Field] {
+				diags = append(diags, d)
+			}
+		}
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		fi, li := location(diags[i])
+		fj, lj := location(diags[j])
+		if fi != fj {
+			return fi < fj // the config file proper comes first
+		}
+
+		return li < lj
+	})
+
+	return
+}
+
+func location(d Diagnostic) (file string, line int) {
+	if d.Position == nil {
+		return
+	}
+
+	return d.Position.File, d.Position.Line
+}
+
+// rule checks a definition against a single class of constraints.
+type rule struct {
+	name  string
+	check func(*Definition, *report)
+}
+
+var rules = []rule{
+	{"kill_signal", checkKillSignal},
+	{"ports", checkPorts},
+	{"handlers", checkHandlers},
+	{"concurrency", checkConcurrency},
+	{"checks", checkChecks},
+	{"mounts", checkMounts},
+	{"process_groups", checkProcessGroups},
+	{"statics", checkStatics},
+}
+
+type report struct {
+	cfg   *Config
+	rule  string
+	diags []Diagnostic
+}
+
+func (r *report) add(severity Severity, field, format string, v ...interface{}) {
+	d := Diagnostic{
+		Severity: severity,
+		Rule:     r.rule,
+		Field:    field,
+		Message:  fmt.Sprintf(format, v...),
+	}
+
+	if pos := r.cfg.Position(field); pos.IsValid() {
+		d.Position = &pos
+	}
+
+	r.diags = append(r.diags, d)
+}
+
+func (r *report) errorf(field, format string, v ...interface{}) {
+	r.add(SeverityError, field, format, v...)
+}
+
+func (r *report) warnf(field, format string, v ...interface{}) {
+	r.add(SeverityWarning, field, format, v...)
+}
+
+var killSignals = []string{"SIGINT", "SIGTERM", "SIGQUIT", "SIGUSR1", "SIGUSR2", "SIGKILL", "SIGSTOP"}
+
+func checkKillSignal(def *Definition, r *report) {
+	if def.KillSignal != "" && !contains(killSignals, def.KillSignal) {
+		r.errorf("kill_signal", "unsupported signal %q; must be one of %s", def.KillSignal, strings.Join(killSignals, ", "))
+	}
+
+	if def.KillTimeout != nil && *def.KillTimeout < 0 {
+		r.errorf("kill_timeout", "must not be negative")
+	}
+}
+
+func checkPorts(def *Definition, r *report) {
+	public := map[string]string{}
+
+	for i, svc := range def.Services {
+		field := fmt.Sprintf("services[%d]", i)
+
+		switch {
+		case svc.InternalPort == 0:
+			r.errorf(field+".internal_port", "is required")
+		case !validPort(svc.InternalPort):
+			r.errorf(field+".internal_port", "%d is not a valid port number", svc.InternalPort)
+		}
+
+		protocol := svc.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		if protocol != "tcp" && protocol != "udp" {
+			r.errorf(field+".protocol", "unsupported protocol %q; must be tcp or udp", svc.Protocol)
+		}
+
+		for j, port := range svc.Ports {
+			portField := fmt.Sprintf("%s.ports[%d].port", field, j)
+
+			if !validPort(port.Port) {
+				r.errorf(portField, "%d is not a valid port number", port.Port)
+
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%d", protocol, port.Port)
+			if prev, exists := public[key]; exists {
+				r.errorf(portField, "port %d/%s is already exposed by %s", port.Port, protocol, prev)
+			} else {
+				public[key] = portField
+			}
+		}
+	}
+}
+
+var knownHandlers = []string{"http", "tls", "pg_tls", "proxy_proto", "edge_http"}
+
+func checkHandlers(def *Definition, r *report) {
+	for i, svc := range def.Services {
+		for j, port := range svc.Ports {
+			field := fmt.Sprintf("services[%d].ports[%d].handlers", i, j)
+
+			if svc.Protocol == "udp" && len(port.Handlers) > 0 {
+				r.errorf(field, "handlers aren't supported for udp services")
+
+				continue
+			}
+
+			seen := map[string]bool{}
+			for _, h := range port.Handlers {
+				switch {
+				case !contains(knownHandlers, h):
+					r.errorf(field, "unknown handler %q; must be one of %s", h, strings.Join(knownHandlers, ", "))
+				case seen[h]:
+					r.warnf(field, "handler %q is listed more than once", h)
+				}
+				seen[h] = true
+			}
+
+			if port.ForceHTTPS && !seen["http"] {
+				r.warnf(fmt.Sprintf("services[%d].ports[%d].force_https", i, j), "has no effect without the http handler")
+			}
+
+			if port.Port == 80 && seen["tls"] {
+				r.warnf(field, "tls handler on port 80 will break plain HTTP clients")
+			}
+		}
+	}
+}
+
+func checkConcurrency(def *Definition, r *report) {
+	for i, svc := range def.Services {
+		c := svc.Concurrency
+		if c == nil {
+			continue
+		}
+
+		field := fmt.Sprintf("services[%d].concurrency", i)
+
+		if c.Type != "" && c.Type != "connections" && c.Type != "requests" {
+			r.errorf(field+".type", "unsupported type %q; must be connections or requests", c.Type)
+		}
+
+		if c.HardLimit < 0 {
+			r.errorf(field+".hard_limit", "must not be negative")
+		}
+
+		if c.SoftLimit < 0 {
+			r.errorf(field+".soft_limit", "must not be negative")
+		}
+
+		if c.HardLimit > 0 && c.SoftLimit > c.HardLimit {
+			r.errorf(field+".soft_limit", "%d exceeds hard_limit of %d", c.SoftLimit, c.HardLimit)
+		}
+	}
+}
+
+const minCheckInterval = time.Second
+
+var checkMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+func checkChecks(def *Definition, r *report) {
+	for i, svc := range def.Services {
+		for j, c := range svc.TCPChecks {
+			field := fmt.Sprintf("services[%d].tcp_checks[%d]", i, j)
+
+			checkTiming(r, field, c.Interval, c.Timeout, c.GracePeriod, c.RestartLimit)
+		}
+
+		for j, c := range svc.HTTPChecks {
+			field := fmt.Sprintf("services[%d].http_checks[%d]", i, j)
+
+			checkTiming(r, field, c.Interval, c.Timeout, c.GracePeriod, c.RestartLimit)
+
+			if c.Path != "" && !strings.HasPrefix(c.Path, "/") {
+				r.errorf(field+".path", "must start with /")
+			}
+
+			if c.Method != "" && !contains(checkMethods, strings.ToUpper(c.Method)) {
+				r.errorf(field+".method", "unsupported method %q", c.Method)
+			}
+
+			if c.Protocol != "" && c.Protocol != "http" && c.Protocol != "https" {
+				r.errorf(field+".protocol", "unsupported protocol %q; must be http or https", c.Protocol)
+			}
+		}
+	}
+}
+
+func checkTiming(r *report, field string, interval, timeout, gracePeriod Duration, restartLimit *int) {
+	if interval.Duration != 0 && interval.Duration < minCheckInterval {
+		r.errorf(field+".interval", "%s is shorter than the minimum of %s", interval.Duration, minCheckInterval)
+	}
+
+	if timeout.Duration < 0 {
+		r.errorf(field+".timeout", "must not be negative")
+	}
+
+	if interval.Duration != 0 && timeout.Duration >= interval.Duration {
+		r.warnf(field+".timeout", "%s is not shorter than the interval of %s", timeout.Duration, interval.Duration)
+	}
+
+	if gracePeriod.Duration < 0 {
+		r.errorf(field+".grace_period", "must not be negative")
+	}
+
+	if restartLimit != nil && *restartLimit < 0 {
+		r.errorf(field+".restart_limit", "must not be negative")
+	}
+}
+
+var volumeNameRegexp = regexp.MustCompile(`^[a-z0-9_]{1,30}$`)
+
+func checkMounts(def *Definition, r *report) {
+	destinations := map[string]string{}
+
+	for i, m := range def.Mounts {
+		field := fmt.Sprintf("mounts[%d]", i)
+
+		switch {
+		case m.Source == "":
+			r.errorf(field+".source", "is required")
+		case !volumeNameRegexp.MatchString(m.Source):
+			r.errorf(field+".source", "%q is not a valid volume name; use up to 30 lowercase letters, digits and underscores", m.Source)
+		}
+
+		switch dst := m.Destination; {
+		case dst == "":
+			r.errorf(field+".destination", "is required")
+		case !path.IsAbs(dst):
+			r.errorf(field+".destination", "%q must be an absolute path", dst)
+		case path.Clean(dst) == "/":
+			r.errorf(field+".destination", "can't mount a volume at the root directory")
+		default:
+			dst = path.Clean(dst)
+
+			if prev, exists := destinations[dst]; exists {
+				r.errorf(field+".destination", "%s is already mounted by %s", dst, prev)
+			} else {
+				destinations[dst] = field
+			}
+		}
+	}
+}
+
+func checkProcessGroups(def *Definition, r *report) {
+	groups := []string{"app"}
+	if len(def.Processes) > 0 {
+		groups = groups[:0]
+		for name := range def.Processes {
+			groups = append(groups, name)
+		}
+		sort.Strings(groups)
+	}
+
+	for name, cmd := range def.Processes {
+		if strings.TrimSpace(cmd) == "" {
+			r.errorf("processes."+name, "command must not be empty")
+		}
+	}
+
+	check := func(field string, refs []string) {
+		for _, ref := range refs {
+			if !contains(groups, ref) {
+				r.errorf(field, "references undefined process group %q; defined groups are %s", ref, strings.Join(groups, ", "))
+			}
+		}
+	}
+
+	for i, svc := range def.Services {
+		check(fmt.Sprintf("services[%d].processes", i), svc.Processes)
+	}
+
+	for i, m := range def.Mounts {
+		check(fmt.Sprintf("mounts[%d].processes", i), m.Processes)
+	}
+}
+
+func checkStatics(def *Definition, r *report) {
+	prefixes := map[string]string{}
+
+	for i, s := range def.Statics {
+		field := fmt.Sprintf("statics[%d]", i)
+
+		switch {
+		case s.GuestPath == "":
+			r.errorf(field+".guest_path", "is required")
+		case !path.IsAbs(s.GuestPath):
+			r.errorf(field+".guest_path", "%q must be an absolute path", s.GuestPath)
+		}
+
+		switch {
+		case s.URLPrefix == "":
+			r.errorf(field+".url_prefix", "is required")
+		case !strings.HasPrefix(s.URLPrefix, "/"):
+			r.errorf(field+".url_prefix", "%q must start with /", s.URLPrefix)
+		default:
+			if prev, exists := prefixes[s.URLPrefix]; exists {
+				r.errorf(field+".url_prefix", "%s is already served by %s", s.URLPrefix, prev)
+			} else {
+				prefixes[s.URLPrefix] = field
+			}
+		}
+	}
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+func contains(set []string, s string) bool {
+	for _, e := range set {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateValidConfig(t *testing.T) {
+	const path = "./testdata/full.toml"
+
+	p, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	assert.Empty(t, p.Validate())
+}
+
+func TestValidateReportsRuleViolations(t *testing.T) {
+	const path = "./testdata/rules.toml"
+
+	p, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	diags := p.Validate()
+	assert.True(t, HasErrors(diags))
+
+	type finding struct {
+		Severity Severity
+		Rule     string
+		Field    string
+		Line     int
+	}
+
+	var got []finding
+	for _, d := range diags {
+		require.NotNil(t, d.Position, d.Field)
+		got = append(got, finding{d.Severity, d.Rule, d.Field, d.Position.Line})
+	}
+
+	assert.Equal(t, []finding{
+		{SeverityError, "process_groups", "mounts[0].processes", 9},
+		{SeverityError, "statics", "statics[0].guest_path", 12},
+		{SeverityError, "handlers", "services[0].ports[0].handlers", 20},
+		{SeverityWarning, "handlers", "services[0].ports[1].handlers", 24},
+		{SeverityError, "ports", "services[0].ports[1].port", 25},
+		{SeverityError, "checks", "services[0].http_checks[0].interval", 28},
+		{SeverityWarning, "checks", "services[0].http_checks[0].timeout", 29},
+		{SeverityError, "checks", "services[0].http_checks[0].path", 30},
+	}, got)
+}
+
+func TestValidateReportsFieldErrorsOnce(t *testing.T) {
+	const path = "./testdata/invalid.toml"
+
+	p, err := LoadConfig(path)
+	require.Error(t, err)
+
+	diags := p.Validate()
+	require.Len(t, diags, 3)
+
+	for _, d := range diags {
+		assert.Equal(t, "schema", d.Rule)
+		assert.Equal(t, SeverityError, d.Severity)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/docker/docker/builder/dockerignore"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/fileutils"
+
+	"github.com/superfly/flyctl/helpers"
 )
 
 type archiveOptions struct {
@@ -51,16 +53,67 @@ func archiveDirectory(options archiveOptions) (io.ReadCloser, error) {
 	return r, nil
 }
 
-func readDockerignore(workingDir string) ([]string, error) {
-	file, err := os.Open(filepath.Join(workingDir, ".dockerignore"))
-	if os.IsNotExist(err) {
+// The names of the files which filter build contexts.
+const (
+	flyignoreName    = ".flyignore"
+	dockerignoreName = ".dockerignore"
+)
+
+// ResolveIgnoreFile returns the path to the file which filters the build
+// context in workingDir, in order of precedence: its .flyignore file, the
+// <Dockerfile>.dockerignore file next to the given Dockerfile, which defaults
+// to the one in workingDir, or its .dockerignore file. It returns an empty
+// string in case there's none.
+func ResolveIgnoreFile(workingDir, dockerfile string) string {
+	if dockerfile == "" {
+		dockerfile = resolveDockerfile(workingDir)
+	}
+
+	candidates := []string{filepath.Join(workingDir, flyignoreName)}
+	if dockerfile != "" {
+		if !filepath.IsAbs(dockerfile) {
+			dockerfile = filepath.Join(workingDir, dockerfile)
+		}
+		candidates = append(candidates, dockerfile+dockerignoreName)
+	}
+	candidates = append(candidates, filepath.Join(workingDir, dockerignoreName))
+
+	for _, path := range candidates {
+		if helpers.FileExists(path) {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// readDockerignore returns the patterns which filter the build context in
+// workingDir for builds with the given Dockerfile, as ResolveIgnoreFile
+// resolves them.
+func readDockerignore(workingDir, dockerfile string) ([]string, error) {
+	path := ResolveIgnoreFile(workingDir, dockerfile)
+	if path == "" {
 		return []string{}, nil
-	} else if err != nil {
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	return parseDockerignore(file)
+	excludes, err := parseDockerignore(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the .dockerignore file the ignore file takes precedence over out of
+	// the context, since builders would apply it on top
+	if path != filepath.Join(workingDir, dockerignoreName) {
+		excludes = append(excludes, dockerignoreName)
+	}
+
+	return excludes, nil
 }
 
 func parseDockerignore(r io.Reader) ([]string, error) {
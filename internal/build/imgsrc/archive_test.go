@@ -173,6 +173,44 @@ func TestParseDockerignore(t *testing.T) {
 	}
 }
 
+func TestReadDockerignore(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile":                          "FROM scratch",
+		"deploy/Dockerfile.prod":              "FROM scratch",
+		".dockerignore":                       "node_modules",
+		"deploy/Dockerfile.prod.dockerignore": "tmp",
+	})
+
+	prod := filepath.Join(dir, "deploy", "Dockerfile.prod")
+
+	assert.Equal(t, filepath.Join(dir, ".dockerignore"), ResolveIgnoreFile(dir, ""))
+	assert.Equal(t, prod+".dockerignore", ResolveIgnoreFile(dir, prod))
+	assert.Equal(t, prod+".dockerignore", ResolveIgnoreFile(dir, "deploy/Dockerfile.prod"))
+
+	excludes, err := readDockerignore(dir, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node_modules", "fly.toml"}, excludes)
+
+	// .dockerignore files other ignore files take precedence over are kept out
+	// of the context
+	excludes, err = readDockerignore(dir, prod)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tmp", "fly.toml", ".dockerignore"}, excludes)
+
+	writeFiles(t, dir, map[string]string{".flyignore": "*.md"})
+
+	assert.Equal(t, filepath.Join(dir, ".flyignore"), ResolveIgnoreFile(dir, prod))
+
+	excludes, err = readDockerignore(dir, prod)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"*.md", "fly.toml", ".dockerignore"}, excludes)
+
+	excludes, err = readDockerignore(t.TempDir(), "")
+	assert.NoError(t, err)
+	assert.Empty(t, excludes)
+}
+
 func TestIsPathInRoot(t *testing.T) {
 	cases := []struct {
 		filename string
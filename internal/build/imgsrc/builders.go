@@ -0,0 +1,100 @@
+package imgsrc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/superfly/flyctl/pkg/iostreams"
+)
+
+// ImageBuilder builds deployment images from source.
+type ImageBuilder interface {
+	// Name returns the name of the builder, which is what the strategy key of
+	// the build section of app configs selects it by.
+	Name() string
+	// Run builds the image opts describe. Builders which don't apply to opts,
+	// such as the Dockerfile one for apps without a Dockerfile, return a nil
+	// image and a nil error.
+	Run(ctx context.Context, dockerFactory *DockerClientFactory, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error)
+}
+
+var (
+	buildersMu sync.RWMutex
+	builders   []ImageBuilder
+)
+
+func init() {
+	// the order builders are registered in is the order Resolver.BuildImage
+	// tries them in
+	RegisterBuilder(&buildpacksBuilder{})
+	RegisterBuilder(&dockerfileBuilder{})
+	RegisterBuilder(&builtinBuilder{})
+	RegisterBuilder(&daemonlessBuilder{})
+}
+
+// RegisterBuilder makes the given ImageBuilder available to Resolvers, which
+// try it after the ones registered before it unless a strategy is selected.
+// RegisterBuilder panics in case a builder by the same name has already been
+// registered.
+func RegisterBuilder(b ImageBuilder) {
+	registerBuilder(b)
+}
+
+// registerBuilder registers b, as RegisterBuilder does, and returns a func
+// which unregisters it again.
+func registerBuilder(b ImageBuilder) (unregister func()) {
+	buildersMu.Lock()
+	defer buildersMu.Unlock()
+
+	for _, registered := range builders {
+		if registered.Name() == b.Name() {
+			panic(fmt.Sprintf("imgsrc: builder %q registered twice", b.Name()))
+		}
+	}
+
+	builders = append(builders, b)
+
+	return func() {
+		buildersMu.Lock()
+		defer buildersMu.Unlock()
+
+		for i, registered := range builders {
+			if registered == b {
+				builders = append(builders[:i:i], builders[i+1:]...)
+
+				break
+			}
+		}
+	}
+}
+
+// Builders returns the registered ImageBuilders, in the order they were
+// registered in.
+func Builders() []ImageBuilder {
+	buildersMu.RLock()
+	defer buildersMu.RUnlock()
+
+	return append([]ImageBuilder(nil), builders...)
+}
+
+// Builder returns the registered ImageBuilder by the given name or nil in
+// case there's none.
+func Builder(name string) ImageBuilder {
+	for _, b := range Builders() {
+		if b.Name() == name {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// BuilderNames returns the names of the registered ImageBuilders.
+func BuilderNames() (names []string) {
+	for _, b := range Builders() {
+		names = append(names, b.Name())
+	}
+
+	return
+}
@@ -0,0 +1,65 @@
+package imgsrc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/pkg/iostreams"
+)
+
+type fakeBuilder struct {
+	name string
+	img  *DeploymentImage
+	opts ImageOptions
+}
+
+func (b *fakeBuilder) Name() string {
+	return b.name
+}
+
+func (b *fakeBuilder) Run(ctx context.Context, dockerFactory *DockerClientFactory, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error) {
+	b.opts = opts
+
+	return b.img, nil
+}
+
+func TestBuilders(t *testing.T) {
+	assert.Equal(t, []string{"buildpacks", "dockerfile", "builtin"}, BuilderNames()[:3])
+	assert.Nil(t, Builder("kaniko"))
+	assert.Panics(t, func() {
+		RegisterBuilder(&fakeBuilder{name: "dockerfile"})
+	})
+}
+
+func TestBuildImageWithStrategy(t *testing.T) {
+	fake := &fakeBuilder{name: "fake", img: &DeploymentImage{ID: "id"}}
+	t.Cleanup(registerBuilder(fake))
+
+	r := &Resolver{dockerFactory: &DockerClientFactory{mode: DockerDaemonTypeNone}}
+	streams, _, _, _ := iostreams.Test()
+
+	img, err := r.BuildImage(context.Background(), streams, ImageOptions{AppName: "app", Tag: "registry.fly.io/app:1", Strategy: "fake"})
+	require.NoError(t, err)
+	assert.Equal(t, "id", img.ID)
+	assert.Equal(t, "registry.fly.io/app:1", fake.opts.Tag)
+
+	_, err = r.BuildImage(context.Background(), streams, ImageOptions{AppName: "app", Strategy: "kaniko"})
+	assert.EqualError(t, err, `unknown build strategy "kaniko"; available strategies are buildpacks, dockerfile, builtin, daemonless, fake`)
+
+	_, err = r.BuildImage(context.Background(), streams, ImageOptions{AppName: "app", Strategy: "dockerfile"})
+	assert.Equal(t, errDockerUnavailable, err)
+}
+
+func TestRegisterBuilderUnregister(t *testing.T) {
+	names := BuilderNames()
+
+	unregister := registerBuilder(&fakeBuilder{name: "fake"})
+	assert.Equal(t, append(names, "fake"), BuilderNames())
+
+	unregister()
+	assert.Equal(t, names, BuilderNames())
+	assert.Nil(t, Builder("fake"))
+}
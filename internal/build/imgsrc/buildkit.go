@@ -17,9 +17,11 @@ import (
 	buildkitClient "github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/session"
 	"github.com/moby/buildkit/session/auth"
+	"github.com/moby/buildkit/session/filesync"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 	"github.com/superfly/flyctl/flyctl"
+	fstypes "github.com/tonistiigi/fsutil/types"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -51,6 +53,24 @@ func createBuildSession(contextDir string) (*session.Session, error) {
 	return s, nil
 }
 
+// newContextSyncProvider returns the session attachable which syncs the build
+// context in dir, honoring the given .dockerignore patterns, and the directory
+// of the Dockerfile with BuildKit. Since the session is keyed by dir, BuildKit
+// only transfers the files which changed since the last build.
+func newContextSyncProvider(dir, dockerfile string, excludes []string) session.Attachable {
+	return filesync.NewFSSyncProvider([]filesync.SyncedDir{
+		{Name: "context", Dir: dir, Excludes: excludes, Map: resetUIDAndGID},
+		{Name: "dockerfile", Dir: filepath.Dir(dockerfile)},
+	})
+}
+
+func resetUIDAndGID(_ string, st *fstypes.Stat) bool {
+	st.Uid = 0
+	st.Gid = 0
+
+	return true
+}
+
 func getBuildSharedKey(dir string) string {
 	// build session is hash of build dir with node based randomness
 	s := sha256.Sum256([]byte(fmt.Sprintf("%s:%s", getBuildNodeID(), dir)))
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/buildpacks/pack"
 	projectTypes "github.com/buildpacks/pack/pkg/project/types"
@@ -17,10 +18,11 @@ import (
 type buildpacksBuilder struct{}
 
 func (*buildpacksBuilder) Name() string {
-	return "Buildpacks"
+	return "buildpacks"
 }
 
-func (*buildpacksBuilder) Run(ctx context.Context, dockerFactory *dockerClientFactory, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error) {
+func (bb *buildpacksBuilder) Run(ctx context.Context, dockerFactory *DockerClientFactory, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error) {
+	started := time.Now()
 	if !dockerFactory.mode.IsAvailable() {
 		terminal.Debug("docker daemon not available, skipping")
 		return nil, nil
@@ -31,6 +33,10 @@ func (*buildpacksBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 		return nil, nil
 	}
 
+	if opts.platform() != DefaultPlatform {
+		return nil, fmt.Errorf("buildpacks builds only support the %s platform", DefaultPlatform)
+	}
+
 	builder := opts.Builder
 	buildpacks := opts.Buildpacks
 
@@ -55,9 +61,9 @@ func (*buildpacksBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 	msg := fmt.Sprintf("docker host: %s %s %s", serverInfo.ServerVersion, serverInfo.OSType, serverInfo.Architecture)
 	cmdfmt.PrintDone(streams.ErrOut, msg)
 
-	excludes, err := readDockerignore(opts.WorkingDir)
+	excludes, err := readDockerignore(opts.WorkingDir, "")
 	if err != nil {
-		return nil, errors.Wrap(err, "error reading .dockerignore")
+		return nil, errors.Wrap(err, "error reading ignore file")
 	}
 
 	err = packClient.Build(ctx, pack.BuildOptions{
@@ -80,6 +86,11 @@ func (*buildpacksBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 		return nil, err
 	}
 
+	labels := withDuration(buildLabels(opts, bb.Name(), started), started)
+	if _, err = labelImage(ctx, docker, opts.Tag, labels); err != nil {
+		return nil, err
+	}
+
 	cmdfmt.PrintDone(streams.ErrOut, "Building image done")
 
 	if opts.Publish {
@@ -92,15 +103,20 @@ func (*buildpacksBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 		cmdfmt.PrintDone(streams.ErrOut, "Pushing image done")
 	}
 
+	if err := exportImage(ctx, docker, streams, opts); err != nil {
+		return nil, err
+	}
+
 	img, err := findImageWithDocker(ctx, docker, opts.Tag)
 	if err != nil {
 		return nil, err
 	}
 
 	return &DeploymentImage{
-		ID:   img.ID,
-		Tag:  opts.Tag,
-		Size: img.Size,
+		ID:     img.ID,
+		Tag:    opts.Tag,
+		Size:   img.Size,
+		Labels: labels,
 	}, nil
 }
 
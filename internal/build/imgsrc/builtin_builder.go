@@ -2,6 +2,7 @@ package imgsrc
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/superfly/flyctl/internal/build/imgsrc/builtins"
@@ -14,10 +15,11 @@ import (
 type builtinBuilder struct{}
 
 func (ds *builtinBuilder) Name() string {
-	return "Builtin"
+	return "builtin"
 }
 
-func (ds *builtinBuilder) Run(ctx context.Context, dockerFactory *dockerClientFactory, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error) {
+func (ds *builtinBuilder) Run(ctx context.Context, dockerFactory *DockerClientFactory, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error) {
+	started := time.Now()
 
 	if !dockerFactory.mode.IsAvailable() {
 		terminal.Debug("docker daemon not available, skipping")
@@ -29,7 +31,7 @@ func (ds *builtinBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 		return nil, nil
 	}
 
-	builtin, err := builtins.GetBuiltin(opts.BuiltIn)
+	builtin, err := findBuiltin(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -52,9 +54,9 @@ func (ds *builtinBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 		compressed: dockerFactory.mode.IsRemote(),
 	}
 
-	excludes, err := readDockerignore(opts.WorkingDir)
+	excludes, err := readDockerignore(opts.WorkingDir, "")
 	if err != nil {
-		return nil, errors.Wrap(err, "error reading .dockerignore")
+		return nil, errors.Wrap(err, "error reading ignore file")
 	}
 	archiveOpts.exclusions = excludes
 
@@ -86,13 +88,19 @@ func (ds *builtinBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 		return nil, fmt.Errorf("error parsing build args: %w", err)
 	}
 
-	imageID, err = runClassicBuild(ctx, streams, docker, r, opts, "", buildArgs)
+	labels := buildLabels(opts, ds.Name(), started)
+
+	imageID, err = runClassicBuild(ctx, streams, docker, r, opts, "", buildArgs, labels)
 	if err != nil {
 		return nil, errors.Wrap(err, "error building")
 	}
 
 	cmdfmt.PrintDone(streams.ErrOut, "Building image done")
 
+	// the labels were part of the build, so only the ones returned record how
+	// long it took
+	labels = withDuration(labels, started)
+
 	if opts.Publish {
 		cmdfmt.PrintBegin(streams.ErrOut, "Pushing image to fly")
 
@@ -103,6 +111,10 @@ func (ds *builtinBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 		cmdfmt.PrintDone(streams.ErrOut, "Pushing image done")
 	}
 
+	if err := exportImage(ctx, docker, streams, opts); err != nil {
+		return nil, err
+	}
+
 	img, _, err := docker.ImageInspectWithRaw(ctx, imageID)
 	if err != nil {
 		return nil, errors.Wrap(err, "count not find built image")
@@ -110,9 +122,21 @@ func (ds *builtinBuilder) Run(ctx context.Context, dockerFactory *dockerClientFa
 	fmt.Println(img)
 
 	return &DeploymentImage{
-		ID:   img.ID,
-		Tag:  opts.Tag,
-		Size: img.Size,
+		ID:     img.ID,
+		Tag:    opts.Tag,
+		Size:   img.Size,
+		Labels: labels,
 	}, nil
 
 }
+
+// findBuiltin returns the builtin opts select, out of the compiled-in and the
+// user-defined ones.
+func findBuiltin(ctx context.Context, opts ImageOptions) (*builtins.Builtin, error) {
+	var sources []string
+	if opts.BuiltinSource != "" {
+		sources = append(sources, opts.BuiltinSource)
+	}
+
+	return builtins.Find(ctx, opts.BuiltIn, sources...)
+}
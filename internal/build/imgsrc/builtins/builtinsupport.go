@@ -20,6 +20,9 @@ type Builtin struct {
 	Details     string
 	Template    string
 	Settings    []Setting
+	// Source is the file or URL user-defined builtins are defined in. It's
+	// empty for the ones compiled in.
+	Source      string
 	settingsMap map[string]Setting
 }
 
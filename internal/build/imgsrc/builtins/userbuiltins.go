@@ -0,0 +1,343 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/viper"
+
+	"github.com/superfly/flyctl/flyctl"
+)
+
+// builtinsFile is the format of the files user-defined builtins are defined
+// in. Each file may define any number of builtins, as in:
+//
+//	[[builtin]]
+//	name = "rails"
+//	description = "Rails builtin"
+//	template = '''
+//	FROM ruby:{{.version}}
+//	...
+//	'''
+//
+//	[[builtin.settings]]
+//	name = "version"
+//	default = "3.1"
+//	description = "Version of Ruby to use"
+type builtinsFile struct {
+	Builtins []builtinDef `toml:"builtin"`
+}
+
+type builtinDef struct {
+	Name        string `toml:"name"`
+	Description string `toml:"description"`
+	Details     string `toml:"details"`
+	Template    string `toml:"template"`
+	Settings    []struct {
+		Name        string      `toml:"name"`
+		Default     interface{} `toml:"default"`
+		Description string      `toml:"description"`
+	} `toml:"settings"`
+}
+
+// fetchTimeout bounds the time fetching builtins from URLs may take.
+const fetchTimeout = 30 * time.Second
+
+// fetched caches the builtins files fetched from URLs, by URL, so that each
+// is fetched once per run.
+var (
+	fetchedMu sync.Mutex
+	fetched   = map[string][]byte{}
+)
+
+// UserDir returns the directory user-defined builtins are loaded from by
+// default.
+func UserDir() string {
+	return filepath.Join(flyctl.ConfigDir(), "builtins")
+}
+
+// DefaultSources returns the sources user-defined builtins are loaded from by
+// default: UserDir, in case it exists, and the builtins file the hidden
+// builtinsfile flag specifies, if any.
+func DefaultSources() (sources []string) {
+	if fi, err := os.Stat(UserDir()); err == nil && fi.IsDir() {
+		sources = append(sources, UserDir())
+	}
+
+	if file := viper.GetString(flyctl.ConfigBuiltinsfile); file != "" {
+		sources = append(sources, file)
+	}
+
+	return
+}
+
+// Load loads the builtins the given sources define. Sources are either TOML
+// files, directories of them or http(s) URLs of them. Builtins are validated
+// as they're loaded; names must be unique across the compiled-in builtins and
+// the sources.
+func Load(ctx context.Context, sources ...string) ([]Builtin, error) {
+	seen := map[string]string{}
+	for _, b := range basicbuiltins {
+		seen[b.Name] = "flyctl"
+	}
+
+	var loaded []Builtin
+	for _, src := range sources {
+		files, err := expandSource(src)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			data, err := readSource(ctx, file)
+			if err != nil {
+				return nil, fmt.Errorf("failed reading builtins from %s: %w", file, err)
+			}
+
+			builtins, err := parseBuiltins(data, file)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, b := range builtins {
+				if prev, ok := seen[b.Name]; ok {
+					return nil, fmt.Errorf("builtin %s of %s is already defined by %s", b.Name, file, prev)
+				}
+				seen[b.Name] = file
+
+				loaded = append(loaded, b)
+			}
+		}
+	}
+
+	return loaded, nil
+}
+
+// All returns the compiled-in builtins followed by the user-defined ones the
+// default sources and the given ones define.
+func All(ctx context.Context, sources ...string) ([]Builtin, error) {
+	user, err := Load(ctx, append(DefaultSources(), sources...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(GetBuiltins(), user...), nil
+}
+
+// Find returns the builtin named name. The compiled-in builtins are checked
+// first; only in case none of them is named name are the default sources and
+// the given ones loaded, file by file, until one defines name. Errors of
+// sources which fail to load are reported only in case no other source defines
+// name, and invalid builtins only in case they're the one named name.
+func Find(ctx context.Context, name string, sources ...string) (*Builtin, error) {
+	if b, err := GetBuiltin(name); err == nil {
+		return b, nil
+	}
+
+	var failed []string
+	for _, src := range append(DefaultSources(), sources...) {
+		files, err := expandSource(src)
+		if err != nil {
+			failed = append(failed, err.Error())
+
+			continue
+		}
+
+		for _, file := range files {
+			b, err := findInFile(ctx, name, file)
+			switch {
+			case err != nil:
+				failed = append(failed, err.Error())
+			case b != nil:
+				if err := b.validate(); err != nil {
+					return nil, fmt.Errorf("invalid builtin %s of %s: %w", name, file, err)
+				}
+
+				return b, nil
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("no builtin with %s name supported; some builtins failed to load:\n  %s", name, strings.Join(failed, "\n  "))
+	}
+
+	return nil, fmt.Errorf("no builtin with %s name supported", name)
+}
+
+// findInFile returns the unvalidated builtin named name the builtins file at
+// src defines, if any.
+func findInFile(ctx context.Context, name, src string) (*Builtin, error) {
+	data, err := readSource(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading builtins from %s: %w", src, err)
+	}
+
+	var f builtinsFile
+	if _, err := toml.Decode(string(data), &f); err != nil {
+		return nil, fmt.Errorf("failed parsing builtins of %s: %w", src, err)
+	}
+
+	for _, def := range f.Builtins {
+		if def.Name == name {
+			b := def.builtin(src)
+
+			return &b, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// expandSource returns the files src denotes: the TOML files of directories,
+// in lexical order, or src itself.
+func expandSource(src string) ([]string, error) {
+	if isURL(src) {
+		return []string{src}, nil
+	}
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading builtins from %s: %w", src, err)
+	}
+
+	if !fi.IsDir() {
+		return []string{src}, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(src, "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func isURL(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+func readSource(ctx context.Context, src string) ([]byte, error) {
+	if !isURL(src) {
+		return os.ReadFile(src)
+	}
+
+	fetchedMu.Lock()
+	defer fetchedMu.Unlock()
+
+	if data, ok := fetched[src]; ok {
+		return data, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	fetched[src] = data
+
+	return data, nil
+}
+
+// parseBuiltins parses and validates the builtins data, read from src,
+// defines.
+func parseBuiltins(data []byte, src string) ([]Builtin, error) {
+	var f builtinsFile
+	if _, err := toml.Decode(string(data), &f); err != nil {
+		return nil, fmt.Errorf("failed parsing builtins of %s: %w", src, err)
+	}
+
+	builtins := make([]Builtin, 0, len(f.Builtins))
+	for _, def := range f.Builtins {
+		b := def.builtin(src)
+		if err := b.validate(); err != nil {
+			return nil, fmt.Errorf("invalid builtin %s of %s: %w", b.Name, src, err)
+		}
+
+		builtins = append(builtins, b)
+	}
+
+	return builtins, nil
+}
+
+// builtin returns the Builtin def defines, read from src.
+func (def *builtinDef) builtin(src string) Builtin {
+	b := Builtin{
+		Name:        def.Name,
+		Description: def.Description,
+		Details:     def.Details,
+		Template:    def.Template,
+		Source:      src,
+	}
+
+	for _, s := range def.Settings {
+		b.Settings = append(b.Settings, Setting{
+			Name:        s.Name,
+			Default:     s.Default,
+			Description: s.Description,
+		})
+	}
+
+	return b
+}
+
+// validate checks that b is named and that its template renders with the
+// defaults of its settings, referencing no others.
+func (b *Builtin) validate() error {
+	switch {
+	case b.Name == "":
+		return fmt.Errorf("the name is missing")
+	case strings.TrimSpace(b.Template) == "":
+		return fmt.Errorf("the template is missing")
+	}
+
+	names := map[string]bool{}
+	for _, s := range b.Settings {
+		if s.Name == "" {
+			return fmt.Errorf("a setting is missing its name")
+		}
+		if names[s.Name] {
+			return fmt.Errorf("setting %s is defined more than once", s.Name)
+		}
+		names[s.Name] = true
+	}
+
+	tmpl, err := template.New(b.Name).Option("missingkey=error").Parse(b.Template)
+	if err != nil {
+		return err
+	}
+
+	if err := tmpl.Execute(io.Discard, b.ResolveSettings(nil)); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package builtins
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const railsBuiltins = `
+[[builtin]]
+name = "rails"
+description = "Rails builtin"
+template = '''
+FROM ruby:{{.version}}
+COPY . /app
+'''
+
+[[builtin.settings]]
+name = "version"
+default = "3.1"
+description = "Version of Ruby to use"
+`
+
+func writeBuiltins(t *testing.T, dir, name, data string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+
+	return path
+}
+
+func TestParseBuiltins(t *testing.T) {
+	builtins, err := parseBuiltins([]byte(railsBuiltins), "rails.toml")
+	require.NoError(t, err)
+	require.Len(t, builtins, 1)
+
+	b := builtins[0]
+	assert.Equal(t, "rails", b.Name)
+	assert.Equal(t, "rails.toml", b.Source)
+	assert.Equal(t, "3.1", b.GetSetting("version").Default)
+
+	dockerfile, err := b.GetVDockerfile(map[string]interface{}{"version": "3.2", "other": "x"})
+	require.NoError(t, err)
+	assert.Equal(t, "FROM ruby:3.2\nCOPY . /app\n", dockerfile)
+}
+
+func TestParseBuiltinsInvalid(t *testing.T) {
+	cases := map[string]struct {
+		data string
+		err  string
+	}{
+		"missing name": {
+			data: "[[builtin]]\ntemplate = 'FROM alpine'\n",
+			err:  "the name is missing",
+		},
+		"missing template": {
+			data: "[[builtin]]\nname = 'alpine'\n",
+			err:  "the template is missing",
+		},
+		"undeclared setting": {
+			data: "[[builtin]]\nname = 'alpine'\ntemplate = 'FROM alpine:{{.version}}'\n",
+			err:  `map has no entry for key "version"`,
+		},
+		"duplicate setting": {
+			data: "[[builtin]]\nname = 'alpine'\ntemplate = 'FROM alpine'\n[[builtin.settings]]\nname = 'a'\n[[builtin.settings]]\nname = 'a'\n",
+			err:  "setting a is defined more than once",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseBuiltins([]byte(c.data), "test.toml")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.err)
+		})
+	}
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeBuiltins(t, dir, "rails.toml", railsBuiltins)
+	writeBuiltins(t, dir, "alpine.toml", "[[builtin]]\nname = 'alpine'\ntemplate = 'FROM alpine'\n")
+	writeBuiltins(t, dir, "README.md", "not builtins")
+
+	builtins, err := Load(context.Background(), dir)
+	require.NoError(t, err)
+	require.Len(t, builtins, 2)
+	assert.Equal(t, "alpine", builtins[0].Name)
+	assert.Equal(t, "rails", builtins[1].Name)
+}
+
+func TestLoadDuplicates(t *testing.T) {
+	dir := t.TempDir()
+
+	path := writeBuiltins(t, dir, "node.toml", "[[builtin]]\nname = 'node'\ntemplate = 'FROM node'\n")
+	_, err := Load(context.Background(), path)
+	assert.EqualError(t, err, "builtin node of "+path+" is already defined by flyctl")
+
+	path = writeBuiltins(t, dir, "rails.toml", railsBuiltins)
+	_, err = Load(context.Background(), path, path)
+	assert.EqualError(t, err, "builtin rails of "+path+" is already defined by "+path)
+}
+
+func TestLoadURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rails.toml" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(railsBuiltins))
+	}))
+	defer srv.Close()
+
+	builtins, err := Load(context.Background(), srv.URL+"/rails.toml")
+	require.NoError(t, err)
+	require.Len(t, builtins, 1)
+	assert.Equal(t, srv.URL+"/rails.toml", builtins[0].Source)
+
+	_, err = Load(context.Background(), srv.URL+"/missing.toml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected status 404")
+}
+
+func TestFind(t *testing.T) {
+	path := writeBuiltins(t, t.TempDir(), "rails.toml", railsBuiltins)
+
+	b, err := Find(context.Background(), "rails", path)
+	require.NoError(t, err)
+	assert.Equal(t, path, b.Source)
+
+	b, err = Find(context.Background(), "node", path)
+	require.NoError(t, err)
+	assert.Empty(t, b.Source)
+
+	_, err = Find(context.Background(), "php", path)
+	assert.EqualError(t, err, "no builtin with php name supported")
+}
+
+func TestFindBrokenSources(t *testing.T) {
+	dir := t.TempDir()
+	broken := writeBuiltins(t, dir, "broken.toml", "[[builtin]\n")
+	invalid := writeBuiltins(t, dir, "invalid.toml", "[[builtin]]\nname = 'php'\ntemplate = 'FROM php:{{.version}}'\n")
+	rails := writeBuiltins(t, t.TempDir(), "rails.toml", railsBuiltins+"\n[[builtin]]\nname = 'php'\n")
+
+	// compiled-in builtins are found without loading any source
+	b, err := Find(context.Background(), "node", broken, filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Equal(t, "node", b.Name)
+
+	// as are user-defined builtins, regardless of the invalid ones the same
+	// file or other sources define
+	b, err = Find(context.Background(), "rails", broken, rails)
+	require.NoError(t, err)
+	assert.Equal(t, rails, b.Source)
+
+	_, err = Find(context.Background(), "php", invalid)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid builtin php of "+invalid)
+
+	_, err = Find(context.Background(), "elixir", broken)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no builtin with elixir name supported; some builtins failed to load")
+	assert.Contains(t, err.Error(), "failed parsing builtins of "+broken)
+}
+
+func TestFindFetchesOnce(t *testing.T) {
+	var fetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		_, _ = w.Write([]byte(railsBuiltins))
+	}))
+	defer srv.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err := Find(context.Background(), "rails", srv.URL+"/rails.toml")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, fetches)
+}
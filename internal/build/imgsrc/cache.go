@@ -0,0 +1,305 @@
+package imgsrc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/pkg/errors"
+
+	"github.com/superfly/flyctl/helpers"
+	"github.com/superfly/flyctl/pkg/iostreams"
+	"github.com/superfly/flyctl/terminal"
+)
+
+// The types of Cache.
+const (
+	// CacheRegistry denotes caches stored as images in a registry.
+	CacheRegistry = "registry"
+	// CacheLocal denotes caches stored in a local directory.
+	CacheLocal = "local"
+)
+
+// localCacheArchive is the name of the archive local caches are stored as,
+// in their directory.
+const localCacheArchive = "cache.tar"
+
+// Cache is a build cache Dockerfile builds import from or export to. Caches
+// are images carrying inline cache metadata, which BuildKit reuses the layers
+// of the final stage of; intermediate stages aren't cached, so caches aren't
+// exported from multi-stage builds. Local caches are such images too, saved
+// with docker save into their directory and loaded into the docker daemon
+// before the builds which import them.
+type Cache struct {
+	Type string
+	// Ref is the image registry caches are stored as.
+	Ref string
+	// Path is the directory local caches are stored in.
+	Path string
+}
+
+// ParseCache parses a Cache of the form type=registry,ref=<image> or
+// type=local,src=<dir> (dest=<dir> for caches exported to). A lone image
+// reference denotes a registry cache.
+func ParseCache(spec string) (*Cache, error) {
+	if !strings.Contains(spec, "=") {
+		spec = "type=registry,ref=" + spec
+	}
+
+	attrs, err := parseAttrs(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache %q: %w", spec, err)
+	}
+
+	c := &Cache{Type: attrs["type"]}
+	delete(attrs, "type")
+
+	switch c.Type {
+	case CacheRegistry:
+		c.Ref = attrs["ref"]
+		delete(attrs, "ref")
+
+		if c.Ref == "" {
+			return nil, fmt.Errorf("invalid cache %q: the ref is missing", spec)
+		}
+		if _, err := name.ParseReference(c.Ref); err != nil {
+			return nil, fmt.Errorf("invalid cache %q: %w", spec, err)
+		}
+	case CacheLocal:
+		for _, k := range []string{"src", "dest"} {
+			if v, ok := attrs[k]; ok {
+				c.Path = expandHome(v)
+				delete(attrs, k)
+			}
+		}
+
+		if c.Path == "" {
+			return nil, fmt.Errorf("invalid cache %q: the directory is missing", spec)
+		}
+	default:
+		return nil, fmt.Errorf("invalid cache %q: expected type=%s or type=%s", spec, CacheRegistry, CacheLocal)
+	}
+
+	for k := range attrs {
+		return nil, fmt.Errorf("invalid cache %q: unsupported option %s", spec, k)
+	}
+
+	return c, nil
+}
+
+func (c *Cache) String() string {
+	if c.Type == CacheLocal {
+		return "local:" + c.Path
+	}
+
+	return c.Ref
+}
+
+// parseAttrs parses comma separated key=value pairs.
+func parseAttrs(spec string) (map[string]string, error) {
+	attrs := map[string]string{}
+
+	for _, field := range strings.Split(spec, ",") {
+		k, v := splitPair(field, "=")
+		if k = strings.TrimSpace(k); k == "" || !strings.Contains(field, "=") {
+			return nil, fmt.Errorf("expected key=value, got %q", field)
+		}
+
+		attrs[strings.ToLower(k)] = strings.TrimSpace(v)
+	}
+
+	return attrs, nil
+}
+
+// expandHome expands a leading ~ of path to the home directory of the user.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, path[1:])
+}
+
+// checkCacheStages returns an error in case opts export a cache from a build of
+// the given Dockerfile which has stages before the one built, as those would
+// never be cached.
+func checkCacheStages(dockerfile []byte, opts ImageOptions) error {
+	if opts.CacheTo == nil {
+		return nil
+	}
+
+	res, err := parser.Parse(bytes.NewReader(dockerfile))
+	if err != nil {
+		return errors.Wrap(err, "error parsing Dockerfile")
+	}
+
+	stages, _, err := instructions.Parse(res.AST)
+	if err != nil {
+		return errors.Wrap(err, "error parsing Dockerfile")
+	}
+
+	n := len(stages)
+	for i, s := range stages {
+		if opts.Target != "" && strings.EqualFold(s.Name, opts.Target) {
+			n = i + 1
+
+			break
+		}
+	}
+
+	if n > 1 {
+		return fmt.Errorf("can't export a cache to %s from a multi-stage build: caches carry inline cache metadata, which covers only the final stage, so the stages before it would never be cached", opts.CacheTo)
+	}
+
+	return nil
+}
+
+// localCacheTag is the tag the local caches of the given app are loaded into
+// the docker daemon as.
+func localCacheTag(appName string) string {
+	return fmt.Sprintf("flyctl-cache/%s:latest", appName)
+}
+
+// cacheTag returns the tag the image built is additionally tagged as for it to
+// be exported to the Cache opts specify, if any.
+func cacheTag(opts ImageOptions) string {
+	switch {
+	case opts.CacheTo == nil:
+		return ""
+	case opts.CacheTo.Type == CacheLocal:
+		return localCacheTag(opts.AppName)
+	default:
+		return opts.CacheTo.Ref
+	}
+}
+
+// importCaches returns the references of the images the caches opts specify
+// import from. Local caches are loaded into the docker daemon first; the ones
+// which don't exist yet are skipped.
+func importCaches(ctx context.Context, docker *dockerclient.Client, opts ImageOptions) ([]string, error) {
+	var refs []string
+
+	for _, c := range opts.CacheFrom {
+		if c.Type == CacheRegistry {
+			refs = append(refs, c.Ref)
+
+			continue
+		}
+
+		archive := filepath.Join(c.Path, localCacheArchive)
+		if !helpers.FileExists(archive) {
+			terminal.Debugf("no cache in %s, skipping\n", c.Path)
+
+			continue
+		}
+
+		if err := loadImage(ctx, docker, archive); err != nil {
+			return nil, errors.Wrapf(err, "error importing cache from %s", c.Path)
+		}
+
+		refs = append(refs, localCacheTag(opts.AppName))
+	}
+
+	return refs, nil
+}
+
+func loadImage(ctx context.Context, docker *dockerclient.Client, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	resp, err := docker.ImageLoad(ctx, f, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return jsonmessage.DisplayJSONMessagesStream(resp.Body, io.Discard, 0, false, nil)
+}
+
+// exportCache exports the image built, tagged as cacheTag specifies, to the
+// Cache opts specify, if any.
+func exportCache(ctx context.Context, docker *dockerclient.Client, streams *iostreams.IOStreams, opts ImageOptions) error {
+	c := opts.CacheTo
+	if c == nil {
+		return nil
+	}
+
+	if c.Type == CacheRegistry {
+		return pushImage(ctx, docker, streams, c.Ref, registryAuthFor(c.Ref))
+	}
+
+	if err := os.MkdirAll(c.Path, 0755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that failures don't destroy the
+	// existing cache
+	f, err := os.CreateTemp(c.Path, localCacheArchive+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	rc, err := docker.ImageSave(ctx, []string{localCacheTag(opts.AppName)})
+	if err != nil {
+		return errors.Wrap(err, "error saving image")
+	}
+	defer rc.Close()
+
+	if _, err := io.Copy(f, rc); err != nil {
+		return errors.Wrap(err, "error saving image")
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(c.Path, localCacheArchive))
+}
+
+// registryAuthFor returns the encoded credentials, if any, for the registry
+// the given image is stored in.
+func registryAuthFor(ref string) string {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return ""
+	}
+
+	key := r.Context().RegistryStr()
+	if key == name.DefaultRegistry {
+		key = "https://index.docker.io/v1/"
+	}
+
+	cfg, ok := authConfigs()[key]
+	if !ok {
+		return ""
+	}
+
+	encodedJSON, err := json.Marshal(cfg)
+	if err != nil {
+		terminal.Warn("Error encoding registry credentials", err)
+		return ""
+	}
+
+	return base64.URLEncoding.EncodeToString(encodedJSON)
+}
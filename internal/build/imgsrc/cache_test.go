@@ -0,0 +1,106 @@
+package imgsrc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCache(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	cases := map[string]*Cache{
+		"registry.fly.io/app:cache":                   {Type: CacheRegistry, Ref: "registry.fly.io/app:cache"},
+		"type=registry,ref=registry.fly.io/app:cache": {Type: CacheRegistry, Ref: "registry.fly.io/app:cache"},
+		"type=local,src=.cache/build":                 {Type: CacheLocal, Path: ".cache/build"},
+		"type=local, dest=~/cache":                    {Type: CacheLocal, Path: filepath.Join(home, "cache")},
+	}
+
+	for spec, want := range cases {
+		got, err := ParseCache(spec)
+		if assert.NoError(t, err, spec) {
+			assert.Equal(t, want, got, spec)
+		}
+	}
+
+	errs := map[string]string{
+		"type=gha":                    `invalid cache "type=gha": expected type=registry or type=local`,
+		"type=registry":               `invalid cache "type=registry": the ref is missing`,
+		"type=local":                  `invalid cache "type=local": the directory is missing`,
+		"type=local,src=dir,mode=max": `invalid cache "type=local,src=dir,mode=max": unsupported option mode`,
+		"type=registry,ref":           `invalid cache "type=registry,ref": expected key=value, got "ref"`,
+	}
+
+	for spec, want := range errs {
+		_, err := ParseCache(spec)
+		assert.EqualError(t, err, want, spec)
+	}
+}
+
+func TestCacheTag(t *testing.T) {
+	opts := ImageOptions{AppName: "app"}
+	assert.Equal(t, "", cacheTag(opts))
+
+	opts.CacheTo = &Cache{Type: CacheRegistry, Ref: "registry.fly.io/app:cache"}
+	assert.Equal(t, "registry.fly.io/app:cache", cacheTag(opts))
+
+	opts.CacheTo = &Cache{Type: CacheLocal, Path: "cache"}
+	assert.Equal(t, "flyctl-cache/app:latest", cacheTag(opts))
+}
+
+func TestCheckCacheStages(t *testing.T) {
+	const multiStage = `FROM golang:1.18 AS build
+RUN go build -o /app .
+
+FROM alpine AS runtime
+COPY --from=build /app /app
+
+FROM runtime AS debug
+RUN apk add curl
+`
+
+	local := &Cache{Type: CacheLocal, Path: "cache"}
+
+	cases := map[string]struct {
+		dockerfile string
+		opts       ImageOptions
+		err        string
+	}{
+		"no cache exported": {
+			dockerfile: multiStage,
+		},
+		"single stage": {
+			dockerfile: "FROM alpine\nRUN apk add curl\n",
+			opts:       ImageOptions{CacheTo: local},
+		},
+		"multi-stage": {
+			dockerfile: multiStage,
+			opts:       ImageOptions{CacheTo: local},
+			err:        "can't export a cache to local:cache from a multi-stage build: caches carry inline cache metadata, which covers only the final stage, so the stages before it would never be cached",
+		},
+		"multi-stage registry cache": {
+			dockerfile: multiStage,
+			opts:       ImageOptions{CacheTo: &Cache{Type: CacheRegistry, Ref: "registry.fly.io/app:cache"}, Target: "runtime"},
+			err:        "can't export a cache to registry.fly.io/app:cache from a multi-stage build: caches carry inline cache metadata, which covers only the final stage, so the stages before it would never be cached",
+		},
+		"first stage targeted": {
+			dockerfile: multiStage,
+			opts:       ImageOptions{CacheTo: local, Target: "build"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := checkCacheStages([]byte(c.dockerfile), c.opts)
+			if c.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, c.err)
+			}
+		})
+	}
+}
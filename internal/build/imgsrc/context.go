@@ -0,0 +1,302 @@
+package imgsrc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/pkg/fileutils"
+	"github.com/dustin/go-humanize"
+
+	"github.com/superfly/flyctl/flyctl"
+	"github.com/superfly/flyctl/internal/cmdfmt"
+	"github.com/superfly/flyctl/terminal"
+)
+
+// hugeContextFileSize is the size past which files of build contexts are
+// warned about.
+const hugeContextFileSize = 100 << 20
+
+// contextSummaryEntries is the number of top level entries of build contexts
+// the breakdown of their size lists.
+const contextSummaryEntries = 5
+
+// contextFile is a file of a build context.
+type contextFile struct {
+	Path string
+	Size int64
+}
+
+// buildContext describes the files of a build context: the ones of its
+// directory which its ignore file doesn't exclude.
+type buildContext struct {
+	// Digest identifies the contents of the context: the paths, modes and
+	// contents of its files.
+	Digest string
+	Size   int64
+	Files  int
+	// Entries are the top level entries of the context with their total size,
+	// largest first.
+	Entries []contextFile
+	// Huge are the files larger than hugeContextFileSize.
+	Huge []contextFile
+}
+
+// contextCache caches the digests of the files of a build context by their
+// size and modification time, so that unchanged files aren't read again, and
+// records the digest of the context builders last received.
+type contextCache struct {
+	path string
+
+	Files map[string]cachedDigest `json:"files"`
+	// Builders maps the hosts of builders to the digest of the context they
+	// last received.
+	Builders map[string]string `json:"builders"`
+}
+
+type cachedDigest struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+	Digest  string `json:"digest"`
+}
+
+// loadContextCache loads the cache of the build context in dir. Caches which
+// can't be read are started afresh.
+func loadContextCache(dir string) *contextCache {
+	key := sha256.Sum256([]byte(dir))
+
+	c := &contextCache{
+		path:     filepath.Join(flyctl.ConfigDir(), "build-context", hex.EncodeToString(key[:8])+".json"),
+		Files:    map[string]cachedDigest{},
+		Builders: map[string]string{},
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return c
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		terminal.Debugf("discarding build context cache %s: %v\n", c.path, err)
+
+		c.Files, c.Builders = map[string]cachedDigest{}, map[string]string{}
+	}
+
+	if c.Files == nil {
+		c.Files = map[string]cachedDigest{}
+	}
+	if c.Builders == nil {
+		c.Builders = map[string]string{}
+	}
+
+	return c
+}
+
+func (c *contextCache) save() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.path, data, 0600)
+}
+
+// scanContext hashes the files of the build context in dir, honoring the
+// given .dockerignore patterns. The digests of files are reused from and
+// recorded to cache.
+func scanContext(dir string, excludes []string, cache *contextCache) (*buildContext, error) {
+	var (
+		bc      buildContext
+		h       = sha256.New()
+		files   = map[string]cachedDigest{}
+		entries = map[string]int64{}
+	)
+
+	err := walkContext(dir, excludes, func(rel, p string, fi fs.FileInfo) (err error) {
+		var content string
+		switch {
+		case fi.Mode().IsRegular():
+			if content, err = fileDigest(p, fi, cache.Files[rel]); err != nil {
+				return err
+			}
+			files[rel] = cachedDigest{Size: fi.Size(), ModTime: fi.ModTime().UnixNano(), Digest: content}
+
+			bc.Size += fi.Size()
+			bc.Files++
+			entries[strings.SplitN(rel, "/", 2)[0]] += fi.Size()
+
+			if fi.Size() > hugeContextFileSize {
+				bc.Huge = append(bc.Huge, contextFile{Path: rel, Size: fi.Size()})
+			}
+		case fi.Mode()&os.ModeSymlink != 0:
+			if content, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(h, "%s\x00%o\x00%s\n", rel, fi.Mode(), content)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cache.Files = files
+	bc.Digest = "sha256:" + hex.EncodeToString(h.Sum(nil))
+
+	for name, size := range entries {
+		bc.Entries = append(bc.Entries, contextFile{Path: name, Size: size})
+	}
+	sort.Slice(bc.Entries, func(i, j int) bool {
+		if bc.Entries[i].Size != bc.Entries[j].Size {
+			return bc.Entries[i].Size > bc.Entries[j].Size
+		}
+
+		return bc.Entries[i].Path < bc.Entries[j].Path
+	})
+
+	return &bc, nil
+}
+
+// walkContext walks the build context in dir in lexical order, calling fn with
+// the slash separated path, relative to dir, the path and the info of every
+// entry of it the given .dockerignore patterns don't exclude.
+func walkContext(dir string, excludes []string, fn func(rel, p string, fi fs.FileInfo) error) error {
+	pm, err := fileutils.NewPatternMatcher(excludes)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if excluded, _ := pm.Matches(rel); excluded {
+			if d.IsDir() && !pm.Exclusions() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		return fn(rel, p, fi)
+	})
+}
+
+// ContextFiles returns the slash separated paths, relative to workingDir, of
+// the files the build context in workingDir consists of, in lexical order,
+// along with the path to the ignore file which filters it, if any. Builds
+// with the given Dockerfile send exactly these files to the builder.
+func ContextFiles(workingDir, dockerfile string) (ignoreFile string, files []string, err error) {
+	ignoreFile = ResolveIgnoreFile(workingDir, dockerfile)
+
+	excludes, err := readDockerignore(workingDir, dockerfile)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = walkContext(workingDir, excludes, func(rel, _ string, fi fs.FileInfo) error {
+		if !fi.IsDir() {
+			files = append(files, rel)
+		}
+
+		return nil
+	})
+
+	return ignoreFile, files, err
+}
+
+// fileDigest returns the digest of the contents of the file at p, reusing the
+// cached one in case the file hasn't changed since.
+func fileDigest(p string, fi fs.FileInfo, cached cachedDigest) (string, error) {
+	if cached.Digest != "" && cached.Size == fi.Size() && cached.ModTime == fi.ModTime().UnixNano() {
+		return cached.Digest, nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// PrintContextSummary prints the summary of the build context in workingDir,
+// as filtered for builds with the given Dockerfile, which deploy prints too.
+func PrintContextSummary(w io.Writer, workingDir, dockerfile string) error {
+	excludes, err := readDockerignore(workingDir, dockerfile)
+	if err != nil {
+		return err
+	}
+
+	cache := loadContextCache(workingDir)
+
+	bc, err := scanContext(workingDir, excludes, cache)
+	if err != nil {
+		return err
+	}
+
+	if err := cache.save(); err != nil {
+		terminal.Debugf("failed saving build context cache: %v\n", err)
+	}
+
+	printContextSummary(w, bc)
+
+	return nil
+}
+
+// printContextSummary prints the size of the build context, broken down by
+// its largest top level entries, and warns about its huge files.
+func printContextSummary(w io.Writer, bc *buildContext) {
+	cmdfmt.PrintDone(w, fmt.Sprintf("build context: %s in %d files (%s)", humanize.Bytes(uint64(bc.Size)), bc.Files, shortDigest(bc.Digest)))
+
+	for i, e := range bc.Entries {
+		if i == contextSummaryEntries {
+			break
+		}
+
+		fmt.Fprintf(w, "    %-30s %10s\n", e.Path, humanize.Bytes(uint64(e.Size)))
+	}
+
+	for _, f := range bc.Huge {
+		terminal.Warnf("%s is %s; consider excluding it from the build context with .flyignore or .dockerignore\n", f.Path, humanize.Bytes(uint64(f.Size)))
+	}
+}
+
+func shortDigest(digest string) string {
+	if i := strings.Index(digest, ":"); i >= 0 && len(digest) > i+13 {
+		return digest[:i+13]
+	}
+
+	return digest
+}
@@ -0,0 +1,111 @@
+package imgsrc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newContextCache() *contextCache {
+	return &contextCache{Files: map[string]cachedDigest{}, Builders: map[string]string{}}
+}
+
+func TestScanContext(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile":          "FROM scratch",
+		"app/main.go":         "package main",
+		"app/web/index.html":  "<h1>hi</h1>",
+		"node_modules/x/x.js": "ignored",
+		"README.md":           "readme",
+	})
+	excludes := []string{"node_modules"}
+
+	cache := newContextCache()
+
+	bc, err := scanContext(dir, excludes, cache)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, bc.Files)
+	assert.Equal(t, int64(41), bc.Size)
+	assert.Equal(t, []contextFile{
+		{Path: "app", Size: 23},
+		{Path: "Dockerfile", Size: 12},
+		{Path: "README.md", Size: 6},
+	}, bc.Entries)
+	assert.Empty(t, bc.Huge)
+	assert.Len(t, cache.Files, 4)
+	assert.NotContains(t, cache.Files, "node_modules/x/x.js")
+
+	// excluded files don't affect the digest
+	writeFiles(t, dir, map[string]string{"node_modules/y.js": "ignored"})
+
+	again, err := scanContext(dir, excludes, cache)
+	require.NoError(t, err)
+	assert.Equal(t, bc.Digest, again.Digest)
+
+	// the ones of the context do
+	writeFiles(t, dir, map[string]string{"app/main.go": "package app"})
+
+	changed, err := scanContext(dir, excludes, cache)
+	require.NoError(t, err)
+	assert.NotEqual(t, bc.Digest, changed.Digest)
+}
+
+func TestScanContextReusesCachedDigests(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.go": "package main"})
+
+	fi, err := os.Stat(filepath.Join(dir, "main.go"))
+	require.NoError(t, err)
+
+	cache := newContextCache()
+	cache.Files["main.go"] = cachedDigest{Size: fi.Size(), ModTime: fi.ModTime().UnixNano(), Digest: "cached"}
+
+	_, err = scanContext(dir, nil, cache)
+	require.NoError(t, err)
+	assert.Equal(t, "cached", cache.Files["main.go"].Digest)
+
+	// files which changed are hashed again
+	cache.Files["main.go"] = cachedDigest{Size: fi.Size() + 1, ModTime: fi.ModTime().UnixNano(), Digest: "cached"}
+
+	_, err = scanContext(dir, nil, cache)
+	require.NoError(t, err)
+	assert.NotEqual(t, "cached", cache.Files["main.go"].Digest)
+}
+
+func TestScanContextHugeFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "small.txt"), []byte("small"), 0644))
+
+	f, err := os.Create(filepath.Join(dir, "dump.sql"))
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(hugeContextFileSize+1))
+	require.NoError(t, f.Close())
+
+	bc, err := scanContext(dir, nil, newContextCache())
+	require.NoError(t, err)
+	assert.Equal(t, []contextFile{{Path: "dump.sql", Size: hugeContextFileSize + 1}}, bc.Huge)
+}
+
+func TestContextFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile":          "FROM scratch",
+		"fly.toml":            "app = \"app\"",
+		"app/main.go":         "package main",
+		"node_modules/x/x.js": "ignored",
+		".dockerignore":       "node_modules",
+		".flyignore":          "*.md\nnode_modules",
+		"README.md":           "readme",
+	})
+
+	ignoreFile, files, err := ContextFiles(dir, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, filepath.Join(dir, ".flyignore"), ignoreFile)
+	assert.Equal(t, []string{".flyignore", "Dockerfile", "app/main.go"}, files)
+}
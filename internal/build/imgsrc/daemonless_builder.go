@@ -0,0 +1,774 @@
+package imgsrc
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/fileutils"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	"github.com/pkg/errors"
+
+	"github.com/superfly/flyctl/helpers"
+	"github.com/superfly/flyctl/internal/cmdfmt"
+	"github.com/superfly/flyctl/pkg/iostreams"
+	"github.com/superfly/flyctl/terminal"
+)
+
+// daemonlessBuilder builds images without a docker daemon. It assembles them
+// from their base image, fetched straight from its registry, and layers of
+// the files their Dockerfile copies from the build context, and pushes them
+// straight to the registry or writes them to disk. Since it runs nothing, it
+// only supports single stage Dockerfiles without RUN instructions, such as the
+// one of the static builtin.
+type daemonlessBuilder struct{}
+
+func (*daemonlessBuilder) Name() string {
+	return "daemonless"
+}
+
+func (db *daemonlessBuilder) Run(ctx context.Context, dockerFactory *DockerClientFactory, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error) {
+	started := time.Now()
+
+	if opts.Builder != "" {
+		terminal.Debug("buildpacks require a docker daemon, skipping")
+		return nil, nil
+	}
+
+	dockerfile, err := daemonlessDockerfile(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if dockerfile == nil {
+		terminal.Debug("dockerfile not found, skipping")
+		return nil, nil
+	}
+
+	if !opts.Publish && opts.Output == nil {
+		return nil, errors.New("the daemonless builder either pushes the images it builds straight to the registry or writes them to disk; it can't build them locally")
+	}
+
+	cmdfmt.PrintBegin(streams.ErrOut, "Building image without Docker")
+
+	excludes, err := readDockerignore(opts.WorkingDir, opts.DockerfilePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading ignore file")
+	}
+
+	keychain := registryKeychain()
+
+	platform, err := ParsePlatform(opts.platform())
+	if err != nil {
+		return nil, err
+	}
+
+	pull := func(ref string) (v1.Image, error) {
+		r, err := name.ParseReference(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		cmdfmt.PrintDone(streams.ErrOut, fmt.Sprintf("base image: %s", r.Name()))
+
+		return remote.Image(r,
+			remote.WithContext(ctx),
+			remote.WithPlatform(*platform),
+			remote.WithAuthFromKeychain(keychain),
+		)
+	}
+
+	img, err := assembleImage(dockerfile, opts, excludes, pull)
+	if err != nil {
+		return nil, errors.Wrap(err, "error building")
+	}
+
+	labels := withDuration(buildLabels(opts, db.Name(), started), started)
+	if img, err = addLabels(img, labels); err != nil {
+		return nil, errors.Wrap(err, "error labeling image")
+	}
+
+	cmdfmt.PrintDone(streams.ErrOut, "Building image done")
+
+	if opts.Publish {
+		ref, err := name.ParseReference(opts.Tag)
+		if err != nil {
+			return nil, err
+		}
+
+		cmdfmt.PrintBegin(streams.ErrOut, "Pushing image to fly")
+
+		if err := remote.Write(ref, img, remote.WithContext(ctx), remote.WithAuthFromKeychain(keychain)); err != nil {
+			return nil, errors.Wrap(err, "error pushing image to registry")
+		}
+
+		cmdfmt.PrintDone(streams.ErrOut, "Pushing image done")
+	}
+
+	if err := writeOutput(streams, opts, img); err != nil {
+		return nil, err
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := imageSize(img)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeploymentImage{
+		ID:     digest.String(),
+		Tag:    opts.Tag,
+		Size:   size,
+		Labels: labels,
+	}, nil
+}
+
+// daemonlessDockerfile returns the contents of the Dockerfile opts describe:
+// the one of the selected builtin or the one at the given path or in the
+// working directory. It returns nil in case there's none.
+func daemonlessDockerfile(ctx context.Context, opts ImageOptions) ([]byte, error) {
+	if opts.BuiltIn != "" {
+		builtin, err := findBuiltin(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		vdockerfile, err := builtin.GetVDockerfile(opts.BuiltInSettings)
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(vdockerfile), nil
+	}
+
+	path := opts.DockerfilePath
+	if path == "" {
+		path = resolveDockerfile(opts.WorkingDir)
+	} else if !helpers.FileExists(path) {
+		return nil, fmt.Errorf("Dockerfile '%s' not found", path)
+	}
+
+	if path == "" {
+		return nil, nil
+	}
+
+	return os.ReadFile(path)
+}
+
+// assembleImage assembles the image the given Dockerfile describes, pulling
+// its base image via pull.
+func assembleImage(dockerfile []byte, opts ImageOptions, excludes []string, pull func(string) (v1.Image, error)) (v1.Image, error) {
+	res, err := parser.Parse(bytes.NewReader(dockerfile))
+	if err != nil {
+		return nil, err
+	}
+
+	stages, metaArgs, err := instructions.Parse(res.AST)
+	if err != nil {
+		return nil, err
+	}
+
+	stage, err := daemonlessStage(stages, opts.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &assembler{
+		lex:       shell.NewLex(res.EscapeToken),
+		buildArgs: opts.BuildArgs,
+		vars:      map[string]string{},
+		context:   opts.WorkingDir,
+		workdir:   "/",
+		shell:     []string{"/bin/sh", "-c"},
+	}
+
+	if a.excludes, err = fileutils.NewPatternMatcher(excludes); err != nil {
+		return nil, err
+	}
+
+	for i := range metaArgs {
+		if err := a.arg(&metaArgs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	baseName, err := a.expand(stage.BaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	var base v1.Image
+	if baseName == "scratch" {
+		base = empty.Image
+	} else if base, err = pull(baseName); err != nil {
+		return nil, fmt.Errorf("failed fetching base image %s: %w", baseName, err)
+	}
+
+	cfg, err := base.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	a.config = *cfg.Config.DeepCopy()
+	if a.config.WorkingDir != "" {
+		a.workdir = a.config.WorkingDir
+	}
+
+	// args declared before FROM are only available to the stage when they're
+	// declared again; the environment of the base image is available as is
+	a.meta, a.vars = a.vars, map[string]string{}
+	for _, kv := range a.config.Env {
+		k, v := splitPair(kv, "=")
+		a.vars[k] = v
+	}
+
+	for _, cmd := range stage.Commands {
+		if err := a.apply(cmd); err != nil {
+			if loc := cmd.Location(); len(loc) > 0 {
+				return nil, fmt.Errorf("line %d: %w", loc[0].Start.Line, err)
+			}
+
+			return nil, err
+		}
+	}
+
+	img, err := mutate.AppendLayers(base, a.layers...)
+	if err != nil {
+		return nil, err
+	}
+
+	return mutate.Config(img, a.config)
+}
+
+// daemonlessStage returns the stage to build: the target one or the last one.
+// Stages may not depend on others, since that requires running them.
+func daemonlessStage(stages []instructions.Stage, target string) (*instructions.Stage, error) {
+	if len(stages) == 0 {
+		return nil, errors.New("the Dockerfile has no FROM instruction")
+	}
+
+	stage := &stages[len(stages)-1]
+	if target != "" {
+		stage = nil
+		for i := range stages {
+			if strings.EqualFold(stages[i].Name, target) {
+				stage = &stages[i]
+			}
+		}
+
+		if stage == nil {
+			return nil, fmt.Errorf("the Dockerfile has no stage named %s", target)
+		}
+	}
+
+	for _, s := range stages {
+		if s.Name != "" && strings.EqualFold(s.Name, stage.BaseName) {
+			return nil, fmt.Errorf("stages based on other stages, such as %s, require a docker daemon", stage.Name)
+		}
+	}
+
+	return stage, nil
+}
+
+// assembler applies the instructions of a Dockerfile to the config and the
+// layers of an image.
+type assembler struct {
+	lex       *shell.Lex
+	buildArgs map[string]string
+	meta      map[string]string
+	vars      map[string]string
+	context   string
+	excludes  *fileutils.PatternMatcher
+
+	config  v1.Config
+	layers  []v1.Layer
+	workdir string
+	shell   []string
+	cmdSet  bool
+}
+
+func (a *assembler) expand(word string) (string, error) {
+	return a.lex.ProcessWordWithMap(word, a.vars)
+}
+
+func (a *assembler) apply(cmd instructions.Command) (err error) {
+	if e, ok := cmd.(instructions.SupportsSingleWordExpansion); ok {
+		if err = e.Expand(a.expand); err != nil {
+			return
+		}
+	}
+
+	switch c := cmd.(type) {
+	case *instructions.ArgCommand:
+		err = a.arg(c)
+	case *instructions.EnvCommand:
+		for _, kv := range c.Env {
+			a.setEnv(kv.Key, kv.Value)
+		}
+	case *instructions.LabelCommand:
+		if a.config.Labels == nil {
+			a.config.Labels = map[string]string{}
+		}
+		for _, kv := range c.Labels {
+			a.config.Labels[kv.Key] = kv.Value
+		}
+	case *instructions.WorkdirCommand:
+		a.workdir = a.abs(c.Path)
+		a.config.WorkingDir = a.workdir
+	case *instructions.UserCommand:
+		a.config.User = c.User
+	case *instructions.ExposeCommand:
+		err = a.expose(c.Ports)
+	case *instructions.VolumeCommand:
+		if a.config.Volumes == nil {
+			a.config.Volumes = map[string]struct{}{}
+		}
+		for _, v := range c.Volumes {
+			a.config.Volumes[v] = struct{}{}
+		}
+	case *instructions.StopSignalCommand:
+		a.config.StopSignal = c.Signal
+	case *instructions.ShellCommand:
+		a.shell = c.Shell
+	case *instructions.CmdCommand:
+		a.config.Cmd = a.cmdLine(c.ShellDependantCmdLine)
+		a.cmdSet = true
+	case *instructions.EntrypointCommand:
+		a.config.Entrypoint = a.cmdLine(c.ShellDependantCmdLine)
+		if !a.cmdSet {
+			// as with docker, entrypoints reset the inherited command
+			a.config.Cmd = nil
+		}
+	case *instructions.HealthCheckCommand:
+		a.config.Healthcheck = &v1.HealthConfig{
+			Test:        c.Health.Test,
+			Interval:    c.Health.Interval,
+			Timeout:     c.Health.Timeout,
+			StartPeriod: c.Health.StartPeriod,
+			Retries:     c.Health.Retries,
+		}
+	case *instructions.MaintainerCommand:
+		// deprecated and only recorded in the history of images
+	case *instructions.CopyCommand:
+		if c.From != "" {
+			return errors.New("COPY --from requires a docker daemon")
+		}
+		err = a.copy(c.SourcesAndDest, c.Chown, c.Chmod)
+	case *instructions.AddCommand:
+		for _, src := range c.SourcePaths {
+			if isURL(src) || isArchive(src) {
+				return fmt.Errorf("ADD %s requires a docker daemon; use COPY for plain files", src)
+			}
+		}
+		err = a.copy(c.SourcesAndDest, c.Chown, c.Chmod)
+	default:
+		err = fmt.Errorf("%s instructions require a docker daemon", strings.ToUpper(cmd.Name()))
+	}
+
+	return
+}
+
+func (a *assembler) arg(c *instructions.ArgCommand) error {
+	for _, kv := range c.Args {
+		if v, ok := a.buildArgs[kv.Key]; ok {
+			a.vars[kv.Key] = v
+		} else if kv.Value != nil {
+			v, err := a.expand(*kv.Value)
+			if err != nil {
+				return err
+			}
+
+			a.vars[kv.Key] = v
+		} else if v, ok := a.meta[kv.Key]; ok {
+			a.vars[kv.Key] = v
+		} else if _, ok := a.vars[kv.Key]; !ok {
+			a.vars[kv.Key] = ""
+		}
+	}
+
+	return nil
+}
+
+func (a *assembler) setEnv(key, value string) {
+	a.vars[key] = value
+
+	for i, kv := range a.config.Env {
+		if k, _ := splitPair(kv, "="); k == key {
+			a.config.Env[i] = key + "=" + value
+
+			return
+		}
+	}
+
+	a.config.Env = append(a.config.Env, key+"="+value)
+}
+
+func (a *assembler) expose(ports []string) error {
+	if a.config.ExposedPorts == nil {
+		a.config.ExposedPorts = map[string]struct{}{}
+	}
+
+	for _, p := range ports {
+		p, err := a.expand(p)
+		if err != nil {
+			return err
+		}
+
+		if !strings.Contains(p, "/") {
+			p += "/tcp"
+		}
+
+		a.config.ExposedPorts[p] = struct{}{}
+	}
+
+	return nil
+}
+
+func (a *assembler) cmdLine(c instructions.ShellDependantCmdLine) []string {
+	if !c.PrependShell {
+		return append([]string(nil), c.CmdLine...)
+	}
+
+	return append(append([]string(nil), a.shell...), strings.Join(c.CmdLine, " "))
+}
+
+// abs resolves p against the working directory.
+func (a *assembler) abs(p string) string {
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+
+	return path.Join(a.workdir, p)
+}
+
+// copy appends a layer holding the given sources to the image.
+func (a *assembler) copy(sd instructions.SourcesAndDest, chown, chmod string) error {
+	if len(sd.SourceContents) > 0 {
+		return errors.New("heredocs require a docker daemon")
+	}
+
+	lw, err := newLayerWriter(chown, chmod)
+	if err != nil {
+		return err
+	}
+
+	var sources []string
+	for _, src := range sd.SourcePaths {
+		matches, err := a.sources(src)
+		if err != nil {
+			return err
+		}
+
+		sources = append(sources, matches...)
+	}
+
+	dest := a.abs(sd.DestPath)
+	toDir := strings.HasSuffix(sd.DestPath, "/") || sd.DestPath == "." || len(sources) > 1
+
+	for _, src := range sources {
+		fi, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case fi.IsDir():
+			// as with docker, the contents of directories are copied rather
+			// than the directories themselves
+			err = a.addTree(lw, src, dest)
+		case toDir:
+			err = lw.add(src, fi, path.Join(dest, filepath.Base(src)))
+		default:
+			err = lw.add(src, fi, dest)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	layer, err := lw.layer()
+	if err != nil {
+		return err
+	}
+
+	a.layers = append(a.layers, layer)
+
+	return nil
+}
+
+// sources returns the paths of the files of the build context src matches.
+func (a *assembler) sources(src string) ([]string, error) {
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(src, "/")))
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s is outside of the build context", src)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(a.context, rel))
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []string
+	for _, m := range matches {
+		if rel, _ := filepath.Rel(a.context, m); rel != "." && a.excluded(rel) {
+			continue
+		}
+
+		sources = append(sources, m)
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("%s: no such file or directory in the build context", src)
+	}
+
+	return sources, nil
+}
+
+func (a *assembler) excluded(rel string) bool {
+	excluded, _ := a.excludes.Matches(filepath.ToSlash(rel))
+
+	return excluded
+}
+
+// addTree adds the contents of the directory src to dest, skipping the
+// files the .dockerignore file excludes.
+func (a *assembler) addTree(lw *layerWriter, src, dest string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if rel, _ := filepath.Rel(a.context, p); rel != "." && a.excluded(rel) {
+			if d.IsDir() && !a.excludes.Exclusions() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			// dest itself isn't copied, its contents are
+			return nil
+		}
+
+		return lw.add(p, fi, path.Join(dest, filepath.ToSlash(rel)))
+	})
+}
+
+// layerWriter writes the tarball of a layer.
+type layerWriter struct {
+	buf  bytes.Buffer
+	tw   *tar.Writer
+	dirs map[string]bool
+
+	uid, gid int
+	mode     os.FileMode
+}
+
+func newLayerWriter(chown, chmod string) (lw *layerWriter, err error) {
+	lw = &layerWriter{dirs: map[string]bool{}}
+	lw.tw = tar.NewWriter(&lw.buf)
+
+	if chown != "" {
+		user, group := splitPair(chown, ":")
+		if lw.uid, err = strconv.Atoi(user); err != nil {
+			return nil, fmt.Errorf("--chown=%s: only numeric user and group IDs are supported without a docker daemon", chown)
+		}
+
+		lw.gid = lw.uid
+		if group != "" {
+			if lw.gid, err = strconv.Atoi(group); err != nil {
+				return nil, fmt.Errorf("--chown=%s: only numeric user and group IDs are supported without a docker daemon", chown)
+			}
+		}
+	}
+
+	if chmod != "" {
+		mode, err := strconv.ParseUint(chmod, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --chmod=%s", chmod)
+		}
+
+		lw.mode = os.FileMode(mode)
+	}
+
+	return
+}
+
+// add adds the file at src to the layer, as dest. The directories leading to
+// dest are left out, so that the ones of the image underneath, like a /tmp
+// only its owner may remove files from, keep their ownership and mode; the ones
+// it lacks are created by whoever unpacks the image.
+func (lw *layerWriter) add(src string, fi os.FileInfo, dest string) error {
+	var link string
+	if fi.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(src); err != nil {
+			return err
+		}
+	}
+
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+
+	hdr.Name = strings.TrimPrefix(dest, "/")
+	hdr.Uid, hdr.Gid = lw.uid, lw.gid
+	hdr.Uname, hdr.Gname = "", ""
+	if lw.mode != 0 {
+		hdr.Mode = int64(lw.mode)
+	}
+
+	if fi.IsDir() {
+		if lw.dirs[dest] {
+			return nil
+		}
+		lw.dirs[dest] = true
+		hdr.Name += "/"
+	}
+
+	if err := lw.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(lw.tw, f)
+
+	return err
+}
+
+func (lw *layerWriter) layer() (v1.Layer, error) {
+	if err := lw.tw.Close(); err != nil {
+		return nil, err
+	}
+
+	data := lw.buf.Bytes()
+
+	return tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+// splitPair splits s around the first instance of sep.
+func splitPair(s, sep string) (before, after string) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):]
+	}
+
+	return s, ""
+}
+
+func isURL(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+func isArchive(src string) bool {
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar.xz", ".txz"} {
+		if strings.HasSuffix(src, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addLabels returns img with the given labels added to its config.
+func addLabels(img v1.Image, labels map[string]string) (v1.Image, error) {
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	config := *cfg.Config.DeepCopy()
+	if config.Labels == nil {
+		config.Labels = map[string]string{}
+	}
+
+	for k, v := range labels {
+		config.Labels[k] = v
+	}
+
+	return mutate.Config(img, config)
+}
+
+// imageSize returns the size of the config and the compressed layers of img.
+func imageSize(img v1.Image) (int64, error) {
+	m, err := img.Manifest()
+	if err != nil {
+		return 0, err
+	}
+
+	size := m.Config.Size
+	for _, l := range m.Layers {
+		size += l.Size
+	}
+
+	return size, nil
+}
+
+// registryKeychain resolves credentials for registries: the ones of the fly
+// registry and Docker Hub, as with docker builds, or else the ones of the
+// docker config file.
+func registryKeychain() authn.Keychain {
+	return authn.NewMultiKeychain(flyKeychain{}, authn.DefaultKeychain)
+}
+
+type flyKeychain struct{}
+
+func (flyKeychain) Resolve(r authn.Resource) (authn.Authenticator, error) {
+	key := r.RegistryStr()
+	if key == name.DefaultRegistry {
+		key = "https://index.docker.io/v1/"
+	}
+
+	if a, ok := authConfigs()[key]; ok {
+		return authn.FromConfig(authn.AuthConfig{
+			Username: a.Username,
+			Password: a.Password,
+		}), nil
+	}
+
+	return authn.Anonymous, nil
+}
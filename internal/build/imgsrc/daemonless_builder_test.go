@@ -0,0 +1,205 @@
+package imgsrc
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, contents := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(contents), 0644))
+	}
+}
+
+func layerFiles(t *testing.T, l v1.Layer) map[string]string {
+	t.Helper()
+
+	rc, err := l.Uncompressed()
+	require.NoError(t, err)
+	defer rc.Close()
+
+	files := map[string]string{}
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+
+		files[hdr.Name] = string(data)
+	}
+
+	return files
+}
+
+func fakePull(t *testing.T) func(string) (v1.Image, error) {
+	return func(ref string) (v1.Image, error) {
+		assert.Equal(t, "base:1", ref)
+
+		return mutate.Config(empty.Image, v1.Config{
+			Env: []string{"PATH=/bin"},
+			Cmd: []string{"sh"},
+		})
+	}
+}
+
+func TestAssembleImage(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.html":          "<h1>hi</h1>",
+		"static/app.js":       "app",
+		"static/app.js.map":   "map",
+		"static/img/logo.svg": "logo",
+		"secrets.env":         "TOKEN=x",
+	})
+
+	const dockerfile = `
+ARG BASE_TAG=0
+FROM base:${BASE_TAG}
+ARG GREETING=hello
+ENV GREETING=${GREETING} PORT=8080
+WORKDIR /srv
+COPY index.html ./
+COPY static public/
+COPY --chown=1000:1000 index.html /index.html
+EXPOSE ${PORT}
+LABEL greeting=${GREETING}
+ENTRYPOINT ["serve"]
+`
+
+	img, err := assembleImage([]byte(dockerfile), ImageOptions{
+		WorkingDir: dir,
+		BuildArgs:  map[string]string{"BASE_TAG": "1", "GREETING": "howdy"},
+	}, []string{"*.env", "**/*.map"}, fakePull(t))
+	require.NoError(t, err)
+
+	cfg, err := img.ConfigFile()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"PATH=/bin", "GREETING=howdy", "PORT=8080"}, cfg.Config.Env)
+	assert.Equal(t, "/srv", cfg.Config.WorkingDir)
+	assert.Equal(t, map[string]struct{}{"8080/tcp": {}}, cfg.Config.ExposedPorts)
+	assert.Equal(t, "howdy", cfg.Config.Labels["greeting"])
+	assert.Equal(t, []string{"serve"}, cfg.Config.Entrypoint)
+	assert.Empty(t, cfg.Config.Cmd)
+
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	require.Len(t, layers, 3)
+
+	assert.Equal(t, map[string]string{
+		"srv/index.html": "<h1>hi</h1>",
+	}, layerFiles(t, layers[0]))
+
+	assert.Equal(t, map[string]string{
+		"srv/public/app.js":       "app",
+		"srv/public/img/":         "",
+		"srv/public/img/logo.svg": "logo",
+	}, layerFiles(t, layers[1]))
+
+	assert.Equal(t, map[string]string{
+		"index.html": "<h1>hi</h1>",
+	}, layerFiles(t, layers[2]))
+}
+
+func TestAssembleImageKeepsBaseDirectories(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.sock":        "",
+		"cache/index.bin": "index",
+	})
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Typeflag: tar.TypeDir, Name: "tmp/", Mode: 01777},
+		{Typeflag: tar.TypeDir, Name: "usr/", Mode: 0755},
+	} {
+		require.NoError(t, tw.WriteHeader(hdr))
+	}
+	require.NoError(t, tw.Close())
+
+	baseLayer, err := tarball.LayerFromReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	base, err := mutate.AppendLayers(empty.Image, baseLayer)
+	require.NoError(t, err)
+
+	const dockerfile = `
+FROM base:1
+COPY --chown=1000:1000 app.sock /tmp/app.sock
+COPY --chown=1000:1000 cache /usr/share/cache/
+`
+
+	img, err := assembleImage([]byte(dockerfile), ImageOptions{WorkingDir: dir}, nil, func(string) (v1.Image, error) {
+		return base, nil
+	})
+	require.NoError(t, err)
+
+	rc := mutate.Extract(img)
+	defer rc.Close()
+
+	modes := map[string]int64{}
+	owners := map[string]int{}
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		modes[hdr.Name] = hdr.Mode & 07777
+		owners[hdr.Name] = hdr.Uid
+	}
+
+	assert.Equal(t, int64(01777), modes["tmp/"])
+	assert.Equal(t, int64(0755), modes["usr/"])
+	assert.Equal(t, 0, owners["tmp/"])
+	assert.Equal(t, 0, owners["usr/"])
+	assert.Equal(t, 1000, owners["tmp/app.sock"])
+	assert.Equal(t, 1000, owners["usr/share/cache/index.bin"])
+	assert.NotContains(t, modes, "usr/share/")
+	assert.NotContains(t, modes, "usr/share/cache/")
+}
+
+func TestAssembleImageUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"index.html": "hi"})
+
+	cases := map[string]string{
+		"FROM base:1\nRUN make\n":                        "line 2: RUN instructions require a docker daemon",
+		"FROM base:1 AS build\nFROM build\n":             "stages based on other stages, such as , require a docker daemon",
+		"FROM base:1\nCOPY --from=build /app /app\n":     "line 2: COPY --from requires a docker daemon",
+		"FROM base:1\nCOPY --chown=www index.html /\n":   "line 2: --chown=www: only numeric user and group IDs are supported without a docker daemon",
+		"FROM base:1\nADD https://example.com/a.txt /\n": "line 2: ADD https://example.com/a.txt requires a docker daemon; use COPY for plain files",
+		"FROM base:1\nCOPY missing.txt /\n":              "line 2: missing.txt: no such file or directory in the build context",
+		"FROM base:1\nCOPY ../outside.txt /\n":           "line 2: ../outside.txt is outside of the build context",
+	}
+
+	for dockerfile, want := range cases {
+		_, err := assembleImage([]byte(dockerfile), ImageOptions{WorkingDir: dir}, nil, fakePull(t))
+		assert.EqualError(t, err, want, dockerfile)
+	}
+}
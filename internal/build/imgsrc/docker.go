@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/azazeal/pause"
@@ -26,17 +27,27 @@ import (
 	"github.com/superfly/flyctl/terminal"
 )
 
-type dockerClientFactory struct {
+type DockerClientFactory struct {
 	mode    DockerDaemonType
 	buildFn func(ctx context.Context) (*dockerclient.Client, error)
 }
 
-func newDockerClientFactory(daemonType DockerDaemonType, apiClient *api.Client, appName string, streams *iostreams.IOStreams) *dockerClientFactory {
+// Mode returns the type of the docker daemon the factory connects to.
+func (f *DockerClientFactory) Mode() DockerDaemonType {
+	return f.mode
+}
+
+// Client returns a client of the docker daemon the factory connects to.
+func (f *DockerClientFactory) Client(ctx context.Context) (*dockerclient.Client, error) {
+	return f.buildFn(ctx)
+}
+
+func newDockerClientFactory(daemonType DockerDaemonType, apiClient *api.Client, appName string, streams *iostreams.IOStreams) *DockerClientFactory {
 	if daemonType.AllowLocal() {
 		terminal.Debug("trying local docker daemon")
 		c, err := NewLocalDockerClient()
 		if c != nil && err == nil {
-			return &dockerClientFactory{
+			return &DockerClientFactory{
 				mode: DockerDaemonTypeLocal,
 				buildFn: func(ctx context.Context) (*dockerclient.Client, error) {
 					return c, nil
@@ -51,11 +62,19 @@ func newDockerClientFactory(daemonType DockerDaemonType, apiClient *api.Client,
 
 	if daemonType.AllowRemote() {
 		terminal.Debug("trying remote docker daemon")
-		var cachedDocker *dockerclient.Client
+		var (
+			mu           sync.Mutex
+			cachedDocker *dockerclient.Client
+		)
 
-		return &dockerClientFactory{
+		return &DockerClientFactory{
 			mode: DockerDaemonTypeRemote,
 			buildFn: func(ctx context.Context) (*dockerclient.Client, error) {
+				// resolvers may build several images at once; they share the
+				// remote builder
+				mu.Lock()
+				defer mu.Unlock()
+
 				if cachedDocker != nil {
 					return cachedDocker, nil
 				}
@@ -69,7 +88,7 @@ func newDockerClientFactory(daemonType DockerDaemonType, apiClient *api.Client,
 		}
 	}
 
-	return &dockerClientFactory{
+	return &DockerClientFactory{
 		mode: DockerDaemonTypeNone,
 		buildFn: func(ctx context.Context) (*dockerclient.Client, error) {
 			return nil, errors.New("no docker daemon available")
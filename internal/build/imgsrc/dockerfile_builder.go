@@ -32,7 +32,7 @@ import (
 type dockerfileBuilder struct{}
 
 func (ds *dockerfileBuilder) Name() string {
-	return "Dockerfile"
+	return "dockerfile"
 }
 
 // lastProgressOutput is the same as progress.Output except
@@ -51,7 +51,8 @@ func (out *lastProgressOutput) WriteProgress(prog progress.Progress) error {
 	return out.output.WriteProgress(prog)
 }
 
-func (ds *dockerfileBuilder) Run(ctx context.Context, dockerFactory *dockerClientFactory, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error) {
+func (ds *dockerfileBuilder) Run(ctx context.Context, dockerFactory *DockerClientFactory, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error) {
+	started := time.Now()
 
 	if !dockerFactory.mode.IsAvailable() {
 		// Where should debug messages be sent?
@@ -75,6 +76,17 @@ func (ds *dockerfileBuilder) Run(ctx context.Context, dockerFactory *dockerClien
 		return nil, nil
 	}
 
+	if opts.CacheTo != nil {
+		data, err := os.ReadFile(dockerfile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading Dockerfile")
+		}
+
+		if err := checkCacheStages(data, opts); err != nil {
+			return nil, err
+		}
+	}
+
 	docker, err := dockerFactory.buildFn(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "error connecting to docker")
@@ -84,56 +96,24 @@ func (ds *dockerfileBuilder) Run(ctx context.Context, dockerFactory *dockerClien
 
 	// Is ErrOut being used here so prevent stdout messages stepping on each other?
 	cmdfmt.PrintBegin(streams.ErrOut, "Creating build context")
-	archiveOpts := archiveOptions{
-		sourcePath: opts.WorkingDir,
-		compressed: dockerFactory.mode.IsRemote(),
-	}
 
-	excludes, err := readDockerignore(opts.WorkingDir)
+	excludes, err := readDockerignore(opts.WorkingDir, dockerfile)
 	if err != nil {
-		return nil, errors.Wrap(err, "error reading .dockerignore")
-	}
-	archiveOpts.exclusions = excludes
-
-	var relativedockerfilePath string
-
-	// copy dockerfile into the archive if it's outside the context dir
-	if !isPathInRoot(dockerfile, opts.WorkingDir) {
-		dockerfileData, err := os.ReadFile(dockerfile)
-		if err != nil {
-			return nil, errors.Wrap(err, "error reading Dockerfile")
-		}
-		archiveOpts.additions = map[string][]byte{
-			"Dockerfile": dockerfileData,
-		}
-	} else {
-		// pass the relative path to Dockerfile within the context
-		p, err := filepath.Rel(opts.WorkingDir, dockerfile)
-		if err != nil {
-			return nil, err
-		}
-		relativedockerfilePath = p
+		return nil, errors.Wrap(err, "error reading ignore file")
 	}
 
-	// Start tracking this build
+	contextCache := loadContextCache(opts.WorkingDir)
 
-	// Create the docker build context as a compressed tar stream
-	r, err := archiveDirectory(archiveOpts)
+	buildContext, err := scanContext(opts.WorkingDir, excludes, contextCache)
 	if err != nil {
-		return nil, errors.Wrap(err, "error archiving build context")
-	}
-	cmdfmt.PrintDone(streams.ErrOut, "Creating build context done")
-
-	// Setup an upload progress bar
-	progressOutput := streamformatter.NewProgressOutput(streams.Out)
-	if !streams.IsStdoutTTY() {
-		progressOutput = &lastProgressOutput{output: progressOutput}
+		return nil, errors.Wrap(err, "error scanning build context")
 	}
-
-	r = progress.NewProgressReader(r, progressOutput, 0, "", "Sending build context to Docker daemon")
+	printContextSummary(streams.ErrOut, buildContext)
 
 	var imageID string
 
+	labels := buildLabels(opts, ds.Name(), started)
+
 	terminal.Debug("fetching docker server info")
 	serverInfo, err := func() (types.Info, error) {
 		infoCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -144,10 +124,6 @@ func (ds *dockerfileBuilder) Run(ctx context.Context, dockerFactory *dockerClien
 		return nil, errors.Wrap(err, "error fetching docker server info")
 	}
 
-	cmdfmt.PrintBegin(streams.ErrOut, "Building image with Docker")
-	msg := fmt.Sprintf("docker host: %s %s %s", serverInfo.ServerVersion, serverInfo.OSType, serverInfo.Architecture)
-	cmdfmt.PrintDone(streams.ErrOut, msg)
-
 	buildArgs, err := normalizeBuildArgsForDocker(ctx, opts.BuildArgs)
 
 	if err != nil {
@@ -160,19 +136,52 @@ func (ds *dockerfileBuilder) Run(ctx context.Context, dockerFactory *dockerClien
 		return nil, errors.Wrap(err, "error checking for buildkit support")
 	}
 	if buildkitEnabled {
-		imageID, err = runBuildKitBuild(ctx, streams, docker, r, opts, relativedockerfilePath, buildArgs)
+		// BuildKit syncs the context through the build session, transferring
+		// only the files the builder doesn't have yet
+		builderHost := docker.DaemonHost()
+		if contextCache.Builders[builderHost] == buildContext.Digest {
+			cmdfmt.PrintDone(streams.ErrOut, "Creating build context done; it's unchanged since the last build on this builder")
+		} else {
+			cmdfmt.PrintDone(streams.ErrOut, "Creating build context done; changed files will be synced with the builder")
+		}
+
+		cmdfmt.PrintBegin(streams.ErrOut, "Building image with Docker")
+		msg := fmt.Sprintf("docker host: %s %s %s", serverInfo.ServerVersion, serverInfo.OSType, serverInfo.Architecture)
+		cmdfmt.PrintDone(streams.ErrOut, msg)
+
+		imageID, err = runBuildKitBuild(ctx, streams, docker, opts, dockerfile, excludes, buildArgs, labels)
 		if err != nil {
 			return nil, errors.Wrap(err, "error building")
 		}
+
+		contextCache.Builders[builderHost] = buildContext.Digest
 	} else {
-		imageID, err = runClassicBuild(ctx, streams, docker, r, opts, relativedockerfilePath, buildArgs)
+		r, relativedockerfilePath, err := archiveContext(streams, opts, dockerfile, excludes, dockerFactory.mode.IsRemote())
+		if err != nil {
+			return nil, err
+		}
+		cmdfmt.PrintDone(streams.ErrOut, "Creating build context done")
+
+		cmdfmt.PrintBegin(streams.ErrOut, "Building image with Docker")
+		msg := fmt.Sprintf("docker host: %s %s %s", serverInfo.ServerVersion, serverInfo.OSType, serverInfo.Architecture)
+		cmdfmt.PrintDone(streams.ErrOut, msg)
+
+		imageID, err = runClassicBuild(ctx, streams, docker, r, opts, relativedockerfilePath, buildArgs, labels)
 		if err != nil {
 			return nil, errors.Wrap(err, "error building")
 		}
 	}
 
+	if err := contextCache.save(); err != nil {
+		terminal.Debugf("failed saving build context cache: %v\n", err)
+	}
+
 	cmdfmt.PrintDone(streams.ErrOut, "Building image done")
 
+	// the labels were part of the build, so only the ones returned record how
+	// long it took
+	labels = withDuration(labels, started)
+
 	if opts.Publish {
 		cmdfmt.PrintBegin(streams.ErrOut, "Pushing image to fly")
 
@@ -183,18 +192,78 @@ func (ds *dockerfileBuilder) Run(ctx context.Context, dockerFactory *dockerClien
 		cmdfmt.PrintDone(streams.ErrOut, "Pushing image done")
 	}
 
+	if err := exportImage(ctx, docker, streams, opts); err != nil {
+		return nil, err
+	}
+
+	if opts.CacheTo != nil {
+		cmdfmt.PrintBegin(streams.ErrOut, fmt.Sprintf("Exporting cache to %s", opts.CacheTo))
+
+		if err := exportCache(ctx, docker, streams, opts); err != nil {
+			return nil, errors.Wrap(err, "error exporting cache")
+		}
+
+		cmdfmt.PrintDone(streams.ErrOut, "Exporting cache done")
+	}
+
 	img, _, err := docker.ImageInspectWithRaw(ctx, imageID)
 	if err != nil {
 		return nil, errors.Wrap(err, "count not find built image")
 	}
 
 	return &DeploymentImage{
-		ID:   img.ID,
-		Tag:  opts.Tag,
-		Size: img.Size,
+		ID:     img.ID,
+		Tag:    opts.Tag,
+		Size:   img.Size,
+		Labels: labels,
 	}, nil
 }
 
+// archiveContext archives the build context, and the Dockerfile in case it's
+// outside of it, for the docker daemon, reporting the progress of uploading
+// it. It also returns the path to the Dockerfile within the archive.
+func archiveContext(streams *iostreams.IOStreams, opts ImageOptions, dockerfile string, excludes []string, compressed bool) (io.ReadCloser, string, error) {
+	archiveOpts := archiveOptions{
+		sourcePath: opts.WorkingDir,
+		compressed: compressed,
+		exclusions: excludes,
+	}
+
+	var relativedockerfilePath string
+
+	// copy dockerfile into the archive if it's outside the context dir
+	if !isPathInRoot(dockerfile, opts.WorkingDir) {
+		dockerfileData, err := os.ReadFile(dockerfile)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "error reading Dockerfile")
+		}
+		archiveOpts.additions = map[string][]byte{
+			"Dockerfile": dockerfileData,
+		}
+	} else {
+		// pass the relative path to Dockerfile within the context
+		p, err := filepath.Rel(opts.WorkingDir, dockerfile)
+		if err != nil {
+			return nil, "", err
+		}
+		relativedockerfilePath = p
+	}
+
+	// Create the docker build context as a compressed tar stream
+	r, err := archiveDirectory(archiveOpts)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error archiving build context")
+	}
+
+	// Setup an upload progress bar
+	progressOutput := streamformatter.NewProgressOutput(streams.Out)
+	if !streams.IsStdoutTTY() {
+		progressOutput = &lastProgressOutput{output: progressOutput}
+	}
+
+	return progress.NewProgressReader(r, progressOutput, 0, "", "Sending build context to Docker daemon"), relativedockerfilePath, nil
+}
+
 func normalizeBuildArgsForDocker(ctx context.Context, buildArgs map[string]string) (map[string]*string, error) {
 	var out = map[string]*string{}
 	//workingDirectory := state.WorkingDirectory(ctx)
@@ -219,15 +288,37 @@ func normalizeBuildArgsForDocker(ctx context.Context, buildArgs map[string]strin
 	return out, nil
 }
 
-func runClassicBuild(ctx context.Context, streams *iostreams.IOStreams, docker *dockerclient.Client, r io.ReadCloser, opts ImageOptions, dockerfilePath string, buildArgs map[string]*string) (imageID string, err error) {
+// withBuildArg returns a copy of buildArgs which sets the given one.
+func withBuildArg(buildArgs map[string]*string, key, value string) map[string]*string {
+	out := make(map[string]*string, len(buildArgs)+1)
+	for k, v := range buildArgs {
+		out[k] = v
+	}
+	out[key] = &value
+
+	return out
+}
+
+func runClassicBuild(ctx context.Context, streams *iostreams.IOStreams, docker *dockerclient.Client, r io.ReadCloser, opts ImageOptions, dockerfilePath string, buildArgs map[string]*string, labels map[string]string) (imageID string, err error) {
+	if opts.CacheTo != nil || len(opts.Secrets) > 0 {
+		return "", errors.New("exporting caches and build secrets require BuildKit")
+	}
+
+	cacheFrom, err := importCaches(ctx, docker, opts)
+	if err != nil {
+		return "", err
+	}
+
 	options := types.ImageBuildOptions{
 		Tags:        []string{opts.Tag},
 		BuildArgs:   buildArgs,
+		Labels:      labels,
 		AuthConfigs: authConfigs(),
-		Platform:    "linux/amd64",
+		Platform:    opts.platform(),
 		Dockerfile:  dockerfilePath,
 		Target:      opts.Target,
 		NoCache:     opts.NoCache,
+		CacheFrom:   cacheFrom,
 	}
 
 	resp, err := docker.ImageBuild(ctx, r, options)
@@ -251,19 +342,42 @@ func runClassicBuild(ctx context.Context, streams *iostreams.IOStreams, docker *
 	return imageID, nil
 }
 
-const uploadRequestRemote = "upload-request"
+// clientSessionRemote denotes build contexts synced through the build session.
+const clientSessionRemote = "client-session"
 
-func runBuildKitBuild(ctx context.Context, streams *iostreams.IOStreams, docker *dockerclient.Client, r io.ReadCloser, opts ImageOptions, dockerfilePath string, buildArgs map[string]*string) (imageID string, err error) {
+func runBuildKitBuild(ctx context.Context, streams *iostreams.IOStreams, docker *dockerclient.Client, opts ImageOptions, dockerfile string, excludes []string, buildArgs map[string]*string, labels map[string]string) (imageID string, err error) {
 	s, err := createBuildSession(opts.WorkingDir)
 	if err != nil {
 		panic(err)
 	}
 	s.Allow(newBuildkitAuthProvider())
+	s.Allow(newContextSyncProvider(opts.WorkingDir, dockerfile, excludes))
 
 	if s == nil {
 		panic("buildkit not supported")
 	}
 
+	if len(opts.Secrets) > 0 {
+		secrets, err := newSecretsProvider(opts.Secrets)
+		if err != nil {
+			return "", errors.Wrap(err, "error reading build secrets")
+		}
+		s.Allow(secrets)
+	}
+
+	cacheFrom, err := importCaches(ctx, docker, opts)
+	if err != nil {
+		return "", err
+	}
+
+	tags := []string{opts.Tag}
+	if tag := cacheTag(opts); tag != "" {
+		// caches are images which carry the metadata BuildKit reuses their
+		// layers by
+		tags = append(tags, tag)
+		buildArgs = withBuildArg(buildArgs, "BUILDKIT_INLINE_CACHE", "1")
+	}
+
 	eg, errCtx := errgroup.WithContext(ctx)
 
 	dialSession := func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
@@ -274,35 +388,23 @@ func runBuildKitBuild(ctx context.Context, streams *iostreams.IOStreams, docker
 	})
 
 	buildID := stringid.GenerateRandomID()
-	eg.Go(func() error {
-		buildOptions := types.ImageBuildOptions{
-			Version: types.BuilderBuildKit,
-			BuildID: uploadRequestRemote + ":" + buildID,
-		}
-
-		response, err := docker.ImageBuild(ctx, r, buildOptions)
-		if err != nil {
-			return err
-		}
-		defer response.Body.Close()
-		return nil
-	})
-
 	eg.Go(func() error {
 		defer s.Close()
 
 		buildOpts := types.ImageBuildOptions{
-			Tags:          []string{opts.Tag},
+			Tags:          tags,
 			BuildArgs:     buildArgs,
+			Labels:        labels,
 			Version:       types.BuilderBuildKit,
 			AuthConfigs:   authConfigs(),
 			SessionID:     s.ID(),
-			RemoteContext: uploadRequestRemote,
+			RemoteContext: clientSessionRemote,
 			BuildID:       buildID,
-			Platform:      "linux/amd64",
-			Dockerfile:    dockerfilePath,
+			Platform:      opts.platform(),
+			Dockerfile:    filepath.Base(dockerfile),
 			Target:        opts.Target,
 			NoCache:       opts.NoCache,
+			CacheFrom:     cacheFrom,
 		}
 
 		return func() error {
@@ -400,8 +502,14 @@ func runBuildKitBuild(ctx context.Context, streams *iostreams.IOStreams, docker
 }
 
 func pushToFly(ctx context.Context, docker *dockerclient.Client, streams *iostreams.IOStreams, tag string) error {
+	return pushImage(ctx, docker, streams, tag, flyRegistryAuth())
+}
+
+// pushImage pushes the given image to its registry with the given encoded
+// credentials.
+func pushImage(ctx context.Context, docker *dockerclient.Client, streams *iostreams.IOStreams, tag, auth string) error {
 	pushResp, err := docker.ImagePush(ctx, tag, types.ImagePushOptions{
-		RegistryAuth: flyRegistryAuth(),
+		RegistryAuth: auth,
 	})
 	if err != nil {
 		return errors.Wrap(err, "error pushing image to registry")
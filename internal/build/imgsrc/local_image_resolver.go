@@ -21,7 +21,7 @@ func (*localImageResolver) Name() string {
 	return "Local Image Reference"
 }
 
-func (*localImageResolver) Run(ctx context.Context, dockerFactory *dockerClientFactory, streams *iostreams.IOStreams, opts RefOptions) (*DeploymentImage, error) {
+func (*localImageResolver) Run(ctx context.Context, dockerFactory *DockerClientFactory, streams *iostreams.IOStreams, opts RefOptions) (*DeploymentImage, error) {
 	if !dockerFactory.mode.IsLocal() {
 		terminal.Debug("local docker daemon not available, skipping")
 		return nil, nil
@@ -0,0 +1,147 @@
+package imgsrc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	dockerclient "github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+
+	"github.com/superfly/flyctl/internal/cmdfmt"
+	"github.com/superfly/flyctl/pkg/iostreams"
+)
+
+// The types of Output.
+const (
+	// OutputOCI denotes OCI image layouts, which are directories.
+	OutputOCI = "oci"
+	// OutputDockerArchive denotes tarballs docker load loads.
+	OutputDockerArchive = "docker-archive"
+)
+
+// annotationRefName is the annotation which names images in OCI layouts.
+const annotationRefName = "org.opencontainers.image.ref.name"
+
+// Output is a destination on disk built images are written to, in addition to
+// the registry they're pushed to, if any.
+type Output struct {
+	Type string
+	Path string
+}
+
+// IsOutput reports whether spec denotes an Output rather than something else
+// flags accepting one may take, such as an output format.
+func IsOutput(spec string) bool {
+	return strings.HasPrefix(spec, OutputOCI+":") || strings.HasPrefix(spec, OutputDockerArchive+":")
+}
+
+// ParseOutput parses an Output of the form oci:<dir> or
+// docker-archive:<file>.
+func ParseOutput(spec string) (*Output, error) {
+	typ, path := splitPair(spec, ":")
+
+	switch {
+	case typ != OutputOCI && typ != OutputDockerArchive:
+		return nil, fmt.Errorf("invalid image output %q: expected %s:<dir> or %s:<file>", spec, OutputOCI, OutputDockerArchive)
+	case path == "":
+		return nil, fmt.Errorf("invalid image output %q: the path is missing", spec)
+	}
+
+	return &Output{Type: typ, Path: path}, nil
+}
+
+func (o *Output) String() string {
+	return o.Type + ":" + o.Path
+}
+
+// write writes img, named tag, to o. Images are added to existing OCI
+// layouts; archives are overwritten.
+func (o *Output) write(tag string, img v1.Image) error {
+	ref, err := name.NewTag(tag)
+	if err != nil {
+		return err
+	}
+
+	switch o.Type {
+	case OutputOCI:
+		p, err := layout.FromPath(o.Path)
+		if err != nil {
+			if p, err = layout.Write(o.Path, empty.Index); err != nil {
+				return err
+			}
+		}
+
+		return p.AppendImage(img, layout.WithAnnotations(map[string]string{
+			annotationRefName: ref.String(),
+		}))
+	case OutputDockerArchive:
+		return tarball.WriteToFile(o.Path, ref, img)
+	default:
+		return fmt.Errorf("unsupported image output type %q", o.Type)
+	}
+}
+
+// writeOutput writes img, as opts name it, to the Output opts specify, if
+// any.
+func writeOutput(streams *iostreams.IOStreams, opts ImageOptions, img v1.Image) error {
+	if opts.Output == nil {
+		return nil
+	}
+
+	cmdfmt.PrintBegin(streams.ErrOut, fmt.Sprintf("Writing image to %s", opts.Output))
+
+	if err := opts.Output.write(opts.Tag, img); err != nil {
+		return errors.Wrapf(err, "error writing image to %s", opts.Output)
+	}
+
+	cmdfmt.PrintDone(streams.ErrOut, "Writing image done")
+
+	return nil
+}
+
+// exportImage writes the image the docker daemon tags as opts specify to the
+// Output opts specify, if any.
+func exportImage(ctx context.Context, docker *dockerclient.Client, streams *iostreams.IOStreams, opts ImageOptions) error {
+	if opts.Output == nil {
+		return nil
+	}
+
+	// the daemon saves images as docker archives, which are read back from a
+	// temporary file since they're read more than once
+	f, err := os.CreateTemp("", "flyctl-image-*.tar")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	rc, err := docker.ImageSave(ctx, []string{opts.Tag})
+	if err != nil {
+		return errors.Wrap(err, "error saving image")
+	}
+	defer rc.Close()
+
+	if _, err := io.Copy(f, rc); err != nil {
+		return errors.Wrap(err, "error saving image")
+	}
+
+	ref, err := name.NewTag(opts.Tag)
+	if err != nil {
+		return err
+	}
+
+	img, err := tarball.ImageFromPath(f.Name(), &ref)
+	if err != nil {
+		return errors.Wrap(err, "error reading saved image")
+	}
+
+	return writeOutput(streams, opts, img)
+}
@@ -0,0 +1,75 @@
+package imgsrc
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutput(t *testing.T) {
+	out, err := ParseOutput("oci:build/image")
+	require.NoError(t, err)
+	assert.Equal(t, &Output{Type: OutputOCI, Path: "build/image"}, out)
+
+	out, err = ParseOutput("docker-archive:image.tar")
+	require.NoError(t, err)
+	assert.Equal(t, &Output{Type: OutputDockerArchive, Path: "image.tar"}, out)
+	assert.Equal(t, "docker-archive:image.tar", out.String())
+
+	_, err = ParseOutput("oci:")
+	assert.EqualError(t, err, `invalid image output "oci:": the path is missing`)
+
+	_, err = ParseOutput("tar:image.tar")
+	assert.EqualError(t, err, `invalid image output "tar:image.tar": expected oci:<dir> or docker-archive:<file>`)
+
+	assert.True(t, IsOutput("oci:dir"))
+	assert.False(t, IsOutput("jsonl"))
+}
+
+func TestOutputWrite(t *testing.T) {
+	const tag = "registry.fly.io/app:deployment-1"
+
+	img, err := random.Image(1024, 2)
+	require.NoError(t, err)
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+
+	// OCI layouts accumulate images
+	oci := &Output{Type: OutputOCI, Path: filepath.Join(dir, "oci")}
+	require.NoError(t, oci.write(tag, img))
+	require.NoError(t, oci.write(tag, img))
+
+	p, err := layout.FromPath(oci.Path)
+	require.NoError(t, err)
+
+	idx, err := p.ImageIndex()
+	require.NoError(t, err)
+
+	manifest, err := idx.IndexManifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Manifests, 2)
+	assert.Equal(t, digest, manifest.Manifests[0].Digest)
+	assert.Equal(t, tag, manifest.Manifests[0].Annotations[annotationRefName])
+
+	archive := &Output{Type: OutputDockerArchive, Path: filepath.Join(dir, "image.tar")}
+	require.NoError(t, archive.write(tag, img))
+
+	ref, err := name.NewTag(tag)
+	require.NoError(t, err)
+
+	loaded, err := tarball.ImageFromPath(archive.Path, &ref)
+	require.NoError(t, err)
+
+	loadedDigest, err := loaded.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, digest, loadedDigest)
+}
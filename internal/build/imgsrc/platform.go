@@ -0,0 +1,48 @@
+package imgsrc
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// DefaultPlatform is the platform images are built for unless ImageOptions
+// specify another one. It's the one Fly machines run on.
+const DefaultPlatform = "linux/amd64"
+
+// ParsePlatform parses a platform of the form os/arch or os/arch/variant, as
+// linux/arm64 or linux/arm/v7. Lists of platforms are rejected, as images are
+// built for a single one.
+func ParsePlatform(spec string) (*v1.Platform, error) {
+	if strings.Contains(spec, ",") {
+		return nil, fmt.Errorf("invalid platform %q: images are built for a single platform", spec)
+	}
+
+	parts := strings.Split(spec, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid platform %q: expected os/arch or os/arch/variant", spec)
+	}
+
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("invalid platform %q: expected os/arch or os/arch/variant", spec)
+		}
+	}
+
+	p := &v1.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+
+	return p, nil
+}
+
+// platform returns the platform opts select.
+func (opts ImageOptions) platform() string {
+	if opts.Platform != "" {
+		return opts.Platform
+	}
+
+	return DefaultPlatform
+}
@@ -0,0 +1,34 @@
+package imgsrc
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePlatform(t *testing.T) {
+	valid := map[string]v1.Platform{
+		"linux/amd64":  {OS: "linux", Architecture: "amd64"},
+		"linux/arm64":  {OS: "linux", Architecture: "arm64"},
+		"linux/arm/v7": {OS: "linux", Architecture: "arm", Variant: "v7"},
+	}
+
+	for spec, want := range valid {
+		got, err := ParsePlatform(spec)
+		if assert.NoError(t, err, spec) {
+			assert.Equal(t, want, *got, spec)
+		}
+	}
+
+	for _, spec := range []string{"", "linux", "linux/", "/amd64", "linux/arm/v7/x"} {
+		_, err := ParsePlatform(spec)
+		assert.EqualError(t, err, `invalid platform "`+spec+`": expected os/arch or os/arch/variant`)
+	}
+
+	_, err := ParsePlatform("linux/amd64,linux/arm64")
+	assert.EqualError(t, err, `invalid platform "linux/amd64,linux/arm64": images are built for a single platform`)
+
+	assert.Equal(t, DefaultPlatform, ImageOptions{}.platform())
+	assert.Equal(t, "linux/arm64", ImageOptions{Platform: "linux/arm64"}.platform())
+}
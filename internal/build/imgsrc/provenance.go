@@ -0,0 +1,230 @@
+package imgsrc
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+
+	"github.com/superfly/flyctl/internal/buildinfo"
+	"github.com/superfly/flyctl/terminal"
+)
+
+// The OCI labels images built by flyctl carry to record their provenance.
+const (
+	LabelRevision      = "org.opencontainers.image.revision"
+	LabelCreated       = "org.opencontainers.image.created"
+	LabelBranch        = "io.fly.build.branch"
+	LabelDirty         = "io.fly.build.dirty"
+	LabelStrategy      = "io.fly.build.strategy"
+	LabelFlyctlVersion = "io.fly.build.flyctl-version"
+	LabelDuration      = "io.fly.build.duration"
+)
+
+// Provenance describes how an image was built.
+type Provenance struct {
+	Revision      string        `json:"revision,omitempty"`
+	Branch        string        `json:"branch,omitempty"`
+	Dirty         bool          `json:"dirty,omitempty"`
+	Strategy      string        `json:"strategy,omitempty"`
+	FlyctlVersion string        `json:"flyctl_version,omitempty"`
+	Created       time.Time     `json:"created,omitempty"`
+	Duration      time.Duration `json:"duration,omitempty"`
+}
+
+// ProvenanceFromLabels returns the Provenance the given labels record or nil
+// in case they record none.
+func ProvenanceFromLabels(labels map[string]string) *Provenance {
+	var p Provenance
+
+	p.Revision = labels[LabelRevision]
+	p.Branch = labels[LabelBranch]
+	p.Dirty, _ = strconv.ParseBool(labels[LabelDirty])
+	p.Strategy = labels[LabelStrategy]
+	p.FlyctlVersion = labels[LabelFlyctlVersion]
+	p.Created, _ = time.Parse(time.RFC3339, labels[LabelCreated])
+	p.Duration, _ = time.ParseDuration(labels[LabelDuration])
+
+	if p == (Provenance{}) {
+		return nil
+	}
+
+	return &p
+}
+
+// ShortRevision returns the abbreviated revision of the Provenance, suffixed
+// with a marker in case the source had uncommitted changes.
+func (p *Provenance) ShortRevision() string {
+	rev := p.Revision
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+
+	if p.Dirty && rev != "" {
+		rev += "-dirty"
+	}
+
+	return rev
+}
+
+// buildLabels returns the labels an image built with the given strategy,
+// starting at the given time, is labeled with: the ones opts specify, which
+// take precedence, and the ones describing the build itself, short of its
+// duration; see withDuration.
+func buildLabels(opts ImageOptions, strategy string, started time.Time) map[string]string {
+	labels := map[string]string{
+		LabelStrategy:      strategy,
+		LabelFlyctlVersion: buildinfo.Version().String(),
+		LabelCreated:       started.UTC().Format(time.RFC3339),
+	}
+
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+
+	return labels
+}
+
+// withDuration adds the label recording the duration of the build which
+// started at the given time to labels. Builders which label images once
+// they're built add it to the image; the others, which pass the labels to the
+// build, add it to the labels of the DeploymentImage they return.
+func withDuration(labels map[string]string, started time.Time) map[string]string {
+	labels[LabelDuration] = time.Since(started).Round(time.Second).String()
+
+	return labels
+}
+
+// labelImage adds the given labels to the image tag refers to, retagging the
+// result, and returns the ID of the labeled image. It only touches the config
+// of the image; its layers are left as they are. It costs an extra build, so
+// it's only meant for images built by tools which can't label them, like pack.
+func labelImage(ctx context.Context, docker *dockerclient.Client, tag string, labels map[string]string) (string, error) {
+	var dockerfile strings.Builder
+	fmt.Fprintf(&dockerfile, "FROM %s\n", tag)
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(&dockerfile, "LABEL %s=%s\n", k, strconv.Quote(labels[k]))
+	}
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "Dockerfile", Mode: 0600, Size: int64(dockerfile.Len())}); err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(tw, dockerfile.String()); err != nil {
+		return "", err
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+
+	resp, err := docker.ImageBuild(ctx, &buf, types.ImageBuildOptions{
+		Tags:        []string{tag},
+		Version:     types.BuilderV1,
+		Remove:      true,
+		ForceRemove: true,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "error labeling image")
+	}
+	defer resp.Body.Close()
+
+	if err := jsonmessage.DisplayJSONMessagesStream(resp.Body, io.Discard, 0, false, nil); err != nil {
+		return "", errors.Wrap(err, "error labeling image")
+	}
+
+	img, _, err := docker.ImageInspectWithRaw(ctx, tag)
+	if err != nil {
+		return "", errors.Wrap(err, "error labeling image")
+	}
+
+	return img.ID, nil
+}
+
+// FetchLabels fetches the labels of the given image from its registry.
+func FetchLabels(ctx context.Context, ref string) (map[string]string, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := remote.Image(r, remote.WithContext(ctx), remote.WithAuthFromKeychain(registryKeychain()))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.Config.Labels, nil
+}
+
+// FetchProvenance fetches the provenance of the given images from their
+// registries, concurrently. Images which can't be fetched or which record no
+// provenance are absent from the returned map.
+func FetchProvenance(ctx context.Context, refs ...string) map[string]*Provenance {
+	const concurrency = 8
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, concurrency)
+		res = map[string]*Provenance{}
+	)
+
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		if ref == "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+
+		wg.Add(1)
+		go func(ref string) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			labels, err := FetchLabels(ctx, ref)
+			if err != nil {
+				terminal.Debugf("failed fetching labels of %s: %v\n", ref, err)
+
+				return
+			}
+
+			if p := ProvenanceFromLabels(labels); p != nil {
+				mu.Lock()
+				res[ref] = p
+				mu.Unlock()
+			}
+		}(ref)
+	}
+
+	wg.Wait()
+
+	return res
+}
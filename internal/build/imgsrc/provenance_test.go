@@ -0,0 +1,259 @@
+package imgsrc
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/pkg/iostreams"
+)
+
+func TestProvenanceLabels(t *testing.T) {
+	started := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	labels := buildLabels(ImageOptions{
+		Labels: map[string]string{
+			LabelRevision: "0123456789abcdef0123456789abcdef01234567",
+			LabelBranch:   "main",
+			LabelDirty:    "true",
+			LabelStrategy: "nix",
+		},
+	}, "dockerfile", started)
+
+	p := ProvenanceFromLabels(labels)
+	if assert.NotNil(t, p) {
+		assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", p.Revision)
+		assert.Equal(t, "main", p.Branch)
+		assert.True(t, p.Dirty)
+		assert.Equal(t, "nix", p.Strategy)
+		assert.Equal(t, started, p.Created)
+		assert.NotEmpty(t, p.FlyctlVersion)
+		assert.Equal(t, "0123456789ab-dirty", p.ShortRevision())
+		assert.Zero(t, p.Duration)
+	}
+
+	assert.Equal(t, "0s", withDuration(labels, time.Now())[LabelDuration])
+
+	assert.Nil(t, ProvenanceFromLabels(nil))
+	assert.Nil(t, ProvenanceFromLabels(map[string]string{"maintainer": "someone"}))
+}
+
+// fakeDaemon is a docker daemon which builds images with the classic builder,
+// recording the labels they're built with.
+type fakeDaemon struct {
+	mu     sync.Mutex
+	labels map[string]string
+}
+
+var apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+`)
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch path := apiVersionPrefix.ReplaceAllString(r.URL.Path, ""); {
+	case path == "/_ping":
+		w.Header().Set("API-Version", "1.41")
+		w.Header().Set("Builder-Version", "1")
+	case path == "/info":
+		_ = json.NewEncoder(w).Encode(types.Info{ServerVersion: "20.10.0", OSType: "linux", Architecture: "x86_64"})
+	case path == "/build":
+		var labels map[string]string
+		_ = json.Unmarshal([]byte(r.URL.Query().Get("labels")), &labels)
+		if labels == nil {
+			labels = map[string]string{}
+		}
+
+		// images labeled after they're built get their labels from the
+		// Dockerfile instead
+		tr := tar.NewReader(r.Body)
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				break
+			}
+			if hdr.Name != "Dockerfile" {
+				continue
+			}
+
+			data, _ := io.ReadAll(tr)
+			for _, line := range strings.Split(string(data), "\n") {
+				if strings.HasPrefix(line, "LABEL ") {
+					k, v := splitPair(strings.TrimPrefix(line, "LABEL "), "=")
+					labels[k], _ = strconv.Unquote(v)
+				}
+			}
+		}
+
+		d.mu.Lock()
+		d.labels = labels
+		d.mu.Unlock()
+
+		fmt.Fprintln(w, `{"stream":"built"}`)
+		fmt.Fprintln(w, `{"aux":{"ID":"sha256:built"}}`)
+	case path == "/images/json":
+		fmt.Fprintln(w, "[]")
+	case strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/json"):
+		_ = json.NewEncoder(w).Encode(types.ImageInspect{ID: "sha256:built", Size: 1})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (d *fakeDaemon) factory(t *testing.T) *DockerClientFactory {
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+
+	docker, err := dockerclient.NewClientWithOpts(dockerclient.WithHost("tcp://"+srv.Listener.Addr().String()), dockerclient.WithVersion("1.41"))
+	require.NoError(t, err)
+
+	return &DockerClientFactory{
+		mode: DockerDaemonTypeLocal,
+		buildFn: func(context.Context) (*dockerclient.Client, error) {
+			return docker, nil
+		},
+	}
+}
+
+func TestBuildersLabelImages(t *testing.T) {
+	t.Setenv("DOCKER_BUILDKIT", "0")
+
+	// the dockerfile builder caches digests of the build context under the
+	// working directory in the absence of a config directory
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	reg := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(reg.Close)
+
+	base := strings.TrimPrefix(reg.URL, "http://") + "/base:1"
+	ref, err := name.ParseReference(base)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, empty.Image))
+
+	want := []string{
+		LabelRevision,
+		LabelBranch,
+		LabelDirty,
+		LabelStrategy,
+		LabelFlyctlVersion,
+		LabelCreated,
+		LabelDuration,
+	}
+
+	source := map[string]string{
+		LabelRevision: "0123456789abcdef0123456789abcdef01234567",
+		LabelBranch:   "main",
+		LabelDirty:    "false",
+	}
+
+	cases := map[string]struct {
+		builder ImageBuilder
+		opts    ImageOptions
+		// image reports whether the image records the duration too, which
+		// only the builders which label images once they're built do
+		image bool
+	}{
+		"dockerfile": {
+			builder: &dockerfileBuilder{},
+		},
+		"builtin": {
+			builder: &builtinBuilder{},
+			opts:    ImageOptions{BuiltIn: "static"},
+		},
+		"daemonless": {
+			builder: &daemonlessBuilder{},
+			opts:    ImageOptions{Output: &Output{Type: OutputOCI}},
+			image:   true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"Dockerfile": "FROM " + base + "\nCOPY . /app\n",
+				"index.html": "hello",
+			})
+
+			opts := c.opts
+			opts.WorkingDir = dir
+			opts.AppName = "app"
+			opts.Tag = "registry.fly.io/app:deployment-1"
+			opts.Labels = source
+			if opts.Output != nil {
+				opts.Output.Path = filepath.Join(t.TempDir(), "image")
+			}
+
+			daemon := &fakeDaemon{}
+			streams, _, _, _ := iostreams.Test()
+
+			img, err := c.builder.Run(context.Background(), daemon.factory(t), streams, opts)
+			require.NoError(t, err)
+			require.NotNil(t, img)
+
+			for _, k := range want {
+				assert.Contains(t, img.Labels, k)
+			}
+			assert.Equal(t, c.builder.Name(), img.Labels[LabelStrategy])
+
+			imageLabels := daemon.labels
+			if opts.Output != nil {
+				idx, err := layout.ImageIndexFromPath(opts.Output.Path)
+				require.NoError(t, err)
+				manifest, err := idx.IndexManifest()
+				require.NoError(t, err)
+				built, err := idx.Image(manifest.Manifests[0].Digest)
+				require.NoError(t, err)
+				cfg, err := built.ConfigFile()
+				require.NoError(t, err)
+
+				imageLabels = cfg.Config.Labels
+			}
+
+			for k, v := range img.Labels {
+				if k == LabelDuration && !c.image {
+					assert.NotContains(t, imageLabels, k)
+					continue
+				}
+				assert.Equal(t, v, imageLabels[k], k)
+			}
+		})
+	}
+}
+
+func TestLabelImage(t *testing.T) {
+	daemon := &fakeDaemon{}
+
+	docker, err := daemon.factory(t).buildFn(context.Background())
+	require.NoError(t, err)
+
+	labels := withDuration(buildLabels(ImageOptions{}, "buildpacks", time.Now()), time.Now())
+
+	id, err := labelImage(context.Background(), docker, "registry.fly.io/app:deployment-1", labels)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:built", id)
+	assert.Equal(t, labels, daemon.labels)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/superfly/flyctl/pkg/iostreams"
 
@@ -27,6 +28,26 @@ type ImageOptions struct {
 	BuiltInSettings map[string]interface{}
 	Builder         string
 	Buildpacks      []string
+	// BuiltinSource is a file, directory or URL user-defined builtins are
+	// loaded from, in addition to the default ones.
+	BuiltinSource string
+	// Strategy names the ImageBuilder to build with. By default, the first
+	// registered one which applies builds the image.
+	Strategy string
+	// Labels are added to the built image, on top of the ones describing the
+	// build, which they override.
+	Labels map[string]string
+	// Output is where the built image is written to on disk, if anywhere.
+	Output *Output
+	// CacheFrom are the caches Dockerfile builds import from.
+	CacheFrom []*Cache
+	// CacheTo is the cache Dockerfile builds export to, if any.
+	CacheTo *Cache
+	// Secrets are served to Dockerfile builds through the BuildKit session.
+	Secrets []BuildSecret
+	// Platform is the platform images are built for, in the form os/arch.
+	// It defaults to DefaultPlatform; buildpacks builds don't support others.
+	Platform string
 }
 
 type RefOptions struct {
@@ -39,13 +60,14 @@ type RefOptions struct {
 }
 
 type DeploymentImage struct {
-	ID   string
-	Tag  string
-	Size int64
+	ID     string
+	Tag    string
+	Size   int64
+	Labels map[string]string
 }
 
 type Resolver struct {
-	dockerFactory *dockerClientFactory
+	dockerFactory *DockerClientFactory
 	apiClient     *api.Client
 }
 
@@ -71,23 +93,19 @@ func (r *Resolver) ResolveReference(ctx context.Context, streams *iostreams.IOSt
 	return nil, fmt.Errorf("could not find image \"%s\"", opts.ImageRef)
 }
 
-// BuildImage converts source code to an image using a Dockerfile, buildpacks, or builtins.
+// BuildImage converts source code to an image using the ImageBuilder opts
+// select or, by default, the first registered one which applies: buildpacks,
+// a Dockerfile or a builtin.
 func (r *Resolver) BuildImage(ctx context.Context, streams *iostreams.IOStreams, opts ImageOptions) (img *DeploymentImage, err error) {
-	if !r.dockerFactory.mode.IsAvailable() {
-		return nil, errors.New("docker is unavailable to build the deployment image")
-	}
-
 	if opts.Tag == "" {
 		opts.Tag = NewDeploymentTag(opts.AppName, opts.ImageLabel)
 	}
 
-	strategies := []imageBuilder{
-		&buildpacksBuilder{},
-		&dockerfileBuilder{},
-		&builtinBuilder{},
+	if opts.Strategy != "" {
+		return r.buildWith(ctx, streams, opts)
 	}
 
-	for _, s := range strategies {
+	for _, s := range Builders() {
 		terminal.Debugf("Trying '%s' strategy\n", s.Name())
 		img, err = s.Run(ctx, r.dockerFactory, streams, opts)
 		terminal.Debugf("result image:%+v error:%v\n", img, err)
@@ -99,9 +117,37 @@ func (r *Resolver) BuildImage(ctx context.Context, streams *iostreams.IOStreams,
 		}
 	}
 
+	if !r.dockerFactory.mode.IsAvailable() {
+		return nil, errDockerUnavailable
+	}
+
 	return nil, errors.New("app does not have a Dockerfile or buildpacks configured. See https://fly.io/docs/reference/configuration/#the-build-section")
 }
 
+// buildWith builds the image with the ImageBuilder opts select.
+func (r *Resolver) buildWith(ctx context.Context, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error) {
+	b := Builder(opts.Strategy)
+	if b == nil {
+		return nil, fmt.Errorf("unknown build strategy %q; available strategies are %s", opts.Strategy, strings.Join(BuilderNames(), ", "))
+	}
+
+	terminal.Debugf("Using '%s' strategy\n", b.Name())
+
+	img, err := b.Run(ctx, r.dockerFactory, streams, opts)
+	switch {
+	case err != nil:
+		return nil, err
+	case img != nil:
+		return img, nil
+	case !r.dockerFactory.mode.IsAvailable():
+		return nil, errDockerUnavailable
+	default:
+		return nil, fmt.Errorf("the %s build strategy doesn't apply to this app. See https://fly.io/docs/reference/configuration/#the-build-section", b.Name())
+	}
+}
+
+var errDockerUnavailable = errors.New("docker is unavailable to build the deployment image")
+
 func NewResolver(daemonType DockerDaemonType, apiClient *api.Client, appName string, iostreams *iostreams.IOStreams) *Resolver {
 	return &Resolver{
 		dockerFactory: newDockerClientFactory(daemonType, apiClient, appName, iostreams),
@@ -109,12 +155,7 @@ func NewResolver(daemonType DockerDaemonType, apiClient *api.Client, appName str
 	}
 }
 
-type imageBuilder interface {
-	Name() string
-	Run(ctx context.Context, dockerFactory *dockerClientFactory, streams *iostreams.IOStreams, opts ImageOptions) (*DeploymentImage, error)
-}
-
 type imageResolver interface {
 	Name() string
-	Run(ctx context.Context, dockerFactory *dockerClientFactory, streams *iostreams.IOStreams, opts RefOptions) (*DeploymentImage, error)
+	Run(ctx context.Context, dockerFactory *DockerClientFactory, streams *iostreams.IOStreams, opts RefOptions) (*DeploymentImage, error)
 }
@@ -0,0 +1,73 @@
+package imgsrc
+
+import (
+	"fmt"
+
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+)
+
+// BuildSecret is a secret Dockerfile builds mount with RUN --mount=type=secret.
+// Secrets are served to BuildKit through the build session, so they never
+// land in build args or image layers.
+type BuildSecret struct {
+	ID string
+	// Src is the file the secret is read from.
+	Src string
+	// Env is the environment variable the secret is read from.
+	Env string
+}
+
+// ParseBuildSecret parses a BuildSecret of the form id=<id>,src=<file> or
+// id=<id>,env=<variable>. Secrets which specify neither are read from the
+// environment variable named after them, in case it's set, or the file named
+// after them.
+func ParseBuildSecret(spec string) (BuildSecret, error) {
+	attrs, err := parseAttrs(spec)
+	if err != nil {
+		return BuildSecret{}, fmt.Errorf("invalid build secret %q: %w", spec, err)
+	}
+
+	var s BuildSecret
+	for k, v := range attrs {
+		switch k {
+		case "id":
+			s.ID = v
+		case "src", "source":
+			s.Src = expandHome(v)
+		case "env":
+			s.Env = v
+		default:
+			return BuildSecret{}, fmt.Errorf("invalid build secret %q: unsupported option %s", spec, k)
+		}
+	}
+
+	switch {
+	case s.ID == "":
+		return BuildSecret{}, fmt.Errorf("invalid build secret %q: the id is missing", spec)
+	case s.Src != "" && s.Env != "":
+		return BuildSecret{}, fmt.Errorf("invalid build secret %q: src and env are mutually exclusive", spec)
+	}
+
+	return s, nil
+}
+
+// newSecretsProvider returns the session attachable which serves the given
+// secrets to BuildKit.
+func newSecretsProvider(secrets []BuildSecret) (session.Attachable, error) {
+	sources := make([]secretsprovider.Source, 0, len(secrets))
+	for _, s := range secrets {
+		sources = append(sources, secretsprovider.Source{
+			ID:       s.ID,
+			FilePath: s.Src,
+			Env:      s.Env,
+		})
+	}
+
+	store, err := secretsprovider.NewStore(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	return secretsprovider.NewSecretProvider(store), nil
+}
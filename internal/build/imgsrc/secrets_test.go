@@ -0,0 +1,42 @@
+package imgsrc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBuildSecret(t *testing.T) {
+	cases := map[string]BuildSecret{
+		"id=npmrc,src=.npmrc":       {ID: "npmrc", Src: ".npmrc"},
+		"id=token,env=GITHUB_TOKEN": {ID: "token", Env: "GITHUB_TOKEN"},
+		"id=netrc":                  {ID: "netrc"},
+	}
+
+	for spec, want := range cases {
+		got, err := ParseBuildSecret(spec)
+		if assert.NoError(t, err, spec) {
+			assert.Equal(t, want, got, spec)
+		}
+	}
+
+	errs := map[string]string{
+		"src=.npmrc":                    `invalid build secret "src=.npmrc": the id is missing`,
+		"id=npmrc,src=.npmrc,env=NPMRC": `invalid build secret "id=npmrc,src=.npmrc,env=NPMRC": src and env are mutually exclusive`,
+		"id=npmrc,required=true":        `invalid build secret "id=npmrc,required=true": unsupported option required`,
+		"npmrc":                         `invalid build secret "npmrc": expected key=value, got "npmrc"`,
+	}
+
+	for spec, want := range errs {
+		_, err := ParseBuildSecret(spec)
+		assert.EqualError(t, err, want, spec)
+	}
+}
+
+func TestNewSecretsProvider(t *testing.T) {
+	_, err := newSecretsProvider([]BuildSecret{{ID: "npmrc", Src: "/does/not/exist"}})
+	assert.Error(t, err)
+
+	_, err = newSecretsProvider([]BuildSecret{{ID: "token", Env: "TOKEN"}})
+	assert.NoError(t, err)
+}
@@ -32,9 +32,13 @@ func Run(ctx context.Context, io *iostreams.IOStreams, args ...string) int {
 
 	cs := io.ColorScheme()
 
+	var exitCode flyerr.ExitCode
+
 	switch _, err := cmd.ExecuteContextC(ctx); {
 	case err == nil:
 		return 0
+	case errors.As(err, &exitCode):
+		return int(exitCode)
 	case errors.Is(err, context.Canceled), errors.Is(err, terminal.InterruptErr):
 		return 127
 	case errors.Is(err, context.DeadlineExceeded):
@@ -38,6 +38,7 @@ to the root URL of the deployed application.
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 	)
 
 	return
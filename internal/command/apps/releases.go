@@ -10,8 +10,10 @@ import (
 	"github.com/superfly/flyctl/api"
 	"github.com/superfly/flyctl/cmd/presenters"
 	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/build/imgsrc"
 	"github.com/superfly/flyctl/internal/client"
 	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/command/deploy"
 	"github.com/superfly/flyctl/internal/config"
 	"github.com/superfly/flyctl/internal/flag"
 	"github.com/superfly/flyctl/internal/render"
@@ -37,14 +39,22 @@ including type, when, success/fail and which user triggered the release.
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Bool{
 			Name:        "image",
 			Description: "Display the Docker image reference of the release",
 		},
+		flag.Bool{
+			Name:        "provenance",
+			Description: "Display the commit, branch and build strategy the image of the release was built from, as recorded by its labels",
+		},
 	)
 
-	return
+	cmd.AddCommand(
+		newReleasesRollback(),
+	)
 
+	return
 }
 
 func runReleases(ctx context.Context) error {
@@ -55,9 +65,44 @@ func runReleases(ctx context.Context) error {
 		return fmt.Errorf("failed retrieving app releases %s: %w", appName, err)
 	}
 
+	// the images of releases of git refs are tagged after their commit, which
+	// is thereby known without fetching the images
+	provenance := map[string]*imgsrc.Provenance{}
+	for _, release := range releases {
+		if rev := deploy.RevisionFromImage(release.ImageRef); rev != "" {
+			provenance[release.ImageRef] = &imgsrc.Provenance{Revision: rev}
+		}
+	}
+
+	withLabels := flag.GetBool(ctx, "provenance")
+	if withLabels {
+		refs := make([]string, 0, len(releases))
+		for _, release := range releases {
+			refs = append(refs, release.ImageRef)
+		}
+
+		for ref, p := range imgsrc.FetchProvenance(ctx, refs...) {
+			provenance[ref] = p
+		}
+	}
+
 	out := iostreams.FromContext(ctx).Out
 	if config.FromContext(ctx).JSONOutput {
-		return render.JSON(out, releases)
+		if len(provenance) == 0 {
+			return render.JSON(out, releases)
+		}
+
+		type releaseWithProvenance struct {
+			api.Release
+			Provenance *imgsrc.Provenance `json:",omitempty"`
+		}
+
+		withProvenance := make([]releaseWithProvenance, 0, len(releases))
+		for _, release := range releases {
+			withProvenance = append(withProvenance, releaseWithProvenance{release, provenance[release.ImageRef]})
+		}
+
+		return render.JSON(out, withProvenance)
 	}
 
 	var rows [][]string
@@ -78,6 +123,13 @@ func runReleases(ctx context.Context) error {
 			row = append(row, release.ImageRef)
 		}
 
+		switch p := provenance[release.ImageRef]; {
+		case withLabels:
+			row = append(row, formatProvenance(p)...)
+		case len(provenance) > 0:
+			row = append(row, formatProvenance(p)[0])
+		}
+
 		rows = append(rows, row)
 
 	}
@@ -95,9 +147,24 @@ func runReleases(ctx context.Context) error {
 		headers = append(headers, "Docker Image")
 	}
 
+	switch {
+	case withLabels:
+		headers = append(headers, "Commit", "Branch", "Builder")
+	case len(provenance) > 0:
+		headers = append(headers, "Commit")
+	}
+
 	return render.Table(out, "", rows, headers...)
 }
 
+func formatProvenance(p *imgsrc.Provenance) []string {
+	if p == nil {
+		return []string{"", "", ""}
+	}
+
+	return []string{p.ShortRevision(), p.Branch, p.Strategy}
+}
+
 func formatReleaseReason(reason string) string {
 	switch reason {
 	case "change_image":
@@ -0,0 +1,178 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/api"
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/client"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/command/deploy"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/internal/render"
+)
+
+func newReleasesRollback() (cmd *cobra.Command) {
+	const (
+		long = `Roll an application back to a past release by deploying the Docker image
+and the config that release was deployed with as a new release. The changes
+the rollback makes are shown before it's confirmed.
+`
+		short = "Roll an app back to a past release"
+		usage = "rollback <VERSION>"
+	)
+
+	cmd = command.New(usage, short, long, runReleasesRollback,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.ExactArgs(1)
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Environment(),
+		flag.Yes(),
+		flag.Detach(),
+		flag.String{
+			Name:        "strategy",
+			Description: "The strategy for replacing running instances. Options are canary, rolling, bluegreen, or immediate.",
+		},
+	)
+
+	return
+}
+
+func runReleasesRollback(ctx context.Context) error {
+	var (
+		appName   = app.NameFromContext(ctx)
+		apiClient = client.FromContext(ctx).API()
+	)
+
+	version, err := parseReleaseVersion(flag.FirstArg(ctx))
+	if err != nil {
+		return err
+	}
+
+	target, err := apiClient.GetAppReleaseByVersion(ctx, appName, version)
+	if err != nil {
+		return fmt.Errorf("failed retrieving release v%d of %s: %w", version, appName, err)
+	}
+	if target.ImageRef == "" {
+		return fmt.Errorf("release v%d of %s references no image to roll back to", version, appName)
+	}
+
+	current, err := currentRelease(ctx, appName)
+	if err != nil {
+		return err
+	}
+	if current != nil && current.Version == target.Version {
+		return fmt.Errorf("v%d is the current release of %s", version, appName)
+	}
+
+	targetDefinition := releaseDefinition(ctx, target)
+
+	if !flag.GetYes(ctx) {
+		printRollback(ctx, current, target, targetDefinition)
+
+		switch confirmed, err := prompt.Confirmf(ctx, "Roll %s back to v%d?", appName, version); {
+		case err == nil:
+			if !confirmed {
+				return nil
+			}
+		case prompt.IsNonInteractive(err):
+			return prompt.NonInteractiveError("yes flag must be specified when not running interactively")
+		default:
+			return err
+		}
+	}
+
+	tb := render.NewTextBlock(ctx, fmt.Sprintf("Rolling %s back to v%d", appName, version))
+
+	release, releaseCommand, err := deploy.Redeploy(ctx, target, target.Config, flag.GetString(ctx, "strategy"))
+	if err != nil {
+		return fmt.Errorf("failed rolling back to v%d: %w", version, err)
+	}
+
+	tb.Donef("release v%d created from v%d\n", release.Version, version)
+
+	if flag.GetDetach(ctx) {
+		return nil
+	}
+
+	return deploy.MonitorRelease(ctx, release, releaseCommand)
+}
+
+// parseReleaseVersion parses release versions given either as 12 or as v12.
+func parseReleaseVersion(arg string) (int, error) {
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(arg), "v"))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid release version %q", arg)
+	}
+
+	return version, nil
+}
+
+// currentRelease returns the named app's current release, along with the config
+// it was released with, or nil in case the app hasn't been released yet.
+func currentRelease(ctx context.Context, appName string) (*api.Release, error) {
+	apiClient := client.FromContext(ctx).API()
+
+	a, err := apiClient.GetApp(ctx, appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving app %s: %w", appName, err)
+	}
+	if a.CurrentRelease == nil {
+		return nil, nil
+	}
+
+	current, err := apiClient.GetAppReleaseByVersion(ctx, appName, a.CurrentRelease.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving current release of %s: %w", appName, err)
+	}
+
+	return current, nil
+}
+
+func releaseDefinition(ctx context.Context, release *api.Release) app.Definition {
+	if release.Config == nil {
+		return app.Definition{}
+	}
+
+	return command.DecodeDefinition(ctx, fmt.Sprintf("config of v%d", release.Version), release.Config.Definition)
+}
+
+func printRollback(ctx context.Context, current, target *api.Release, targetDefinition app.Definition) {
+	io := iostreams.FromContext(ctx)
+	colorize := io.ColorScheme()
+
+	if current == nil {
+		fmt.Fprintf(io.ErrOut, "Rolling back to v%d, which deploys %s\n", target.Version, target.ImageRef)
+
+		return
+	}
+
+	fmt.Fprintf(io.ErrOut, "Changes from v%d (current) to v%d:\n", current.Version, target.Version)
+
+	if current.ImageRef != target.ImageRef {
+		fmt.Fprintln(io.ErrOut, colorize.Yellow(fmt.Sprintf("~ image: %s => %s", current.ImageRef, target.ImageRef)))
+	}
+
+	currentDefinition := releaseDefinition(ctx, current)
+
+	changes := app.Diff(&currentDefinition, &targetDefinition)
+	deploy.PrintChanges(io.ErrOut, colorize, "", changes)
+
+	if len(changes) == 0 && current.ImageRef == target.ImageRef {
+		fmt.Fprintln(io.ErrOut, "no changes")
+	}
+}
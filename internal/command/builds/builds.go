@@ -12,14 +12,18 @@ func New() (cmd *cobra.Command) {
 	const (
 		long = `Build commands expose your local and remote builds.
 The LIST command will list all builds along with their status.
+The CONTEXT command will describe the files builds are sent.
 `
 		short = "Manage application builds"
 	)
 
 	cmd = command.New("builds", short, long, nil)
 
+	cmd.Aliases = []string{"build"}
+
 	cmd.AddCommand(
 		newList(),
+		newContext(),
 	)
 
 	return
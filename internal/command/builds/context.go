@@ -0,0 +1,89 @@
+package builds
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/build/imgsrc"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/internal/state"
+	"github.com/superfly/flyctl/pkg/iostreams"
+)
+
+func newContext() (cmd *cobra.Command) {
+	const (
+		long = `Describe the build context deploy sends to the builder: the files of the
+working directory which its ignore file doesn't exclude. The ignore file is,
+in order of precedence, .flyignore, the <Dockerfile>.dockerignore file next to
+the Dockerfile or .dockerignore.
+`
+		short = "Describe the build context"
+	)
+
+	cmd = command.New("context [WORKING_DIRECTORY]", short, long, runContext,
+		command.ChangeWorkingDirectoryToFirstArgIfPresent,
+		command.LoadAppConfigIfPresent,
+	)
+
+	cmd.Args = cobra.MaximumNArgs(1)
+
+	flag.Add(cmd,
+		flag.AppConfig(),
+		flag.Environment(),
+		flag.String{
+			Name:        "dockerfile",
+			Description: "Path to a Dockerfile. Defaults to the one the app config specifies or the Dockerfile in the working directory.",
+		},
+		flag.Bool{
+			Name:        "list",
+			Description: "List every file the build context consists of",
+		},
+	)
+
+	return
+}
+
+func runContext(ctx context.Context) error {
+	wd := state.WorkingDirectory(ctx)
+	out := iostreams.FromContext(ctx).Out
+
+	dockerfile := flag.GetString(ctx, "dockerfile")
+	if cfg := app.ConfigFromContext(ctx); dockerfile == "" && cfg != nil && cfg.Dockerfile() != "" {
+		dockerfile = filepath.Join(filepath.Dir(cfg.Path), cfg.Dockerfile())
+	}
+
+	ignoreFile, files, err := imgsrc.ContextFiles(wd, dockerfile)
+	if err != nil {
+		return fmt.Errorf("failed reading build context: %w", err)
+	}
+
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(out, struct {
+			IgnoreFile string   `json:"ignore_file"`
+			Files      []string `json:"files"`
+		}{ignoreFile, files})
+	}
+
+	if flag.GetBool(ctx, "list") {
+		for _, f := range files {
+			fmt.Fprintln(out, f)
+		}
+
+		return nil
+	}
+
+	if ignoreFile == "" {
+		fmt.Fprintln(out, "No ignore file; the build context is the whole working directory")
+	} else {
+		fmt.Fprintf(out, "Ignore file: %s\n", ignoreFile)
+	}
+
+	return imgsrc.PrintContextSummary(out, wd, dockerfile)
+}
@@ -31,6 +31,7 @@ func newList() (cmd *cobra.Command) {
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 	)
 
 	return
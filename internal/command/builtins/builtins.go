@@ -0,0 +1,52 @@
+// Package builtins implements the builtins command chain.
+package builtins
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/build/imgsrc/builtins"
+	"github.com/superfly/flyctl/internal/command"
+)
+
+// New initializes and returns a new builtins Command.
+func New() (cmd *cobra.Command) {
+	const (
+		long = `View the builtins deploy builds images with: the ones flyctl ships with and
+the user-defined ones, which are loaded from the builtins directory of the
+flyctl config directory and the builtin_source the build section of the app
+config specifies.
+`
+		short = "View Flyctl deployment builtins"
+	)
+
+	cmd = command.New("builtins", short, long, nil)
+
+	cmd.AddCommand(
+		newList(),
+		newShow(),
+	)
+
+	return
+}
+
+// sources returns the sources of user-defined builtins the app config in ctx,
+// if any, specifies.
+func sources(ctx context.Context) []string {
+	if cfg := app.ConfigFromContext(ctx); cfg != nil && cfg.BuiltinSource() != "" {
+		return []string{cfg.BuiltinSource()}
+	}
+
+	return nil
+}
+
+// source returns the description of the source of b.
+func source(b builtins.Builtin) string {
+	if b.Source == "" {
+		return "flyctl"
+	}
+
+	return b.Source
+}
@@ -0,0 +1,70 @@
+package builtins
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/internal/build/imgsrc/builtins"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/pkg/iostreams"
+)
+
+func newList() (cmd *cobra.Command) {
+	const (
+		long = `List the available builtins, the ones flyctl ships with first, along with
+their descriptions and the sources the user-defined ones are loaded from.
+`
+		short = "List available builtins"
+	)
+
+	cmd = command.New("list", short, long, runList,
+		command.LoadAppConfigIfPresent,
+	)
+
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.AppConfig(),
+		flag.Environment(),
+	)
+
+	return
+}
+
+func runList(ctx context.Context) error {
+	all, err := builtins.All(ctx, sources(ctx)...)
+	if err != nil {
+		return err
+	}
+
+	out := iostreams.FromContext(ctx).Out
+	if config.FromContext(ctx).JSONOutput {
+		type listed struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			Source      string `json:"source"`
+		}
+
+		list := make([]listed, 0, len(all))
+		for _, b := range all {
+			list = append(list, listed{b.Name, b.Description, source(b)})
+		}
+
+		return render.JSON(out, list)
+	}
+
+	rows := make([][]string, 0, len(all))
+	for _, b := range all {
+		rows = append(rows, []string{
+			b.Name,
+			b.Description,
+			source(b),
+		})
+	}
+
+	return render.Table(out, "", rows, "Name", "Description", "Source")
+}
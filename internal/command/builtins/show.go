@@ -0,0 +1,90 @@
+package builtins
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/build/imgsrc/builtins"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/pkg/iostreams"
+)
+
+func newShow() (cmd *cobra.Command) {
+	const (
+		long = `Show the details of a builtin: its settings and the Dockerfile it builds
+with. The Dockerfile is rendered with the settings of the app config, in case
+it uses the builtin, or the defaults otherwise.
+`
+		short = "Show details of a builtin"
+	)
+
+	cmd = command.New("show <builtin name>", short, long, runShow,
+		command.LoadAppConfigIfPresent,
+	)
+
+	cmd.Args = cobra.ExactArgs(1)
+
+	flag.Add(cmd,
+		flag.AppConfig(),
+		flag.Environment(),
+	)
+
+	return
+}
+
+func runShow(ctx context.Context) error {
+	name := flag.FirstArg(ctx)
+
+	b, err := builtins.Find(ctx, name, sources(ctx)...)
+	if err != nil {
+		return err
+	}
+
+	var settings map[string]interface{}
+	if cfg := app.ConfigFromContext(ctx); cfg != nil && cfg.Build != nil && cfg.Build.Builtin == name {
+		settings = cfg.Build.Settings
+	}
+
+	dockerfile, err := b.GetVDockerfile(settings)
+	if err != nil {
+		return fmt.Errorf("failed rendering builtin %s: %w", name, err)
+	}
+
+	out := iostreams.FromContext(ctx).Out
+
+	fmt.Fprintf(out, "Name:        %s\n", b.Name)
+	fmt.Fprintf(out, "Description: %s\n", b.Description)
+	fmt.Fprintf(out, "Source:      %s\n", source(*b))
+	if b.Details != "" {
+		fmt.Fprintf(out, "Details:\n%s\n", b.Details)
+	}
+
+	if len(b.Settings) > 0 {
+		fmt.Fprintln(out)
+
+		resolved := b.ResolveSettings(settings)
+
+		rows := make([][]string, 0, len(b.Settings))
+		for _, s := range b.Settings {
+			rows = append(rows, []string{
+				s.Name,
+				fmt.Sprint(resolved[s.Name]),
+				fmt.Sprint(s.Default),
+				s.Description,
+			})
+		}
+
+		if err := render.Table(out, "Settings", rows, "Name", "Value", "Default", "Description"); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(out, "\nDockerfile:\n%s\n", dockerfile)
+
+	return nil
+}
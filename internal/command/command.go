@@ -26,6 +26,7 @@ import (
 	"github.com/superfly/flyctl/internal/app"
 	"github.com/superfly/flyctl/internal/cache"
 	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/event"
 	"github.com/superfly/flyctl/internal/flag"
 	"github.com/superfly/flyctl/internal/state"
 	"github.com/superfly/flyctl/internal/task"
@@ -439,14 +440,44 @@ func RequireSession(ctx context.Context) (context.Context, error) {
 
 // LoadAppConfigIfPresent is a Preparer which loads the application's
 // configuration file from the path the user has selected via command line args
-// or the current working directory.
+// or the current working directory. In case the user has selected an
+// environment, its overlay is merged onto the configuration.
+//
+// Configurations with invalid fields or unresolved variable references are
+// loaded nonetheless, with the fields holding zero values or the references as
+// they are, and their errors are logged. Commands which consume every resolved
+// field, such as deploy, should reject them via the Err method of the loaded
+// configuration.
 func LoadAppConfigIfPresent(ctx context.Context) (context.Context, error) {
 	logger := logger.FromContext(ctx)
 
-	for _, path := range appConfigFilePaths(ctx) {
-		switch cfg, err := app.LoadConfig(path); {
+	opts := AppConfigLoadOptions(ctx)
+	environment := opts.Environment
+
+	for _, path := range AppConfigFilePaths(ctx) {
+		cfg, err := app.LoadConfigWithOptions(path, opts)
+
+		var verr *app.ValidationError
+		if errors.As(err, &verr) {
+			for _, fe := range verr.Errors {
+				if fe.Unresolved() {
+					// most commands don't consume the fields in question
+					logger.Debugf("app config %s: %s", path, fe)
+				} else {
+					logger.Warnf("app config %s: %s", path, fe)
+				}
+			}
+
+			err = nil
+		}
+
+		switch {
 		case err == nil:
-			logger.Debugf("app config loaded from %s", path)
+			if environment != "" {
+				logger.Debugf("app config loaded from %s with overlay %s", path, cfg.OverlayPath)
+			} else {
+				logger.Debugf("app config loaded from %s", path)
+			}
 
 			return app.WithConfig(ctx, cfg), nil // we loaded a configuration file
 		case errors.Is(err, fs.ErrNotExist):
@@ -458,21 +489,71 @@ func LoadAppConfigIfPresent(ctx context.Context) (context.Context, error) {
 		}
 	}
 
+	if environment != "" {
+		return nil, fmt.Errorf("environment %q selected but no app config found to apply its overlay to", environment)
+	}
+
 	return ctx, nil
 }
 
-// appConfigFilePaths returns the possible paths at which we may find a fly.toml
-// in order of preference. it takes into consideration whether the user has
-// specified a command-line path to a config file.
-func appConfigFilePaths(ctx context.Context) (paths []string) {
+// DecodeDefinition decodes m, the definition of a config the API holds, such as
+// the deployed one. As the API accepted the definition already, fields which
+// fail to decode don't fail the command; their errors are logged and the
+// fields kept as they were, so that the definition is sent back unaltered.
+func DecodeDefinition(ctx context.Context, source string, m map[string]interface{}) app.Definition {
+	def, err := app.DefinitionFromMap(m)
+
+	var verr *app.ValidationError
+	if errors.As(err, &verr) {
+		logger := logger.FromContext(ctx)
+		for _, fe := range verr.Errors {
+			logger.Warnf("%s: %s", source, fe)
+		}
+	}
+
+	return def
+}
+
+// AppConfigLoadOptions returns the options app configs should be loaded with
+// according to the user's selections. The env file variables are resolved from
+// may be selected via the FLY_ENV_FILE environment variable.
+func AppConfigLoadOptions(ctx context.Context) app.LoadOptions {
+	return app.LoadOptions{
+		Environment: Environment(ctx),
+		EnvFile:     env.First("FLY_ENV_FILE"),
+	}
+}
+
+// Environment returns the name of the environment the user has selected via
+// command line args or, in their absence, via the environment.
+func Environment(ctx context.Context) string {
+	if name := flag.GetEnvironment(ctx); name != "" {
+		return name
+	}
+
+	return env.First("FLY_ENVIRONMENT")
+}
+
+// AppConfigFilePaths returns the possible paths at which we may find an app
+// config file in order of preference. it takes into consideration whether the
+// user has specified a command-line path to a config file or a directory
+// containing one.
+func AppConfigFilePaths(ctx context.Context) (paths []string) {
+	dir := state.WorkingDirectory(ctx)
+
 	if p := flag.GetAppConfigFilePath(ctx); p != "" {
-		paths = append(paths, p, filepath.Join(p, app.DefaultConfigFileName))
+		if fi, err := os.Stat(p); err != nil || !fi.IsDir() {
+			paths = append(paths, p)
 
-		return
+			return
+		}
+
+		dir = p
 	}
 
-	wd := state.WorkingDirectory(ctx)
-	paths = append(paths, filepath.Join(wd, app.DefaultConfigFileName))
+	for _, name := range app.ConfigFileNames {
+		paths = append(paths, filepath.Join(dir, name))
+	}
 
 	return
 }
@@ -538,3 +619,31 @@ func ChangeWorkingDirectoryToFirstArgIfPresent(ctx context.Context) (context.Con
 
 	return state.WithWorkingDirectory(ctx, wd), nil
 }
+
+// OutputJSONLines denotes the output format which streams events as JSON
+// lines.
+const OutputJSONLines = "jsonl"
+
+// StreamEvents is a Preparer which, when the output flag selects the jsonl
+// format, makes the command stream events as JSON lines to stdout. The rest of
+// the output of the command is redirected to stderr.
+func StreamEvents(ctx context.Context) (context.Context, error) {
+	switch format := flag.GetOutput(ctx); format {
+	case "":
+		return ctx, nil
+	case OutputJSONLines:
+		break
+	default:
+		return nil, fmt.Errorf("unsupported output format %q: expected %s", format, OutputJSONLines)
+	}
+
+	io := iostreams.FromContext(ctx)
+	emitter := event.NewEmitter(io.Out)
+
+	streams := *io
+	streams.Out = io.ErrOut
+
+	ctx = iostreams.NewContext(ctx, &streams)
+
+	return event.NewContext(ctx, emitter), nil
+}
@@ -0,0 +1,31 @@
+// Package config implements the config command chain.
+package config
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/internal/command"
+)
+
+// New initializes and returns a new config Command.
+func New() (cmd *cobra.Command) {
+	const (
+		long = `The CONFIG commands allow you to work with an application's configuration.
+`
+		short = "Manage an app's configuration"
+	)
+
+	cmd = command.New("config", short, long, nil)
+
+	cmd.AddCommand(
+		newDisplay(),
+		newSave(),
+		newValidate(),
+		newConvert(),
+		newRender(),
+		newDiff(),
+		newEnv(),
+	)
+
+	return
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/helpers"
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/internal/state"
+)
+
+func newConvert() (cmd *cobra.Command) {
+	const (
+		long = `Convert an application's config file to another format. The format of the
+new file is implied by the extension of DESTINATION, which may be .toml,
+.yaml, .yml or .json. Use - as DESTINATION along with --format to print the
+converted config to standard output instead.
+
+Every format carries the same settings, so converting back and forth loses
+nothing but comments. Variable references are carried over unresolved.
+`
+		short = "Convert an app's config file to another format"
+		usage = "convert <DESTINATION>"
+	)
+
+	cmd = command.New(usage, short, long, runConvert)
+
+	cmd.Args = cobra.ExactArgs(1)
+
+	flag.Add(cmd,
+		flag.AppConfig(),
+		flag.Yes(),
+		flag.String{
+			Name:        "format",
+			Description: "Format to convert to (toml, yaml or json). Required when DESTINATION is -",
+		},
+	)
+
+	return
+}
+
+func runConvert(ctx context.Context) error {
+	dst := flag.FirstArg(ctx)
+
+	format := app.FormatFromPath(dst)
+	if f := flag.GetString(ctx, "format"); f != "" {
+		switch parsed := app.ParseFormat(f); {
+		case parsed == "":
+			return fmt.Errorf("unsupported format %q: expected toml, yaml or json", f)
+		case dst != "-" && parsed != format:
+			return fmt.Errorf("format %s doesn't match the extension of %s", f, dst)
+		default:
+			format = parsed
+		}
+	}
+
+	switch {
+	case format != "":
+		break
+	case dst == "-":
+		return errors.New("the format flag must be specified when writing to standard output")
+	default:
+		return fmt.Errorf("can't tell the format of %s: expected a .toml, .yaml, .yml or .json file", dst)
+	}
+
+	src, err := findAppConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := app.LoadConfigWithOptions(src, app.LoadOptions{
+		Verbatim: true, // references are to be carried over as they are
+	})
+
+	// fields which don't decode, such as references in place of integers, are
+	// carried over as they are as well
+	var verr *app.ValidationError
+	if err != nil && !errors.As(err, &verr) {
+		return fmt.Errorf("failed loading app config from %s: %w", src, err)
+	}
+
+	io := iostreams.FromContext(ctx)
+
+	if dst == "-" {
+		return cfg.Encode(io.Out, format)
+	}
+
+	if !filepath.IsAbs(dst) {
+		dst = filepath.Join(state.WorkingDirectory(ctx), dst)
+	}
+
+	if helpers.FileExists(dst) && !flag.GetYes(ctx) {
+		switch confirmed, err := prompt.Confirmf(ctx, "Overwrite file '%s'?", dst); {
+		case err == nil:
+			if !confirmed {
+				return nil
+			}
+		case prompt.IsNonInteractive(err):
+			return prompt.NonInteractiveError("yes flag must be specified when not running interactively")
+		default:
+			return err
+		}
+	}
+
+	if err := cfg.WriteToFile(dst); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(io.Out, "Converted %s to %s\n", helpers.PathRelativeToCWD(src), helpers.PathRelativeToCWD(dst))
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/api"
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/client"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/command/deploy"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flyerr"
+	"github.com/superfly/flyctl/internal/render"
+)
+
+// exitDiffers is the code config diff exits with when the configs differ.
+const exitDiffers = 2
+
+func newDiff() (cmd *cobra.Command) {
+	const (
+		long = `Compare an application's local config file with the config it's currently
+deployed with, or with the config of a past release. Both configs are
+normalized before they're compared, so that differences in number types, key
+order, duration formats and values the platform assumes by default don't
+count.
+
+The command exits with 0 when the configs match, with 2 when they differ and
+with 1 on errors, which makes it suitable for drift detection in CI.
+`
+		short = "Compare an app's local config file with a deployed one"
+	)
+
+	cmd = command.New("diff", short, long, runDiff,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Environment(),
+		flag.Int{
+			Name:        "version",
+			Description: "Version of the release to compare with instead of the current one",
+		},
+	)
+
+	return
+}
+
+// configDiff is the machine-readable result of diffing configs.
+type configDiff struct {
+	App       string       `json:"app"`
+	Version   int          `json:"version,omitempty"`
+	Path      string       `json:"path"`
+	Identical bool         `json:"identical"`
+	Changes   []app.Change `json:"changes"`
+}
+
+func runDiff(ctx context.Context) error {
+	appName := app.NameFromContext(ctx)
+
+	local := app.ConfigFromContext(ctx)
+	if local == nil {
+		return errors.New("no local app config found to compare")
+	}
+
+	version := flag.GetInt(ctx, "version")
+
+	var (
+		remote *api.AppConfig
+		err    error
+	)
+
+	apiClient := client.FromContext(ctx).API()
+	if version > 0 {
+		remote, err = apiClient.GetAppReleaseConfig(ctx, appName, version)
+	} else {
+		remote, err = apiClient.GetConfig(ctx, appName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed retrieving config of %s: %w", appName, err)
+	}
+
+	deployed := command.DecodeDefinition(ctx, "config of "+appName, remote.Definition)
+
+	result := configDiff{
+		App:     appName,
+		Version: version,
+		Path:    local.Path,
+		Changes: app.Diff(&deployed, &local.Definition),
+	}
+	if result.Changes == nil {
+		result.Changes = []app.Change{}
+	}
+	result.Identical = len(result.Changes) == 0
+
+	io := iostreams.FromContext(ctx)
+
+	if config.FromContext(ctx).JSONOutput {
+		if err := render.JSON(io.Out, result); err != nil {
+			return err
+		}
+	} else {
+		printDiff(io, result)
+	}
+
+	if !result.Identical {
+		return flyerr.ExitCode(exitDiffers)
+	}
+
+	return nil
+}
+
+func printDiff(io *iostreams.IOStreams, result configDiff) {
+	colorize := io.ColorScheme()
+
+	deployed := "deployed config"
+	if result.Version > 0 {
+		deployed = fmt.Sprintf("config of release v%d", result.Version)
+	}
+
+	if result.Identical {
+		fmt.Fprintf(io.Out, "%s %s matches the %s of %s\n", colorize.SuccessIcon(), result.Path, deployed, result.App)
+
+		return
+	}
+
+	fmt.Fprintln(io.Out, colorize.Red(fmt.Sprintf("--- %s of %s", deployed, result.App)))
+	fmt.Fprintln(io.Out, colorize.Green(fmt.Sprintf("+++ %s", result.Path)))
+
+	deploy.PrintChanges(io.Out, colorize, "", result.Changes)
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/client"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+)
+
+func newDisplay() (cmd *cobra.Command) {
+	const (
+		long = `Display an application's configuration. The configuration is presented
+in JSON format. The configuration data is retrieved from the Fly service.
+`
+		short = "Display an app's configuration"
+	)
+
+	cmd = command.New("display", short, long, runDisplay,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Environment(),
+	)
+
+	return
+}
+
+func runDisplay(ctx context.Context) error {
+	appName := app.NameFromContext(ctx)
+
+	cfg, err := client.FromContext(ctx).API().GetConfig(ctx, appName)
+	if err != nil {
+		return fmt.Errorf("failed retrieving config of %s: %w", appName, err)
+	}
+
+	return render.JSON(iostreams.FromContext(ctx).Out, cfg.Definition)
+}
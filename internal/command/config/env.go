@@ -0,0 +1,102 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/cmd/presenters"
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/client"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+)
+
+func newEnv() (cmd *cobra.Command) {
+	const (
+		long = `Display an app's runtime environment variables. It displays a section for
+secrets and another for config file defined environment variables.
+`
+		short = "Display an app's runtime environment variables"
+	)
+
+	cmd = command.New("env", short, long, runEnv,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Environment(),
+	)
+
+	return
+}
+
+func runEnv(ctx context.Context) error {
+	var (
+		appName = app.NameFromContext(ctx)
+		client  = client.FromContext(ctx).API()
+		out     = iostreams.FromContext(ctx).Out
+	)
+
+	secrets, err := client.GetAppSecrets(ctx, appName)
+	if err != nil {
+		return fmt.Errorf("failed retrieving secrets of %s: %w", appName, err)
+	}
+
+	cfg, err := client.GetConfig(ctx, appName)
+	if err != nil {
+		return fmt.Errorf("failed retrieving config of %s: %w", appName, err)
+	}
+
+	env, _ := cfg.Definition["env"].(map[string]interface{})
+
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(out, map[string]interface{}{
+			"secrets": secrets,
+			"env":     env,
+		})
+	}
+
+	if len(secrets) > 0 {
+		var rows [][]string
+		for _, secret := range secrets {
+			rows = append(rows, []string{
+				secret.Name,
+				secret.Digest,
+				presenters.FormatRelativeTime(secret.CreatedAt),
+			})
+		}
+
+		if err := render.Table(out, "Secrets", rows, "Name", "Digest", "Date"); err != nil {
+			return err
+		}
+	}
+
+	if len(env) > 0 {
+		names := make([]string, 0, len(env))
+		for name := range env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		rows := make([][]string, 0, len(names))
+		for _, name := range names {
+			rows = append(rows, []string{name, fmt.Sprint(env[name])})
+		}
+
+		return render.Table(out, "Environment variables", rows, "Name", "Value")
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+)
+
+func newRender() (cmd *cobra.Command) {
+	const (
+		long = `Render an application's effective config file, that is the config file with
+the overlay of the selected environment merged onto it and its ${VAR}
+references resolved. The rendered config is printed in the format of the
+config file, unless --format says otherwise. Rendering runs offline and
+requires no authentication.
+
+Use --sources to list where the value of each field came from instead.
+`
+		short = "Render an app's effective config file"
+	)
+
+	cmd = command.New("render", short, long, runRender)
+
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.AppConfig(),
+		flag.Environment(),
+		flag.String{
+			Name:        "format",
+			Description: "Format to render in (toml, yaml or json)",
+		},
+		flag.Bool{
+			Name:        "sources",
+			Description: "List where the value of each field came from",
+		},
+	)
+
+	return
+}
+
+func runRender(ctx context.Context) error {
+	path, err := findAppConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	format := app.FormatFromPath(path)
+	if f := flag.GetString(ctx, "format"); f != "" {
+		if format = app.ParseFormat(f); format == "" {
+			return fmt.Errorf("unsupported format %q: expected toml, yaml or json", f)
+		}
+	}
+
+	cfg, err := app.LoadConfigWithOptions(path, command.AppConfigLoadOptions(ctx))
+	if err != nil {
+		return fmt.Errorf("failed loading app config from %s: %w", path, err)
+	}
+
+	out := iostreams.FromContext(ctx).Out
+
+	if !flag.GetBool(ctx, "sources") {
+		return cfg.Encode(out, format)
+	}
+
+	origins := cfg.Origins()
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(out, origins)
+	}
+
+	rows := make([][]string, 0, len(origins))
+	for _, o := range origins {
+		rows = append(rows, []string{
+			o.Field,
+			fmt.Sprint(o.Value),
+			source(cfg, o),
+		})
+	}
+
+	return render.Table(out, "", rows, "Field", "Value", "Source")
+}
+
+// source describes where the value of a field came from.
+func source(cfg *app.Config, o app.Origin) string {
+	var parts []string
+
+	if o.Position != nil {
+		file := o.Position.File
+		if file == "" {
+			file = cfg.Path
+		}
+
+		parts = append(parts, fmt.Sprintf("%s:%d", filepath.Base(file), o.Position.Line))
+	}
+
+	for _, r := range o.Resolutions {
+		parts = append(parts, r.String())
+	}
+
+	return strings.Join(parts, ", ")
+}
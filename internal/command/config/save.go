@@ -0,0 +1,117 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/helpers"
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/client"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/prompt"
+	"github.com/superfly/flyctl/internal/state"
+)
+
+func newSave() (cmd *cobra.Command) {
+	const (
+		long = `Save an application's configuration locally. The configuration data is
+retrieved from the Fly service and saved in the format of the existing
+config file, or in TOML format when there's none. When an environment is
+selected, the configuration is saved to its overlay instead.
+
+Only the settings retrieved are replaced; everything else the file contains,
+such as the build section and its variable references, is kept as it is.
+`
+		short = "Save an app's config file"
+	)
+
+	cmd = command.New("save", short, long, runSave,
+		command.RequireSession,
+		command.RequireAppName,
+	)
+
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.App(),
+		flag.AppConfig(),
+		flag.Environment(),
+		flag.Yes(),
+	)
+
+	return
+}
+
+func runSave(ctx context.Context) error {
+	appName := app.NameFromContext(ctx)
+
+	path := filepath.Join(state.WorkingDirectory(ctx), app.DefaultConfigFileName)
+
+	loaded := app.ConfigFromContext(ctx)
+	switch {
+	case loaded == nil:
+		break
+	case loaded.OverlayPath != "":
+		path = loaded.OverlayPath
+	default:
+		path = loaded.Path
+	}
+
+	cfg := &app.Config{
+		Path: path,
+	}
+
+	if helpers.FileExists(path) {
+		if !flag.GetYes(ctx) {
+			switch confirmed, err := prompt.Confirmf(ctx, "Overwrite file '%s'?", path); {
+			case err == nil:
+				if !confirmed {
+					return nil
+				}
+			case prompt.IsNonInteractive(err):
+				return prompt.NonInteractiveError("yes flag must be specified when not running interactively")
+			default:
+				return err
+			}
+		}
+
+		// the file is loaded on its own and verbatim, rather than merged and
+		// resolved, so that what it's written back with is what it contains
+		var err error
+		cfg, err = app.LoadConfigWithOptions(path, app.LoadOptions{
+			Verbatim: true,
+		})
+
+		var verr *app.ValidationError
+		if err != nil && !errors.As(err, &verr) {
+			return fmt.Errorf("failed loading app config from %s: %w", path, err)
+		}
+	}
+
+	serverCfg, err := client.FromContext(ctx).API().GetConfig(ctx, appName)
+	if err != nil {
+		return fmt.Errorf("failed retrieving config of %s: %w", appName, err)
+	}
+
+	cfg.Definition = command.DecodeDefinition(ctx, "config of "+appName, serverCfg.Definition)
+
+	// keep the reference the app name is given by, if it resolves to it
+	if cfg.AppName == "" || loaded == nil || loaded.AppName != appName {
+		cfg.AppName = appName
+	}
+
+	if err := cfg.WriteToFile(path); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(iostreams.FromContext(ctx).Out, "Wrote config file", helpers.PathRelativeToCWD(path))
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+)
+
+func newValidate() (cmd *cobra.Command) {
+	const (
+		long = `Validates an application's config file locally against the constraints
+the Fly platform imposes. Validation runs offline and requires no
+authentication, which makes it suitable for pre-commit hooks and CI.
+
+Use --json for machine-readable diagnostics. The command exits with a
+non-zero status when the config contains errors.
+`
+		short = "Validate an app's config file"
+	)
+
+	cmd = command.New("validate", short, long, runValidate)
+
+	cmd.Args = cobra.NoArgs
+
+	flag.Add(cmd,
+		flag.AppConfig(),
+		flag.Environment(),
+		flag.Bool{
+			Name:        "strict",
+			Description: "Treat warnings as errors",
+		},
+	)
+
+	return
+}
+
+// validation is the machine-readable result of validating a config file.
+type validation struct {
+	Path        string           `json:"path"`
+	Overlay     string           `json:"overlay,omitempty"`
+	Valid       bool             `json:"valid"`
+	Diagnostics []app.Diagnostic `json:"diagnostics"`
+}
+
+func runValidate(ctx context.Context) error {
+	path, err := findAppConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := app.LoadConfigWithOptions(path, command.AppConfigLoadOptions(ctx))
+
+	result := validation{
+		Path: path,
+	}
+
+	var (
+		serr *app.SyntaxError
+		verr *app.ValidationError
+	)
+	switch {
+	case errors.As(err, &serr):
+		// we report syntax errors the way we report everything else
+		d := app.Diagnostic{
+			Severity: app.SeverityError,
+			Rule:     "syntax",
+			Message:  serr.Message,
+		}
+		if serr.Position.IsValid() {
+			pos := serr.Position
+			d.Position = &pos
+		}
+
+		result.Diagnostics = []app.Diagnostic{d}
+	case err == nil, errors.As(err, &verr):
+		// we report field errors along with everything else
+		result.Overlay = cfg.OverlayPath
+		result.Diagnostics = cfg.Validate()
+	default:
+		return fmt.Errorf("failed loading app config from %s: %w", path, err)
+	}
+
+	if result.Diagnostics == nil {
+		result.Diagnostics = []app.Diagnostic{}
+	}
+
+	result.Valid = !app.HasErrors(result.Diagnostics)
+	if flag.GetBool(ctx, "strict") && len(result.Diagnostics) > 0 {
+		result.Valid = false
+	}
+
+	io := iostreams.FromContext(ctx)
+
+	if config.FromContext(ctx).JSONOutput {
+		if err := render.JSON(io.Out, result); err != nil {
+			return err
+		}
+	} else {
+		printValidation(io, result)
+	}
+
+	if !result.Valid {
+		return errors.New("app config is not valid")
+	}
+
+	return nil
+}
+
+func printValidation(io *iostreams.IOStreams, result validation) {
+	colorize := io.ColorScheme()
+
+	if result.Overlay != "" {
+		fmt.Fprintf(io.Out, "Validating %s with overlay %s\n", result.Path, result.Overlay)
+	} else {
+		fmt.Fprintf(io.Out, "Validating %s\n", result.Path)
+	}
+
+	for _, d := range result.Diagnostics {
+		switch d.Severity {
+		case app.SeverityError:
+			fmt.Fprintln(io.Out, "   ", colorize.FailureIcon(), colorize.Red(d.String()))
+		default:
+			fmt.Fprintln(io.Out, "   ", colorize.WarningIcon(), colorize.Yellow(d.String()))
+		}
+	}
+
+	if result.Valid {
+		fmt.Fprintln(io.Out, colorize.SuccessIcon(), "Configuration is valid")
+	}
+}
+
+// findAppConfig returns the path to the app config file the user selected or,
+// in its absence, to the one in the working directory.
+func findAppConfig(ctx context.Context) (string, error) {
+	for _, path := range command.AppConfigFilePaths(ctx) {
+		switch fi, err := os.Stat(path); {
+		case err == nil && !fi.IsDir():
+			return path, nil
+		case err == nil, errors.Is(err, fs.ErrNotExist):
+			continue
+		default:
+			return "", err
+		}
+	}
+
+	return "", errors.New("app config file not found")
+}
@@ -0,0 +1,452 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/build/imgsrc"
+	"github.com/superfly/flyctl/internal/client"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/internal/state"
+)
+
+// Outcomes of deploying a single app of a workspace.
+const (
+	statusDeployed = "deployed"
+	statusBuilt    = "built"
+	statusPlanned  = "planned"
+	statusFailed   = "failed"
+	statusSkipped  = "skipped"
+	statusExcluded = "excluded"
+)
+
+// outcome describes what deploying a single app of a workspace came to.
+type outcome struct {
+	App     string `json:"app"`
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	Image   string `json:"image,omitempty"`
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// job tracks deploying a single app of a workspace.
+type job struct {
+	*member
+
+	ctx      context.Context
+	notified func()
+	out      *prefixWriter
+	img      *imgsrc.DeploymentImage
+	built    chan struct{}
+	outcome  outcome
+}
+
+func (j *job) fail(err error) {
+	j.outcome.Status = statusFailed
+	j.outcome.Error = err.Error()
+}
+
+// settled reports whether the outcome of j is known already, as is the case
+// for jobs which failed and for the ones of excluded members.
+func (j *job) settled() bool {
+	return j.outcome.Status != ""
+}
+
+// runWorkspace deploys every app of the workspace found in the working
+// directory. Images are built concurrently by a bounded pool of workers which
+// share a resolver per organization, while releases are created one app at a
+// time, in dependency order. Apps whose dependencies fail to deploy are skipped,
+// while apps whose configs fail to load don't stop the others from deploying.
+func runWorkspace(ctx context.Context) error {
+	for _, name := range []string{"app", "image", "dockerfile", "nix"} {
+		if flag.IsSet(ctx, name) {
+			return fmt.Errorf("--%s can't be used when deploying every app of a workspace", name)
+		}
+	}
+
+	root := state.WorkingDirectory(ctx)
+
+	ws, err := loadWorkspace(root)
+	if err != nil {
+		return err
+	}
+
+	members, err := loadMembers(ctx, root, ws)
+	if err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("no app configs found under %s", root)
+	}
+
+	io := iostreams.FromContext(ctx)
+
+	var (
+		mu   sync.Mutex
+		jobs = make([]*job, 0, len(members))
+		byID = make(map[*member]*job, len(members))
+	)
+
+	for _, m := range members {
+		j := &job{
+			member: m,
+			out:    newPrefixWriter(&mu, io.ErrOut, fmt.Sprintf("[%s] ", m.name())),
+			built:  make(chan struct{}),
+			outcome: outcome{
+				App:  m.name(),
+				Path: m.Path,
+			},
+		}
+		j.ctx = memberContext(ctx, io, m, j.out)
+
+		switch {
+		case m.err != nil:
+			j.fail(m.err)
+		case m.excluded != "":
+			j.outcome.Status = statusExcluded
+			j.outcome.Error = m.excluded
+		}
+
+		jobs = append(jobs, j)
+		byID[m] = j
+	}
+
+	if flag.GetBool(ctx, "dry-run") {
+		for _, j := range jobs {
+			if j.settled() {
+				continue
+			}
+
+			if cfg, err := determineAppConfig(j.ctx); err != nil {
+				j.fail(err)
+			} else if err := runDryRun(j.ctx, cfg); err != nil {
+				j.fail(err)
+			} else {
+				j.outcome.Status = statusPlanned
+			}
+
+			j.out.Flush()
+		}
+
+		return summarize(ctx, jobs)
+	}
+
+	defer func() {
+		for _, j := range jobs {
+			if j.notified != nil {
+				j.notified()
+			}
+		}
+	}()
+
+	for _, j := range jobs {
+		if j.settled() {
+			continue
+		}
+
+		if j.ctx, j.notified, err = withNotifications(j.ctx, j.config); err != nil {
+			return fmt.Errorf("%s: %w", j.name(), err)
+		}
+	}
+
+	go buildAll(ctx, jobs, concurrency(ctx, ws))
+
+	for _, j := range jobs {
+		<-j.built
+
+		if j.settled() {
+			continue
+		}
+
+		if dep := failedDependency(j, byID); dep != "" {
+			j.outcome.Status = statusSkipped
+			j.outcome.Error = fmt.Sprintf("dependency %s wasn't deployed", dep)
+
+			continue
+		}
+
+		if flag.GetBuildOnly(ctx) {
+			j.outcome.Status = statusBuilt
+
+			continue
+		}
+
+		release, err := deployImage(j.ctx, j.config, j.img)
+		if release != nil {
+			j.outcome.Version = release.Version
+		}
+
+		if err != nil {
+			j.fail(err)
+		} else {
+			j.outcome.Status = statusDeployed
+		}
+
+		j.out.Flush()
+	}
+
+	return summarize(ctx, jobs)
+}
+
+// memberContext derives the context the given member is deployed with from
+// ctx. Output is written to out, non-interactively, since several members may
+// be built at once.
+func memberContext(ctx context.Context, parent *iostreams.IOStreams, m *member, out io.Writer) context.Context {
+	streams := *parent
+	streams.Out = out
+	streams.ErrOut = out
+	streams.SetStdoutTTY(false)
+	streams.SetStderrTTY(false)
+	streams.SetNeverPrompt(true)
+
+	ctx = iostreams.NewContext(ctx, &streams)
+	ctx = state.WithWorkingDirectory(ctx, m.dir)
+	ctx = app.WithName(ctx, m.name())
+	ctx = app.WithConfig(ctx, m.config)
+
+	return ctx
+}
+
+// buildAll builds the images of jobs, at most n at a time, with the resolvers
+// of their organizations. Settled jobs aren't built.
+func buildAll(ctx context.Context, jobs []*job, n int) {
+	pending := make([]*job, 0, len(jobs))
+	for _, j := range jobs {
+		if j.settled() {
+			close(j.built)
+		} else {
+			pending = append(pending, j)
+		}
+	}
+	jobs = pending
+
+	resolvers := orgResolvers(ctx, jobs)
+
+	queue := make(chan *job)
+	go func() {
+		defer close(queue)
+
+		for _, j := range jobs {
+			select {
+			case queue <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n && i < len(jobs); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range queue {
+				build(j, resolvers[j])
+			}
+		}()
+	}
+	wg.Wait()
+
+	// release the jobs which never got built due to cancelation
+	for _, j := range jobs {
+		select {
+		case <-j.built:
+		default:
+			j.fail(ctx.Err())
+			close(j.built)
+		}
+	}
+}
+
+// orgResolvers returns the resolver each of jobs builds with. Jobs share the
+// resolver of their organization, since the remote builder a resolver uses
+// belongs to the organization of the app it's created for. Apps whose
+// organization can't be determined get a resolver of their own.
+func orgResolvers(ctx context.Context, jobs []*job) map[*job]*imgsrc.Resolver {
+	var (
+		apiClient = client.FromContext(ctx).API()
+		byOrg     = map[string]*imgsrc.Resolver{}
+		resolvers = make(map[*job]*imgsrc.Resolver, len(jobs))
+	)
+
+	for _, j := range jobs {
+		key := "app " + j.name()
+		if a, err := apiClient.GetAppCompact(ctx, j.name()); err == nil {
+			key = "org " + a.Organization.Slug
+		}
+
+		r, ok := byOrg[key]
+		if !ok {
+			r = newResolver(j.ctx)
+			byOrg[key] = r
+		}
+		resolvers[j] = r
+	}
+
+	return resolvers
+}
+
+func build(j *job, resolver *imgsrc.Resolver) {
+	defer close(j.built)
+	defer j.out.Flush()
+
+	cfg, err := determineAppConfig(j.ctx)
+	if err != nil {
+		j.fail(err)
+
+		return
+	}
+	j.config = cfg
+
+	if j.img, err = determineImage(j.ctx, resolver, cfg); err != nil {
+		j.fail(fmt.Errorf("failed to fetch an image or build from source: %w", err))
+
+		return
+	}
+
+	j.outcome.Image = j.img.Tag
+}
+
+// failedDependency returns the name of the first dependency of j which wasn't
+// deployed, or an empty string in case they all were. Dependencies excluded
+// from the selected environment have nothing to deploy.
+func failedDependency(j *job, byID map[*member]*job) string {
+	for _, dep := range j.deps {
+		switch byID[dep].outcome.Status {
+		case statusDeployed, statusBuilt, statusExcluded:
+			continue
+		default:
+			return dep.name()
+		}
+	}
+
+	return ""
+}
+
+func concurrency(ctx context.Context, ws *workspace) int {
+	switch {
+	case flag.GetInt(ctx, "concurrency") > 0:
+		return flag.GetInt(ctx, "concurrency")
+	case ws.Concurrency > 0:
+		return ws.Concurrency
+	default:
+		return defaultConcurrency
+	}
+}
+
+// summarize prints the outcomes of jobs and returns an error in case any of
+// them failed or was skipped.
+func summarize(ctx context.Context, jobs []*job) error {
+	var (
+		outcomes = make([]outcome, 0, len(jobs))
+		failed   int
+	)
+
+	for _, j := range jobs {
+		outcomes = append(outcomes, j.outcome)
+
+		if s := j.outcome.Status; s == statusFailed || s == statusSkipped {
+			failed++
+		}
+	}
+
+	out := iostreams.FromContext(ctx).Out
+
+	if config.FromContext(ctx).JSONOutput {
+		if err := render.JSON(out, outcomes); err != nil {
+			return err
+		}
+	} else {
+		rows := make([][]string, 0, len(outcomes))
+		for _, o := range outcomes {
+			version := ""
+			if o.Version > 0 {
+				version = "v" + strconv.Itoa(o.Version)
+			}
+
+			rows = append(rows, []string{o.App, o.Path, o.Status, version, o.Image, o.Error})
+		}
+
+		fmt.Fprintln(out)
+		if err := render.Table(out, "Summary", rows, "App", "Path", "Status", "Release", "Image", "Error"); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d apps weren't deployed", failed, len(jobs))
+	}
+
+	return nil
+}
+
+// prefixWriter prefixes every line written to it before passing it on to the
+// underlying writer. Writers which share a mutex don't interleave their lines.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    bytes.Buffer
+}
+
+func newPrefixWriter(mu *sync.Mutex, w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{
+		mu:     mu,
+		w:      w,
+		prefix: prefix,
+	}
+}
+
+// Write implements io.Writer for prefixWriter. Incomplete lines are held back
+// until they're completed or Flush is called.
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	pw.buf.Write(p)
+
+	for {
+		i := bytes.IndexByte(pw.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+
+		if err := pw.writeLine(pw.buf.Next(i + 1)); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush writes out the incomplete line pw holds back, if any.
+func (pw *prefixWriter) Flush() {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if pw.buf.Len() > 0 {
+		_ = pw.writeLine(append(pw.buf.Next(pw.buf.Len()), '\n'))
+	}
+}
+
+func (pw *prefixWriter) writeLine(line []byte) error {
+	if _, err := io.WriteString(pw.w, pw.prefix); err != nil {
+		return err
+	}
+
+	_, err := pw.w.Write(line)
+
+	return err
+}
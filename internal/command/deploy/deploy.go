@@ -19,7 +19,9 @@ import (
 	"github.com/superfly/flyctl/internal/app"
 	"github.com/superfly/flyctl/internal/build/imgsrc"
 	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/event"
 	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/flyerr"
 	"github.com/superfly/flyctl/internal/render"
 	"github.com/superfly/flyctl/internal/state"
 
@@ -32,14 +34,46 @@ import (
 func New() (cmd *cobra.Command) {
 	const (
 		long = `Deploy Fly applications from source or an image using a local or remote builder.
+
+With --all, every app of the workspace is deployed: the apps fly.workspace.toml
+lists, in dependency order, or in its absence every app config found under the
+working directory. Images are built concurrently and a summary of the outcome
+for each app is printed at the end. Apps whose configs fail to load don't stop
+the others from deploying, and apps without an overlay for the selected
+environment are excluded.
+
+With --git <url>#<ref>, the given ref of a git repository, or the directory of
+it following a colon, as in <url>#<ref>:<dir>, is checked out into a temporary
+directory and deployed from there. Images built from it are tagged
+git-<commit> and labeled after the commit deployed, which the releases command
+lists.
+
+With --output oci:<dir> or --output docker-archive:<file>, the built image is
+also written to disk, as an OCI image layout or a tarball, respectively.
+Combined with --build-only, images can be scanned and archived before they're
+pushed and deployed with --image.
+
+Dockerfile builds import caches with --cache-from and export them with
+--cache-to, either to a registry or a local directory. Caches are images
+carrying BuildKit's inline cache metadata, so only the layers of the final
+stage are cached; local ones are such images saved with docker save. Caches
+are therefore not exported from multi-stage builds.
+
+Secrets --build-secret specifies are exposed to RUN --mount=type=secret
+instructions through the BuildKit session, so they never land in build args or
+image layers.
+
+Images are built for linux/amd64 unless --platform selects another platform.
+Each build targets a single platform; multi-platform images aren't built.
 	`
 		short = "Deploy Fly applications"
 	)
 
 	cmd = command.New("deploy [WORKING_DIRECTORY]", short, long, run,
 		command.RequireSession,
+		prepareOutput,
 		command.ChangeWorkingDirectoryToFirstArgIfPresent,
-		command.RequireAppName,
+		requireAppNameUnlessWorkspace,
 	)
 
 	cmd.Args = cobra.MaximumNArgs(1)
@@ -47,6 +81,7 @@ func New() (cmd *cobra.Command) {
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Region(),
 		flag.Image(),
 		flag.Now(),
@@ -84,23 +119,103 @@ func New() (cmd *cobra.Command) {
 			Name:        "no-cache",
 			Description: "Do not use the build cache when building the image",
 		},
+		flag.StringArray{
+			Name:        "cache-from",
+			Description: "Cache to import when building from a Dockerfile, in the form type=registry,ref=<image> or type=local,src=<dir>. Can be specified multiple times.",
+		},
+		flag.String{
+			Name:        "cache-to",
+			Description: "Cache to export to when building from a single-stage Dockerfile, in the form type=registry,ref=<image> or type=local,dest=<dir>",
+		},
+		flag.String{
+			Name:        "platform",
+			Description: "Single platform to build the image for, in the form os/arch, as " + imgsrc.DefaultPlatform + ", the default one Fly machines run on. Lists of platforms aren't supported.",
+		},
+		flag.StringArray{
+			Name:        "build-secret",
+			Description: "Secret to expose to RUN --mount=type=secret instructions, in the form id=<id>,src=<file> or id=<id>,env=<variable>. Can be specified multiple times.",
+		},
 		flag.Bool{
 			Name:        "nix",
 			Description: "Build with Nix",
 		},
+		flag.String{
+			Name:        "output",
+			Description: "Output format. Use jsonl to stream events as JSON lines to stdout. Alternatively, oci:<dir> or docker-archive:<file> write the built image to an OCI layout or a tarball docker load loads",
+		},
+		flag.Bool{
+			Name:        "all",
+			Description: "Deploy every app of the workspace: the ones " + WorkspaceFileName + " lists or, in its absence, every app config found under the working directory",
+		},
+		flag.Int{
+			Name:        "concurrency",
+			Description: "Maximum number of images to build at once when deploying every app of the workspace",
+		},
+		flag.Bool{
+			Name:        "auto-rollback",
+			Description: "Redeploy the image and config of the last successful release when the deployment or its release command fails",
+		},
+		flag.StringSlice{
+			Name:        "notify",
+			Description: "URL of a webhook to post deployment notifications to, in addition to the ones the [notify] section of the app config lists. Supports http, https and slack URLs. Can be specified multiple times.",
+		},
+		flag.String{
+			Name:        "git",
+			Description: "Deploy the given ref of a git repository, in the form <url>#<ref>, from a fresh checkout of it. The url may also be the path to a local mirror.",
+		},
+		flag.Bool{
+			Name:        "dry-run",
+			Description: "Print what the deployment would change without building, pushing or releasing anything",
+		},
 	)
 
 	return
 }
 
-func run(ctx context.Context) error {
+func run(ctx context.Context) (err error) {
+	if isGitDeploy(ctx) {
+		if flag.FirstArg(ctx) != "" {
+			return errors.New("the working directory and --git are mutually exclusive; specify a directory of the repository as <url>#<ref>:<dir> instead")
+		}
+
+		var cleanup func()
+		if ctx, cleanup, err = checkoutGitSource(ctx); err != nil {
+			return
+		}
+		defer cleanup()
+
+		if !isWorkspaceDeploy(ctx) {
+			if ctx, err = command.RequireAppName(ctx); err != nil {
+				return
+			}
+		}
+	}
+
+	if isWorkspaceDeploy(ctx) {
+		if imageOutputFromContext(ctx) != nil {
+			return errors.New("--output can't write the images of every app of the workspace; deploy them one at a time instead")
+		}
+
+		return runWorkspace(ctx)
+	}
+
 	appConfig, err := determineAppConfig(ctx)
 	if err != nil {
 		return err
 	}
 
+	if flag.GetBool(ctx, "dry-run") {
+		return runDryRun(ctx, appConfig)
+	}
+
+	ctx, done, err := withNotifications(ctx, appConfig)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	// Fetch an image ref or build from source to get the final image reference to deploy
-	img, err := determineImage(ctx, appConfig)
+	img, err := determineImage(ctx, newResolver(ctx), appConfig)
 
 	if err != nil {
 		return fmt.Errorf("failed to fetch an image or build from source: %w", err)
@@ -110,29 +225,66 @@ func run(ctx context.Context) error {
 		return nil
 	}
 
+	_, err = deployImage(ctx, appConfig, img)
+
+	return err
+}
+
+// deployImage creates a release of the given image and, unless detached,
+// monitors it, rolling back in case it fails and auto-rollback is enabled.
+func deployImage(ctx context.Context, appConfig *app.Config, img *imgsrc.DeploymentImage) (*api.Release, error) {
 	release, releaseCommand, err := createRelease(ctx, appConfig, img)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if flag.GetDetach(ctx) {
-		return nil
+		emitDetached(ctx, release)
+
+		return release, nil
 	}
 
 	// TODO: This is a single message that doesn't belong to any block output, so we should have helpers to allow that
 	tb := render.NewTextBlock(ctx)
 	tb.Done("You can detach the terminal anytime without stopping the deployment")
 
+	err = MonitorRelease(ctx, release, releaseCommand)
+
+	var failure *releaseFailure
+	if errors.As(err, &failure) && flag.GetBool(ctx, "auto-rollback") {
+		err = rollback(ctx, failure)
+	}
+
+	return release, err
+}
+
+// MonitorRelease runs the release command of the given release, if it has
+// one, and then monitors its deployment. Failures of either are reported as
+// *releaseFailure.
+func MonitorRelease(ctx context.Context, release *api.Release, releaseCommand *api.ReleaseCommand) (err error) {
 	// Run the pre-deployment release command if it's set
 	if releaseCommand != nil {
 		// TODO: don't use text block here
 		tb := render.NewTextBlock(ctx, fmt.Sprintf("Release command detected: %s\n", releaseCommand.Command))
 		tb.Done("This release will not be available until the release command succeeds.")
 
-		if err := watch.ReleaseCommand(ctx, releaseCommand.ID); err != nil {
-			return err
+		if err = watch.ReleaseCommand(ctx, releaseCommand.ID); err != nil {
+			event.Emit(ctx, event.Event{
+				Type:    event.ReleaseCommandFailed,
+				App:     app.NameFromContext(ctx),
+				Version: release.Version,
+				Message: err.Error(),
+			})
+
+			return &releaseFailure{Release: release, Cause: err}
 		}
 
+		event.Emit(ctx, event.Event{
+			Type:    event.ReleaseCommandSucceeded,
+			App:     app.NameFromContext(ctx),
+			Version: release.Version,
+		})
+
 		apiClient := client.FromContext(ctx).API()
 		release, err = apiClient.GetAppRelease(ctx, app.NameFromContext(ctx), release.ID)
 		if err != nil {
@@ -147,9 +299,14 @@ func run(ctx context.Context) error {
 		return nil
 	}
 
-	err = watch.Deployment(ctx, release.EvaluationID)
+	if err = watch.Deployment(ctx, release.EvaluationID); errors.Is(err, flyerr.ErrAbort) {
+		err = &releaseFailure{
+			Release: release,
+			Cause:   fmt.Errorf("v%d failed to deploy", release.Version),
+		}
+	}
 
-	return err
+	return
 }
 
 // determineAppConfig fetches the app config from a local file, or in its absence, from the API
@@ -157,7 +314,13 @@ func determineAppConfig(ctx context.Context) (cfg *app.Config, err error) {
 	tb := render.NewTextBlock(ctx, "Verifying app config")
 	client := client.FromContext(ctx).API()
 
-	if cfg = app.ConfigFromContext(ctx); cfg == nil {
+	if cfg = app.ConfigFromContext(ctx); cfg != nil {
+		if err = cfg.Err(); err != nil {
+			err = fmt.Errorf("failed loading app config from %s: %w", cfg.Path, err)
+
+			return
+		}
+	} else {
 		logger := logger.FromContext(ctx)
 		logger.Debug("no local app config detected; fetching from backend ...")
 
@@ -169,7 +332,7 @@ func determineAppConfig(ctx context.Context) (cfg *app.Config, err error) {
 		}
 
 		cfg = &app.Config{
-			Definition: apiConfig.Definition,
+			Definition: command.DecodeDefinition(ctx, "existing app config", apiConfig.Definition),
 		}
 	}
 
@@ -189,18 +352,23 @@ func determineAppConfig(ctx context.Context) (cfg *app.Config, err error) {
 	return
 }
 
+// newResolver returns the resolver images of the app ctx carries are built or
+// resolved with.
+func newResolver(ctx context.Context) *imgsrc.Resolver {
+	daemonType := imgsrc.NewDockerDaemonType(!flag.GetRemoteOnly(ctx), !flag.GetLocalOnly(ctx))
+	client := client.FromContext(ctx).API()
+	io := iostreams.FromContext(ctx)
+
+	return imgsrc.NewResolver(daemonType, client, app.NameFromContext(ctx), io)
+}
+
 // determineImage picks the deployment strategy, builds the image and returns a
 // DeploymentImage struct
-func determineImage(ctx context.Context, appConfig *app.Config) (img *imgsrc.DeploymentImage, err error) {
+func determineImage(ctx context.Context, resolver *imgsrc.Resolver, appConfig *app.Config) (img *imgsrc.DeploymentImage, err error) {
 	tb := render.NewTextBlock(ctx, "Building image")
 
-	daemonType := imgsrc.NewDockerDaemonType(!flag.GetRemoteOnly(ctx), !flag.GetLocalOnly(ctx))
-	appName := app.NameFromContext(ctx)
-	client := client.FromContext(ctx).API()
 	io := iostreams.FromContext(ctx)
 
-	resolver := imgsrc.NewResolver(daemonType, client, appName, io)
-
 	var imageRef string
 	if imageRef, err = fetchImageRef(ctx, appConfig); err != nil {
 		return
@@ -208,12 +376,18 @@ func determineImage(ctx context.Context, appConfig *app.Config) (img *imgsrc.Dep
 
 	// we're using a pre-built Docker image
 	if imageRef != "" {
+		if out := imageOutputFromContext(ctx); out != nil {
+			err = fmt.Errorf("--output %s only applies to images deploy builds", out)
+
+			return
+		}
+
 		opts := imgsrc.RefOptions{
 			AppName:    app.NameFromContext(ctx),
 			WorkingDir: state.WorkingDirectory(ctx),
 			Publish:    !flag.GetBuildOnly(ctx),
 			ImageRef:   imageRef,
-			ImageLabel: flag.GetString(ctx, "image-label"),
+			ImageLabel: imageLabel(ctx),
 		}
 
 		img, err = resolver.ResolveReference(ctx, io, opts)
@@ -231,12 +405,23 @@ func determineImage(ctx context.Context, appConfig *app.Config) (img *imgsrc.Dep
 		AppName:         app.NameFromContext(ctx),
 		WorkingDir:      state.WorkingDirectory(ctx),
 		Publish:         flag.GetBool(ctx, "push") || (!flag.GetBuildOnly(ctx) && !flag.GetBool(ctx, "nix")),
-		ImageLabel:      flag.GetString(ctx, "image-label"),
+		ImageLabel:      imageLabel(ctx),
 		NoCache:         flag.GetBool(ctx, "no-cache"),
 		BuiltIn:         build.Builtin,
 		BuiltInSettings: build.Settings,
+		BuiltinSource:   appConfig.BuiltinSource(),
 		Builder:         build.Builder,
 		Buildpacks:      build.Buildpacks,
+		Strategy:        build.Strategy,
+		Labels:          sourceLabels(ctx),
+		Output:          imageOutputFromContext(ctx),
+		Platform:        flag.GetString(ctx, "platform"),
+	}
+
+	if opts.Platform != "" {
+		if _, err = imgsrc.ParsePlatform(opts.Platform); err != nil {
+			return
+		}
 	}
 
 	if flag.GetBool(ctx, "nix") {
@@ -254,6 +439,14 @@ func determineImage(ctx context.Context, appConfig *app.Config) (img *imgsrc.Dep
 		opts.Tag = dockerTag
 		build.Args["TAG"] = dockerTag
 
+		if opts.Labels == nil {
+			opts.Labels = make(map[string]string)
+		}
+		opts.Labels[imgsrc.LabelStrategy] = "nix"
+
+		// Nix builds run off a Dockerfile
+		opts.Strategy = "dockerfile"
+
 		// Temporary Dockerfile for running the Nix deployment
 		dockerfileContents := `# syntax=docker/dockerfile:1.4
 		FROM flyio/nix-build
@@ -280,6 +473,10 @@ func determineImage(ctx context.Context, appConfig *app.Config) (img *imgsrc.Dep
 
 	opts.BuildArgs = buildArgs
 
+	if err = parseBuildFlags(ctx, &opts); err != nil {
+		return
+	}
+
 	if opts.DockerfilePath, err = resolveDockerfilePath(ctx, appConfig); err != nil {
 		return
 	}
@@ -299,6 +496,15 @@ func determineImage(ctx context.Context, appConfig *app.Config) (img *imgsrc.Dep
 		tb.Printf("image: %s\n", img.Tag)
 		tb.Printf("image size: %s\n", humanize.Bytes(uint64(img.Size)))
 
+		if p := imgsrc.ProvenanceFromLabels(img.Labels); p != nil {
+			if p.Revision != "" {
+				tb.Printf("image revision: %s\n", p.ShortRevision())
+			}
+			if p.Duration > 0 {
+				tb.Printf("image build duration: %s\n", p.Duration)
+			}
+		}
+
 		// We can't easily get the resulting image ID, but we know the tag and expect it to be pushed, so we can use that
 		// reference for the final deployment
 		if flag.GetBool(ctx, "nix") {
@@ -328,6 +534,39 @@ func resolveDockerfilePath(ctx context.Context, appConfig *app.Config) (path str
 	return
 }
 
+// parseBuildFlags sets the caches and secrets of opts as the respective flags
+// specify.
+func parseBuildFlags(ctx context.Context, opts *imgsrc.ImageOptions) error {
+	for _, spec := range flag.GetStringArray(ctx, "cache-from") {
+		c, err := imgsrc.ParseCache(spec)
+		if err != nil {
+			return err
+		}
+
+		opts.CacheFrom = append(opts.CacheFrom, c)
+	}
+
+	if spec := flag.GetString(ctx, "cache-to"); spec != "" {
+		c, err := imgsrc.ParseCache(spec)
+		if err != nil {
+			return err
+		}
+
+		opts.CacheTo = c
+	}
+
+	for _, spec := range flag.GetStringArray(ctx, "build-secret") {
+		s, err := imgsrc.ParseBuildSecret(spec)
+		if err != nil {
+			return err
+		}
+
+		opts.Secrets = append(opts.Secrets, s)
+	}
+
+	return nil
+}
+
 func mergeBuildArgs(ctx context.Context, args map[string]string) (map[string]string, error) {
 
 	if args == nil {
@@ -373,8 +612,8 @@ func createRelease(ctx context.Context, appConfig *app.Config, img *imgsrc.Deplo
 		input.Strategy = api.StringPointer(strings.ToUpper(val))
 	}
 
-	if len(appConfig.Definition) > 0 {
-		input.Definition = api.DefinitionPtr(appConfig.Definition)
+	if appConfig.HasDefinition() {
+		input.Definition = api.DefinitionPtr(appConfig.Definition.Map())
 	}
 
 	// Start deployment of the determined image
@@ -382,7 +621,24 @@ func createRelease(ctx context.Context, appConfig *app.Config, img *imgsrc.Deplo
 
 	release, releaseCommand, err := client.DeployImage(ctx, input)
 	if err == nil {
-		tb.Donef("release v%d created\n", release.Version)
+		ev := event.Event{
+			Type:          event.ReleaseCreated,
+			App:           input.AppID,
+			Version:       release.Version,
+			Image:         input.Image,
+			BuildDuration: img.Labels[imgsrc.LabelDuration],
+		}
+
+		// DeployImageInput has no field the commit could be recorded in, so
+		// it's reported here and recorded by the tag and labels of the image
+		if c := checkoutFromContext(ctx); c != nil {
+			ev.Revision = c.SHA
+			tb.Donef("release v%d created from commit %s\n", release.Version, shortSHA(c.SHA))
+		} else {
+			tb.Donef("release v%d created\n", release.Version)
+		}
+
+		event.Emit(ctx, ev)
 	}
 
 	return release, releaseCommand, err
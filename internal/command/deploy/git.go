@@ -0,0 +1,297 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/superfly/flyctl/internal/build/imgsrc"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+	"github.com/superfly/flyctl/internal/state"
+)
+
+// gitSource describes the git ref deploy --git deploys, as specified in the
+// form <url>#<ref>:<dir>, where both ref and dir are optional.
+type gitSource struct {
+	// URL is the URL of the repository. Local paths, such as the ones of bare
+	// mirrors, are URLs too.
+	URL string
+	// Ref is the branch, tag or commit to deploy. It defaults to the default
+	// branch of the repository.
+	Ref string
+	// Dir is the directory of the repository, relative to its root, to deploy
+	// from.
+	Dir string
+}
+
+func parseGitSource(spec string) (src gitSource, err error) {
+	src.URL = spec
+	if i := strings.Index(spec, "#"); i >= 0 {
+		src.URL, src.Ref = spec[:i], spec[i+1:]
+	}
+
+	if i := strings.Index(src.Ref, ":"); i >= 0 {
+		src.Ref, src.Dir = src.Ref[:i], src.Ref[i+1:]
+	}
+
+	if src.URL == "" {
+		err = fmt.Errorf("invalid git source %q: expected <url>#<ref>", spec)
+
+		return
+	}
+
+	if src.Dir != "" {
+		if src.Dir = filepath.Clean(src.Dir); filepath.IsAbs(src.Dir) || strings.HasPrefix(src.Dir, "..") {
+			err = fmt.Errorf("invalid git source %q: the directory must be relative to the root of the repository", spec)
+		}
+	}
+
+	return
+}
+
+// checkout is a temporary checkout of a gitSource.
+type checkout struct {
+	gitSource
+
+	// Root is the path to the checkout.
+	Root string
+	// SHA is the commit checked out.
+	SHA string
+}
+
+type checkoutKey struct{}
+
+// checkoutFromContext returns the checkout ctx carries, if any.
+func checkoutFromContext(ctx context.Context) (c *checkout) {
+	if v := ctx.Value(checkoutKey{}); v != nil {
+		c = v.(*checkout)
+	}
+
+	return
+}
+
+// isGitDeploy reports whether the command deploys a git ref.
+func isGitDeploy(ctx context.Context) bool {
+	return flag.GetString(ctx, "git") != ""
+}
+
+// checkoutGitSource checks the git source the git flag specifies out into a
+// temporary directory and makes it the working directory of the process and
+// of the returned context. The returned function restores the previous
+// working directory and removes the checkout.
+func checkoutGitSource(ctx context.Context) (context.Context, func(), error) {
+	src, err := parseGitSource(flag.GetString(ctx, "git"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prev, err := os.Getwd()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed determining working directory: %w", err)
+	}
+
+	tb := render.NewTextBlock(ctx, fmt.Sprintf("Checking out %s", src.URL))
+
+	root, err := os.MkdirTemp("", "flyctl-git-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed creating checkout directory: %w", err)
+	}
+
+	cleanup := func() {
+		_ = os.RemoveAll(root)
+	}
+
+	c, err := fetch(ctx, src, root)
+	if err != nil {
+		cleanup()
+
+		return nil, nil, err
+	}
+
+	wd := filepath.Join(root, src.Dir)
+	if fi, err := os.Stat(wd); err != nil || !fi.IsDir() {
+		cleanup()
+
+		return nil, nil, fmt.Errorf("%s contains no directory %s", src.URL, src.Dir)
+	}
+
+	if err := os.Chdir(wd); err != nil {
+		cleanup()
+
+		return nil, nil, fmt.Errorf("failed changing working directory: %w", err)
+	}
+
+	tb.Donef("checked out %s (%s)", c.SHA, refOrDefault(src.Ref))
+
+	ctx = state.WithWorkingDirectory(ctx, wd)
+	ctx = context.WithValue(ctx, checkoutKey{}, c)
+
+	return ctx, func() {
+		_ = os.Chdir(prev)
+		cleanup()
+	}, nil
+}
+
+// fetch fetches the ref of src from its repository into a new repository at
+// root and checks it out. Refs are fetched shallowly when the remote allows
+// it.
+func fetch(ctx context.Context, src gitSource, root string) (*checkout, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git not found - make sure it's installed and try again: %w", err)
+	}
+
+	ref := refOrDefault(src.Ref)
+
+	if _, err := git(ctx, root, "init", "--quiet"); err != nil {
+		return nil, err
+	}
+
+	if _, err := git(ctx, root, "fetch", "--quiet", "--depth=1", src.URL, ref); err != nil {
+		// servers may refuse to serve commits by their SHA shallowly; fall
+		// back to fetching the full history
+		if _, err := git(ctx, root, "fetch", "--quiet", "--tags", src.URL, "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+			return nil, fmt.Errorf("failed fetching %s: %w", src.URL, err)
+		}
+
+		if _, err := git(ctx, root, "checkout", "--quiet", "--detach", ref); err != nil {
+			return nil, fmt.Errorf("failed checking out %s: %w", ref, err)
+		}
+	} else if _, err := git(ctx, root, "checkout", "--quiet", "--detach", "FETCH_HEAD"); err != nil {
+		return nil, fmt.Errorf("failed checking out %s: %w", ref, err)
+	}
+
+	sha, err := git(ctx, root, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	return &checkout{
+		gitSource: src,
+		Root:      root,
+		SHA:       sha,
+	}, nil
+}
+
+func refOrDefault(ref string) string {
+	if ref == "" {
+		return "HEAD"
+	}
+
+	return ref
+}
+
+// git runs git with the given arguments in dir and returns its trimmed output.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// imageLabel returns the label images are tagged with: the one the image-label
+// flag specifies or, when deploying a git ref, one derived from the commit.
+// An empty label stands for the default one.
+func imageLabel(ctx context.Context) string {
+	if label := flag.GetString(ctx, "image-label"); label != "" {
+		return label
+	}
+
+	if c := checkoutFromContext(ctx); c != nil {
+		return gitTagPrefix + c.SHA
+	}
+
+	return ""
+}
+
+// gitTagPrefix prefixes the commit in the tags of images built from git refs.
+const gitTagPrefix = "git-"
+
+// RevisionFromImage returns the commit the image ref names was built from, in
+// case it was tagged after it when deploying a git ref. Unlike the labels of
+// the image, which record it as well, the tag is part of the release, so it's
+// known without fetching the image.
+func RevisionFromImage(ref string) string {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") {
+		return ""
+	}
+
+	rev := strings.TrimPrefix(ref[i+1:], gitTagPrefix)
+	if len(rev) != 40 || rev == ref[i+1:] || strings.Trim(rev, "0123456789abcdef") != "" {
+		return ""
+	}
+
+	return rev
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+
+	return sha
+}
+
+// sourceLabels returns the labels which record the commit images are built
+// from: the one checked out when deploying a git ref or, in case the working
+// directory is part of a git repository, its HEAD. Local repositories are
+// also marked dirty in case they have uncommitted changes.
+func sourceLabels(ctx context.Context) map[string]string {
+	if c := checkoutFromContext(ctx); c != nil {
+		labels := map[string]string{
+			imgsrc.LabelRevision: c.SHA,
+			imgsrc.LabelDirty:    "false",
+		}
+		if c.Ref != "" && c.Ref != c.SHA {
+			labels[imgsrc.LabelBranch] = c.Ref
+		}
+
+		return labels
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil
+	}
+
+	dir := state.WorkingDirectory(ctx)
+
+	sha, err := git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil // not a repository or one without commits
+	}
+
+	status, err := git(ctx, dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return nil
+	}
+
+	labels := map[string]string{
+		imgsrc.LabelRevision: sha,
+		imgsrc.LabelDirty:    strconv.FormatBool(status != ""),
+	}
+
+	if branch, err := git(ctx, dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil && branch != "HEAD" {
+		labels[imgsrc.LabelBranch] = branch
+	}
+
+	return labels
+}
@@ -0,0 +1,214 @@
+package deploy
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/build/imgsrc"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/state"
+)
+
+func TestParseGitSource(t *testing.T) {
+	cases := []struct {
+		spec string
+		src  gitSource
+		err  bool
+	}{
+		{spec: "https://github.com/org/repo", src: gitSource{URL: "https://github.com/org/repo"}},
+		{spec: "https://github.com/org/repo#main", src: gitSource{URL: "https://github.com/org/repo", Ref: "main"}},
+		{spec: "git@github.com:org/repo.git#v1.2:web/", src: gitSource{URL: "git@github.com:org/repo.git", Ref: "v1.2", Dir: "web"}},
+		{spec: "/srv/mirrors/repo.git#:api", src: gitSource{URL: "/srv/mirrors/repo.git", Dir: "api"}},
+		{spec: "#main", err: true},
+		{spec: "repo#main:../etc", err: true},
+		{spec: "repo#main:/etc", err: true},
+	}
+
+	for _, c := range cases {
+		src, err := parseGitSource(c.spec)
+		if c.err {
+			assert.Error(t, err, c.spec)
+
+			continue
+		}
+
+		require.NoError(t, err, c.spec)
+		assert.Equal(t, c.src, src, c.spec)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	ctx := context.Background()
+
+	// a repository with two commits, mirrored into a bare one
+	work := t.TempDir()
+	run := func(dir string, args ...string) string {
+		t.Helper()
+
+		out, err := git(ctx, dir, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		require.NoError(t, err)
+
+		return out
+	}
+
+	run(work, "init", "--quiet")
+	require.NoError(t, os.WriteFile(filepath.Join(work, "fly.toml"), []byte(`app = "one"`), 0600))
+	run(work, "add", ".")
+	run(work, "commit", "--quiet", "-m", "one")
+	run(work, "tag", "v1")
+	first := run(work, "rev-parse", "HEAD")
+
+	require.NoError(t, os.WriteFile(filepath.Join(work, "fly.toml"), []byte(`app = "two"`), 0600))
+	run(work, "commit", "--quiet", "-am", "two")
+	second := run(work, "rev-parse", "HEAD")
+
+	mirror := filepath.Join(t.TempDir(), "repo.git")
+	run(work, "clone", "--quiet", "--mirror", work, mirror)
+
+	for ref, sha := range map[string]string{"": second, "v1": first, first: first} {
+		root := t.TempDir()
+
+		c, err := fetch(ctx, gitSource{URL: mirror, Ref: ref}, root)
+		require.NoError(t, err, ref)
+		assert.Equal(t, sha, c.SHA, ref)
+		assert.Equal(t, root, c.Root, ref)
+	}
+
+	_, err := fetch(ctx, gitSource{URL: mirror, Ref: "missing"}, t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestSourceLabels(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	ctx := state.WithWorkingDirectory(context.Background(), dir)
+
+	assert.Nil(t, sourceLabels(ctx))
+
+	run := func(args ...string) string {
+		t.Helper()
+
+		out, err := git(ctx, dir, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		require.NoError(t, err)
+
+		return out
+	}
+
+	run("init", "--quiet")
+	run("checkout", "--quiet", "-b", "main")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fly.toml"), []byte(`app = "one"`), 0600))
+	run("add", ".")
+	run("commit", "--quiet", "-m", "one")
+	sha := run("rev-parse", "HEAD")
+
+	assert.Equal(t, map[string]string{
+		imgsrc.LabelRevision: sha,
+		imgsrc.LabelBranch:   "main",
+		imgsrc.LabelDirty:    "false",
+	}, sourceLabels(ctx))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fly.toml"), []byte(`app = "two"`), 0600))
+	assert.Equal(t, "true", sourceLabels(ctx)[imgsrc.LabelDirty])
+
+	ctx = context.WithValue(ctx, checkoutKey{}, &checkout{gitSource: gitSource{Ref: "v1"}, SHA: "abc"})
+	assert.Equal(t, map[string]string{
+		imgsrc.LabelRevision: "abc",
+		imgsrc.LabelBranch:   "v1",
+		imgsrc.LabelDirty:    "false",
+	}, sourceLabels(ctx))
+}
+
+func TestCheckoutGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+
+		out, err := git(context.Background(), repo, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		require.NoError(t, err)
+
+		return out
+	}
+
+	run("init", "--quiet")
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "web"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "web", "fly.toml"), []byte(`app = "web"`), 0600))
+	run("add", ".")
+	run("commit", "--quiet", "-m", "web")
+	sha := run("rev-parse", "HEAD")
+
+	fs := pflag.NewFlagSet("deploy", pflag.ContinueOnError)
+	fs.String("git", "", "")
+	fs.String("image-label", "", "")
+	require.NoError(t, fs.Set("git", repo+"#:web"))
+
+	io, _, _, _ := iostreams.Test()
+	ctx := iostreams.NewContext(flag.NewContext(context.Background(), fs), io)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	ctx, cleanup, err := checkoutGitSource(ctx)
+	require.NoError(t, err)
+
+	c := checkoutFromContext(ctx)
+	require.NotNil(t, c)
+	assert.Equal(t, sha, c.SHA)
+	assert.FileExists(t, filepath.Join(state.WorkingDirectory(ctx), "fly.toml"))
+	assert.Equal(t, "git-"+sha, imageLabel(ctx))
+
+	// temporary directories may be reached through symlinks
+	checkedOut, err := os.Getwd()
+	require.NoError(t, err)
+	want, err := filepath.EvalSymlinks(state.WorkingDirectory(ctx))
+	require.NoError(t, err)
+	checkedOut, err = filepath.EvalSymlinks(checkedOut)
+	require.NoError(t, err)
+	assert.Equal(t, want, checkedOut)
+
+	cleanup()
+
+	restored, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, wd, restored)
+	assert.NoDirExists(t, c.Root)
+}
+
+func TestRevisionFromImage(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+
+	cases := map[string]string{
+		"registry.fly.io/app:git-" + sha:                  sha,
+		"localhost:5000/app:git-" + sha:                   sha,
+		"registry.fly.io/app:deployment-1654084800":       "",
+		"registry.fly.io/app:git-0123456789ab":            "",
+		"registry.fly.io/app:git-" + strings.ToUpper(sha): "",
+		"registry.fly.io/app@sha256:" + sha + sha[:24]:    "",
+		"localhost:5000/app":                              "",
+		"":                                                "",
+	}
+
+	for ref, rev := range cases {
+		assert.Equal(t, rev, RevisionFromImage(ref), ref)
+	}
+}
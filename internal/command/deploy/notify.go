@@ -0,0 +1,53 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/superfly/flyctl/api"
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/event"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/notify"
+)
+
+// withNotifications subscribes a notifier, which posts to the URLs of the
+// notify flag and of the [notify] section of the given config, to the events
+// of the deployment carried out with the returned context. The returned
+// function waits for the notifications to be posted; it must be called once
+// the deployment is done.
+func withNotifications(ctx context.Context, cfg *app.Config) (context.Context, func(), error) {
+	urls := flag.GetStringSlice(ctx, "notify")
+	if cfg != nil {
+		urls = append(urls, cfg.Notify...)
+	}
+
+	n, err := notify.New(urls)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n == nil {
+		return ctx, func() {}, nil
+	}
+
+	io := iostreams.FromContext(ctx)
+
+	return event.Subscribe(ctx, n.Handle), func() {
+		if err := n.Close(); err != nil {
+			fmt.Fprintf(io.ErrOut, "%s %v\n", io.ColorScheme().WarningIcon(), err)
+		}
+	}, nil
+}
+
+// emitDetached emits the start of the deployment of release on behalf of
+// detached deploys, which don't watch it, so that the notifications of the
+// deployment aren't left without the one it started with.
+func emitDetached(ctx context.Context, release *api.Release) {
+	event.Emit(ctx, event.Event{
+		Type:    event.DeploymentStarted,
+		App:     app.NameFromContext(ctx),
+		Version: release.Version,
+	})
+}
@@ -0,0 +1,57 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/api"
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/flag"
+)
+
+func TestDetachedDeploysNotifyStart(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []map[string]interface{}
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	fs := pflag.NewFlagSet("deploy", pflag.ContinueOnError)
+	fs.StringSlice("notify", nil, "")
+	require.NoError(t, fs.Set("notify", srv.URL))
+
+	io, _, _, _ := iostreams.Test()
+	ctx := iostreams.NewContext(flag.NewContext(context.Background(), fs), io)
+	ctx = app.WithName(ctx, "app")
+
+	ctx, done, err := withNotifications(ctx, &app.Config{Notify: []string{srv.URL + "/config"}})
+	require.NoError(t, err)
+
+	emitDetached(ctx, &api.Release{Version: 7})
+	done()
+
+	require.Len(t, bodies, 2)
+	for _, body := range bodies {
+		assert.Equal(t, "started", body["kind"])
+		assert.Equal(t, "Deploying app v7", body["text"])
+	}
+}
@@ -0,0 +1,44 @@
+package deploy
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/superfly/flyctl/internal/build/imgsrc"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+)
+
+type imageOutputKey struct{}
+
+// imageOutputFromContext returns the output the images deploy builds are
+// written to, if any.
+func imageOutputFromContext(ctx context.Context) (out *imgsrc.Output) {
+	if v := ctx.Value(imageOutputKey{}); v != nil {
+		out = v.(*imgsrc.Output)
+	}
+
+	return
+}
+
+// prepareOutput is a Preparer which handles the output flag: oci:<dir> and
+// docker-archive:<file> select where built images are written to, relative to
+// the directory flyctl runs in, while everything else selects the format
+// events are streamed in. It runs before the working directory changes.
+func prepareOutput(ctx context.Context) (context.Context, error) {
+	spec := flag.GetOutput(ctx)
+	if !imgsrc.IsOutput(spec) {
+		return command.StreamEvents(ctx)
+	}
+
+	out, err := imgsrc.ParseOutput(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if out.Path, err = filepath.Abs(out.Path); err != nil {
+		return nil, err
+	}
+
+	return context.WithValue(ctx, imageOutputKey{}, out), nil
+}
@@ -0,0 +1,78 @@
+package deploy
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/build/imgsrc"
+	"github.com/superfly/flyctl/internal/event"
+	"github.com/superfly/flyctl/internal/flag"
+)
+
+func TestPrepareOutput(t *testing.T) {
+	cases := map[string]struct {
+		output string
+		image  *imgsrc.Output
+		events bool
+		err    string
+	}{
+		"none": {},
+		"events": {
+			output: "jsonl",
+			events: true,
+		},
+		"oci layout": {
+			output: "oci:image",
+			image:  &imgsrc.Output{Type: imgsrc.OutputOCI, Path: "image"},
+		},
+		"docker archive": {
+			output: "docker-archive:image.tar",
+			image:  &imgsrc.Output{Type: imgsrc.OutputDockerArchive, Path: "image.tar"},
+		},
+		"unsupported": {
+			output: "yaml",
+			err:    `unsupported output format "yaml": expected jsonl`,
+		},
+		"image without path": {
+			output: "oci:",
+			err:    "invalid image output",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := pflag.NewFlagSet("deploy", pflag.ContinueOnError)
+			fs.String("output", "", "")
+			if c.output != "" {
+				require.NoError(t, fs.Set("output", c.output))
+			}
+
+			io, _, _, _ := iostreams.Test()
+			ctx := iostreams.NewContext(flag.NewContext(context.Background(), fs), io)
+
+			ctx, err := prepareOutput(ctx)
+			if c.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), c.err)
+
+				return
+			}
+			require.NoError(t, err)
+
+			if c.image != nil {
+				abs, err := filepath.Abs(c.image.Path)
+				require.NoError(t, err)
+				c.image.Path = abs
+			}
+			assert.Equal(t, c.image, imageOutputFromContext(ctx))
+			assert.Equal(t, c.events, event.FromContext(ctx) != nil)
+		})
+	}
+}
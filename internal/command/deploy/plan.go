@@ -0,0 +1,316 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/superfly/flyctl/api"
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/build/imgsrc"
+	"github.com/superfly/flyctl/internal/client"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/render"
+)
+
+// plan describes what a deployment would do. It's what --dry-run prints.
+type plan struct {
+	App            string       `json:"app"`
+	CurrentVersion int          `json:"current_version,omitempty"`
+	Image          imagePlan    `json:"image"`
+	Strategy       string       `json:"strategy"`
+	StrategySource string       `json:"strategy_source"`
+	Env            []app.Change `json:"env"`
+	Services       []app.Change `json:"services"`
+	Other          []app.Change `json:"other"`
+	Secrets        []string     `json:"secrets"`
+	ShadowedEnv    []string     `json:"shadowed_env"`
+	Regions        []string     `json:"regions"`
+	BackupRegions  []string     `json:"backup_regions"`
+}
+
+// imagePlan describes the image a deployment would build or reference.
+type imagePlan struct {
+	// Ref is the image the deployment would reference, or the tag the image it
+	// would build would be pushed as.
+	Ref string `json:"ref"`
+	// Build is set when the image would be built from source.
+	Build bool `json:"build"`
+	// Source describes what the image would be built from.
+	Source string `json:"source,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// runDryRun prints the plan for deploying the given config, without building
+// or pushing images and without creating a release.
+func runDryRun(ctx context.Context, appConfig *app.Config) error {
+	p, err := makePlan(ctx, appConfig)
+	if err != nil {
+		return err
+	}
+
+	out := iostreams.FromContext(ctx).Out
+	if config.FromContext(ctx).JSONOutput {
+		return render.JSON(out, p)
+	}
+
+	printPlan(iostreams.FromContext(ctx), p)
+
+	return nil
+}
+
+func makePlan(ctx context.Context, appConfig *app.Config) (*plan, error) {
+	appName := app.NameFromContext(ctx)
+	apiClient := client.FromContext(ctx).API()
+
+	current, err := apiClient.GetApp(ctx, appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving app %s: %w", appName, err)
+	}
+
+	p := &plan{
+		App: appName,
+	}
+	if current.CurrentRelease != nil {
+		p.CurrentVersion = current.CurrentRelease.Version
+	}
+
+	if p.Image, err = planImage(ctx, appConfig); err != nil {
+		return nil, err
+	}
+
+	p.Strategy, p.StrategySource = planStrategy(ctx, appConfig)
+
+	remote, err := apiClient.GetConfig(ctx, appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving config of %s: %w", appName, err)
+	}
+
+	deployed := command.DecodeDefinition(ctx, "config of "+appName, remote.Definition)
+
+	p.Env, p.Services, p.Other = groupChanges(app.Diff(&deployed, &appConfig.Definition))
+
+	secrets, err := apiClient.GetAppSecrets(ctx, appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving secrets of %s: %w", appName, err)
+	}
+	p.Secrets, p.ShadowedEnv = planSecrets(secrets, appConfig.Definition.Env)
+
+	regions, backupRegions, err := apiClient.ListAppRegions(ctx, appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving regions of %s: %w", appName, err)
+	}
+	p.Regions, p.BackupRegions = regionCodes(regions), regionCodes(backupRegions)
+
+	return p, nil
+}
+
+// planImage determines the image determineImage would build or reference.
+func planImage(ctx context.Context, appConfig *app.Config) (img imagePlan, err error) {
+	var imageRef string
+	if imageRef, err = fetchImageRef(ctx, appConfig); err != nil {
+		return
+	}
+
+	if imageRef != "" {
+		img.Ref = imageRef
+
+		// resolving the reference remotely tells whether it exists without
+		// pulling or pushing anything
+		apiClient := client.FromContext(ctx).API()
+		if resolved, err := apiClient.ResolveImageForApp(ctx, app.NameFromContext(ctx), imageRef); err == nil && resolved != nil {
+			img.Ref, img.Digest = resolved.Ref, resolved.Digest
+		}
+
+		return
+	}
+
+	img.Build = true
+	img.Ref = imgsrc.NewDeploymentTag(app.NameFromContext(ctx), imageLabel(ctx))
+
+	build := appConfig.Build
+	if build == nil {
+		build = new(app.Build)
+	}
+
+	switch {
+	case flag.GetBool(ctx, "nix"):
+		img.Source = "Nix"
+	case build.Strategy != "":
+		if imgsrc.Builder(build.Strategy) == nil {
+			err = fmt.Errorf("unknown build strategy %q; available strategies are %s", build.Strategy, strings.Join(imgsrc.BuilderNames(), ", "))
+
+			return
+		}
+
+		img.Source = fmt.Sprintf("%s build strategy", build.Strategy)
+	case build.Builtin != "":
+		img.Source = fmt.Sprintf("builtin %s", build.Builtin)
+	case build.Builder != "":
+		img.Source = fmt.Sprintf("buildpacks builder %s", build.Builder)
+	default:
+		var path string
+		if path, err = resolveDockerfilePath(ctx, appConfig); err != nil {
+			return
+		}
+		if path == "" {
+			path = "Dockerfile in the working directory"
+		}
+
+		img.Source = path
+	}
+
+	return
+}
+
+// planStrategy returns the strategy createRelease would request, along with
+// where it was picked up from. Releases which request none are deployed with
+// the one the platform picks.
+func planStrategy(ctx context.Context, appConfig *app.Config) (strategy, source string) {
+	switch {
+	case flag.GetString(ctx, "strategy") != "":
+		return strings.ToLower(flag.GetString(ctx, "strategy")), "--strategy flag"
+	case appConfig.Definition.Deploy != nil && appConfig.Definition.Deploy.Strategy != "":
+		return strings.ToLower(appConfig.Definition.Deploy.Strategy), "app config"
+	default:
+		return "server default", "canary or rolling, as the platform decides"
+	}
+}
+
+// groupChanges splits changes into the ones to env vars, the ones to services
+// and the rest.
+func groupChanges(changes []app.Change) (env, services, other []app.Change) {
+	env, services, other = []app.Change{}, []app.Change{}, []app.Change{}
+
+	for _, c := range changes {
+		switch {
+		case strings.HasPrefix(c.Field, "env.") || c.Field == "env":
+			env = append(env, c)
+		case strings.HasPrefix(c.Field, "services[") || c.Field == "services":
+			services = append(services, c)
+		default:
+			other = append(other, c)
+		}
+	}
+
+	return
+}
+
+// planSecrets returns the names of the app's secrets along with the names of
+// the env vars of the config they override at runtime. Deployments don't
+// change secrets.
+func planSecrets(secrets []api.Secret, env map[string]string) (names, shadowed []string) {
+	names, shadowed = []string{}, []string{}
+
+	for _, s := range secrets {
+		names = append(names, s.Name)
+
+		if _, ok := env[s.Name]; ok {
+			shadowed = append(shadowed, s.Name)
+		}
+	}
+
+	sort.Strings(names)
+	sort.Strings(shadowed)
+
+	return
+}
+
+func regionCodes(regions []api.Region) []string {
+	codes := make([]string, 0, len(regions))
+	for _, r := range regions {
+		codes = append(codes, r.Code)
+	}
+
+	return codes
+}
+
+func printPlan(io *iostreams.IOStreams, p *plan) {
+	colorize := io.ColorScheme()
+	out := io.Out
+
+	fmt.Fprintf(out, "Deployment plan for %s (dry run: nothing will be built, pushed or released)\n\n", p.App)
+
+	if p.CurrentVersion > 0 {
+		fmt.Fprintf(out, "Current release: v%d\n", p.CurrentVersion)
+	}
+
+	if p.Image.Build {
+		fmt.Fprintf(out, "Image:           would build %s from %s\n", p.Image.Ref, p.Image.Source)
+	} else {
+		ref := p.Image.Ref
+		if p.Image.Digest != "" {
+			ref = fmt.Sprintf("%s@%s", ref, p.Image.Digest)
+		}
+
+		fmt.Fprintf(out, "Image:           would deploy %s\n", ref)
+	}
+
+	fmt.Fprintf(out, "Strategy:        %s (%s)\n", p.Strategy, p.StrategySource)
+
+	printSection(out, colorize, "Env", p.Env)
+	printSection(out, colorize, "Services", p.Services)
+	printSection(out, colorize, "Other config", p.Other)
+
+	fmt.Fprintln(out)
+	if len(p.Secrets) == 0 {
+		fmt.Fprintln(out, "Secrets: none set")
+	} else {
+		fmt.Fprintf(out, "Secrets: %s (unchanged by deployments)\n", strings.Join(p.Secrets, ", "))
+	}
+	for _, name := range p.ShadowedEnv {
+		fmt.Fprintf(out, "  %s secret %s overrides the env var of the same name\n", colorize.WarningIcon(), name)
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Regions: %s (unchanged)\n", joinOrNone(p.Regions))
+	if len(p.BackupRegions) > 0 {
+		fmt.Fprintf(out, "Backup regions: %s (unchanged)\n", strings.Join(p.BackupRegions, ", "))
+	}
+}
+
+func printSection(out io.Writer, colorize *iostreams.ColorScheme, title string, changes []app.Change) {
+	fmt.Fprintln(out)
+
+	if len(changes) == 0 {
+		fmt.Fprintf(out, "%s: no changes\n", title)
+
+		return
+	}
+
+	fmt.Fprintf(out, "%s:\n", title)
+	PrintChanges(out, colorize, "  ", changes)
+}
+
+// PrintChanges prints the given config changes one per line, prefixed with
+// indent and colored after their kind.
+func PrintChanges(out io.Writer, colorize *iostreams.ColorScheme, indent string, changes []app.Change) {
+	for _, c := range changes {
+		line := indent + c.String()
+
+		switch c.Kind {
+		case app.ChangeAdded:
+			line = colorize.Green(line)
+		case app.ChangeRemoved:
+			line = colorize.Red(line)
+		default:
+			line = colorize.Yellow(line)
+		}
+
+		fmt.Fprintln(out, line)
+	}
+}
+
+func joinOrNone(s []string) string {
+	if len(s) == 0 {
+		return "none"
+	}
+
+	return strings.Join(s, ", ")
+}
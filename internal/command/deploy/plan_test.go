@@ -0,0 +1,173 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/api"
+	"github.com/superfly/flyctl/pkg/iostreams"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/flag"
+)
+
+func TestPlanStrategy(t *testing.T) {
+	cases := map[string]struct {
+		flag     string
+		def      app.Definition
+		strategy string
+		source   string
+	}{
+		"default": {
+			strategy: "server default",
+			source:   "canary or rolling, as the platform decides",
+		},
+		"default with volumes": {
+			def: app.Definition{
+				Mounts: []app.Mount{{Source: "data", Destination: "/data"}},
+			},
+			strategy: "server default",
+			source:   "canary or rolling, as the platform decides",
+		},
+		"app config": {
+			def: app.Definition{
+				Deploy: &app.Deploy{Strategy: "BlueGreen"},
+				Mounts: []app.Mount{{Source: "data", Destination: "/data"}},
+			},
+			strategy: "bluegreen",
+			source:   "app config",
+		},
+		"flag": {
+			flag: "Immediate",
+			def: app.Definition{
+				Deploy: &app.Deploy{Strategy: "bluegreen"},
+			},
+			strategy: "immediate",
+			source:   "--strategy flag",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := pflag.NewFlagSet("deploy", pflag.ContinueOnError)
+			fs.String("strategy", "", "")
+			if c.flag != "" {
+				require.NoError(t, fs.Set("strategy", c.flag))
+			}
+			ctx := flag.NewContext(context.Background(), fs)
+
+			strategy, source := planStrategy(ctx, &app.Config{Definition: c.def})
+			assert.Equal(t, c.strategy, strategy)
+			assert.Equal(t, c.source, source)
+		})
+	}
+}
+
+func TestGroupChanges(t *testing.T) {
+	cases := map[string]struct {
+		changes  []app.Change
+		env      []app.Change
+		services []app.Change
+		other    []app.Change
+	}{
+		"none": {
+			env:      []app.Change{},
+			services: []app.Change{},
+			other:    []app.Change{},
+		},
+		"grouped": {
+			changes: []app.Change{
+				{Kind: app.ChangeAdded, Field: "env.LOG_LEVEL", New: "debug"},
+				{Kind: app.ChangeRemoved, Field: "env", Old: map[string]interface{}{"A": "1"}},
+				{Kind: app.ChangeModified, Field: "services[0].internal_port", Old: 8080, New: 3000},
+				{Kind: app.ChangeAdded, Field: "services", New: []interface{}{}},
+				{Kind: app.ChangeModified, Field: "kill_signal", Old: "SIGINT", New: "SIGTERM"},
+				// only exact env and services fields count
+				{Kind: app.ChangeAdded, Field: "environment", New: "x"},
+				{Kind: app.ChangeAdded, Field: "services_extra", New: "x"},
+			},
+			env: []app.Change{
+				{Kind: app.ChangeAdded, Field: "env.LOG_LEVEL", New: "debug"},
+				{Kind: app.ChangeRemoved, Field: "env", Old: map[string]interface{}{"A": "1"}},
+			},
+			services: []app.Change{
+				{Kind: app.ChangeModified, Field: "services[0].internal_port", Old: 8080, New: 3000},
+				{Kind: app.ChangeAdded, Field: "services", New: []interface{}{}},
+			},
+			other: []app.Change{
+				{Kind: app.ChangeModified, Field: "kill_signal", Old: "SIGINT", New: "SIGTERM"},
+				{Kind: app.ChangeAdded, Field: "environment", New: "x"},
+				{Kind: app.ChangeAdded, Field: "services_extra", New: "x"},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			env, services, other := groupChanges(c.changes)
+			assert.Equal(t, c.env, env)
+			assert.Equal(t, c.services, services)
+			assert.Equal(t, c.other, other)
+		})
+	}
+}
+
+func TestPlanSecrets(t *testing.T) {
+	cases := map[string]struct {
+		secrets  []api.Secret
+		env      map[string]string
+		names    []string
+		shadowed []string
+	}{
+		"none": {
+			names:    []string{},
+			shadowed: []string{},
+		},
+		"sorted": {
+			secrets:  []api.Secret{{Name: "REDIS_URL"}, {Name: "DATABASE_URL"}},
+			env:      map[string]string{"LOG_LEVEL": "info"},
+			names:    []string{"DATABASE_URL", "REDIS_URL"},
+			shadowed: []string{},
+		},
+		"shadowing env vars": {
+			secrets:  []api.Secret{{Name: "LOG_LEVEL"}, {Name: "DATABASE_URL"}, {Name: "API_KEY"}},
+			env:      map[string]string{"LOG_LEVEL": "info", "API_KEY": ""},
+			names:    []string{"API_KEY", "DATABASE_URL", "LOG_LEVEL"},
+			shadowed: []string{"API_KEY", "LOG_LEVEL"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			names, shadowed := planSecrets(c.secrets, c.env)
+			assert.Equal(t, c.names, names)
+			assert.Equal(t, c.shadowed, shadowed)
+		})
+	}
+}
+
+func TestPrintChanges(t *testing.T) {
+	changes := []app.Change{
+		{Kind: app.ChangeAdded, Field: "env.LOG_LEVEL", New: "debug"},
+		{Kind: app.ChangeRemoved, Field: "kill_timeout", Old: 5},
+		{Kind: app.ChangeModified, Field: "kill_signal", Old: "SIGINT", New: "SIGTERM"},
+	}
+
+	var buf bytes.Buffer
+	PrintChanges(&buf, iostreams.NewColorScheme(false, false), "  ", changes)
+
+	var want string
+	for _, c := range changes {
+		want += "  " + c.String() + "\n"
+	}
+	assert.Equal(t, want, buf.String())
+
+	buf.Reset()
+	PrintChanges(&buf, iostreams.NewColorScheme(true, false), "", changes[:1])
+	assert.Equal(t, iostreams.NewColorScheme(true, false).Green(changes[0].String())+"\n", buf.String())
+}
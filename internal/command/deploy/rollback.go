@@ -0,0 +1,169 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/superfly/flyctl/api"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/client"
+	"github.com/superfly/flyctl/internal/event"
+	"github.com/superfly/flyctl/internal/render"
+)
+
+// releaseFailure is the error MonitorRelease returns when the release command
+// or the deployment of a release fails.
+type releaseFailure struct {
+	Release *api.Release
+	Cause   error
+}
+
+func (f *releaseFailure) Error() string {
+	return f.Cause.Error()
+}
+
+func (f *releaseFailure) Unwrap() error {
+	return f.Cause
+}
+
+// releasesToSearch is the number of past releases rollback searches for a
+// successful one.
+const releasesToSearch = 25
+
+// rollback redeploys the image and definition of the last successful release
+// preceding the failed one. It always returns an error, since the deployment
+// the user asked for didn't happen, which reports both the cause of the
+// failure and the outcome of the rollback.
+func rollback(ctx context.Context, failure *releaseFailure) error {
+	appName := app.NameFromContext(ctx)
+
+	event.Emit(ctx, event.Event{
+		Type:    event.RollbackStarted,
+		App:     appName,
+		Version: failure.Release.Version,
+		Message: failure.Cause.Error(),
+	})
+
+	release, previous, err := redeployPrevious(ctx, failure)
+	if err != nil {
+		event.Emit(ctx, event.Event{
+			Type:    event.RollbackFailed,
+			App:     appName,
+			Version: failure.Release.Version,
+			Message: err.Error(),
+		})
+
+		return fmt.Errorf("%w; %v", failure, err)
+	}
+
+	event.Emit(ctx, event.Event{
+		Type:    event.RollbackSucceeded,
+		App:     appName,
+		Version: release.Version,
+		Image:   previous.ImageRef,
+		Message: fmt.Sprintf("redeployed v%d", previous.Version),
+	})
+
+	return fmt.Errorf("%w; rolled back to v%d as v%d", failure, previous.Version, release.Version)
+}
+
+// redeployPrevious redeploys the last successful release preceding the failed
+// one and returns the release it created along with the one it redeployed.
+func redeployPrevious(ctx context.Context, failure *releaseFailure) (release, previous *api.Release, err error) {
+	appName := app.NameFromContext(ctx)
+	apiClient := client.FromContext(ctx).API()
+
+	tb := render.NewTextBlock(ctx, fmt.Sprintf("Rolling back: %s", failure.Cause))
+
+	releases, err := apiClient.GetAppReleases(ctx, appName, releasesToSearch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rollback failed: failed retrieving releases: %v", err)
+	}
+
+	if previous = lastSuccessfulRelease(releases, failure.Release.Version); previous == nil {
+		return nil, nil, errors.New("no successful release to roll back to")
+	}
+
+	cfg, err := apiClient.GetAppReleaseConfig(ctx, appName, previous.Version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rollback failed: failed retrieving config of v%d: %v", previous.Version, err)
+	}
+
+	tb.Detailf("redeploying %s from v%d", previous.ImageRef, previous.Version)
+
+	release, releaseCommand, err := Redeploy(ctx, previous, cfg, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("rollback failed: %v", err)
+	}
+
+	tb.Donef("release v%d created from v%d\n", release.Version, previous.Version)
+
+	if err = MonitorRelease(ctx, release, releaseCommand); err != nil {
+		var rollbackFailure *releaseFailure
+		if errors.As(err, &rollbackFailure) {
+			err = rollbackFailure.Cause
+		}
+
+		return nil, nil, fmt.Errorf("rollback to v%d failed: %v", previous.Version, err)
+	}
+
+	return release, previous, nil
+}
+
+// Redeploy creates a release of the image and the config, which cfg holds,
+// the given past release was deployed with, using strategy, unless it's empty.
+func Redeploy(ctx context.Context, past *api.Release, cfg *api.AppConfig, strategy string) (*api.Release, *api.ReleaseCommand, error) {
+	appName := app.NameFromContext(ctx)
+	input := redeployInput(appName, past, cfg, strategy)
+
+	release, releaseCommand, err := client.FromContext(ctx).API().DeployImage(ctx, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	event.Emit(ctx, event.Event{
+		Type:    event.ReleaseCreated,
+		App:     appName,
+		Version: release.Version,
+		Image:   input.Image,
+		Message: fmt.Sprintf("rollback to v%d", past.Version),
+	})
+
+	return release, releaseCommand, nil
+}
+
+func redeployInput(appName string, past *api.Release, cfg *api.AppConfig, strategy string) api.DeployImageInput {
+	input := api.DeployImageInput{
+		AppID: appName,
+		Image: past.ImageRef,
+	}
+	if cfg != nil && cfg.Definition != nil {
+		input.Definition = api.DefinitionPtr(cfg.Definition)
+	}
+	if strategy != "" {
+		input.Strategy = api.StringPointer(strings.ToUpper(strategy))
+	}
+
+	return input
+}
+
+// lastSuccessfulRelease returns the most recent of the given releases which
+// precedes version, deployed successfully and references an image.
+func lastSuccessfulRelease(releases []api.Release, version int) (last *api.Release) {
+	for i := range releases {
+		r := &releases[i]
+
+		if r.Version >= version || !r.Stable || r.ImageRef == "" {
+			continue
+		}
+
+		if last == nil || r.Version > last.Version {
+			last = r
+		}
+	}
+
+	return
+}
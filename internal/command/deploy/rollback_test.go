@@ -0,0 +1,117 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/superfly/flyctl/api"
+)
+
+func TestLastSuccessfulRelease(t *testing.T) {
+	cases := map[string]struct {
+		releases []api.Release
+		version  int
+		want     int // 0 for none
+	}{
+		"no releases": {
+			version: 1,
+		},
+		"no earlier successful release": {
+			releases: []api.Release{
+				{Version: 3, Stable: false, ImageRef: "img:3"},
+				{Version: 2, Stable: false, ImageRef: "img:2"},
+				{Version: 1, Stable: true},
+			},
+			version: 3,
+		},
+		"failed releases in between": {
+			releases: []api.Release{
+				{Version: 5, Stable: false, ImageRef: "img:5"},
+				{Version: 4, Stable: false, ImageRef: "img:4"},
+				{Version: 3, Stable: false, ImageRef: "img:3"},
+				{Version: 2, Stable: true, ImageRef: "img:2"},
+				{Version: 1, Stable: true, ImageRef: "img:1"},
+			},
+			version: 5,
+			want:    2,
+		},
+		"unordered": {
+			releases: []api.Release{
+				{Version: 1, Stable: true, ImageRef: "img:1"},
+				{Version: 3, Stable: true, ImageRef: "img:3"},
+				{Version: 2, Stable: true, ImageRef: "img:2"},
+			},
+			version: 4,
+			want:    3,
+		},
+		"the failed release itself is excluded": {
+			releases: []api.Release{
+				{Version: 2, Stable: true, ImageRef: "img:2"},
+				{Version: 1, Stable: true, ImageRef: "img:1"},
+			},
+			version: 2,
+			want:    1,
+		},
+		"later releases are excluded": {
+			releases: []api.Release{
+				{Version: 4, Stable: true, ImageRef: "img:4"},
+				{Version: 3, Stable: false, ImageRef: "img:3"},
+				{Version: 2, Stable: true, ImageRef: "img:2"},
+			},
+			version: 3,
+			want:    2,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := lastSuccessfulRelease(c.releases, c.version)
+
+			if c.want == 0 {
+				assert.Nil(t, got)
+
+				return
+			}
+
+			if assert.NotNil(t, got) {
+				assert.Equal(t, c.want, got.Version)
+			}
+		})
+	}
+}
+
+func TestRedeployInput(t *testing.T) {
+	past := &api.Release{Version: 3, ImageRef: "registry.fly.io/app:deployment-3"}
+	definition := api.Definition{"kill_signal": "SIGTERM"}
+
+	cases := map[string]struct {
+		cfg      *api.AppConfig
+		strategy string
+		want     api.DeployImageInput
+	}{
+		"without config": {
+			want: api.DeployImageInput{AppID: "app", Image: past.ImageRef},
+		},
+		"without definition": {
+			cfg:  &api.AppConfig{},
+			want: api.DeployImageInput{AppID: "app", Image: past.ImageRef},
+		},
+		"with definition and strategy": {
+			cfg:      &api.AppConfig{Definition: definition},
+			strategy: "bluegreen",
+			want: api.DeployImageInput{
+				AppID:      "app",
+				Image:      past.ImageRef,
+				Definition: api.DefinitionPtr(definition),
+				Strategy:   api.StringPointer("BLUEGREEN"),
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, c.want, redeployInput("app", past, c.cfg, c.strategy))
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/command"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/logger"
+	"github.com/superfly/flyctl/internal/state"
+)
+
+// WorkspaceFileName denotes the name of the manifest which lists the apps of
+// a workspace, that is a repository which contains several apps.
+const WorkspaceFileName = "fly.workspace.toml"
+
+// defaultConcurrency is the number of images deploy --all builds at once
+// unless told otherwise.
+const defaultConcurrency = 4
+
+// workspace wraps the contents of a workspace manifest.
+type workspace struct {
+	// Concurrency is the number of images to build at once.
+	Concurrency int            `toml:"concurrency"`
+	Apps        []workspaceApp `toml:"apps"`
+}
+
+// workspaceApp wraps an [[apps]] section of a workspace manifest.
+type workspaceApp struct {
+	// Path is the path to the directory of the app, relative to the manifest.
+	Path string `toml:"path"`
+	// DependsOn lists the apps, by name or by path, which must deploy
+	// successfully before this one is deployed.
+	DependsOn []string `toml:"depends_on"`
+}
+
+// member is an app of a workspace along with its loaded config.
+type member struct {
+	workspaceApp
+
+	dir    string
+	config *app.Config
+	deps   []*member
+
+	// err is why the config of the member failed to load, if it did.
+	err error
+	// excluded is why the member isn't part of the selected environment, if
+	// it isn't.
+	excluded string
+}
+
+// name returns the name of the app of m or, in case its config failed to
+// load, its path.
+func (m *member) name() string {
+	if m.config == nil {
+		return m.Path
+	}
+
+	return m.config.AppName
+}
+
+// isWorkspaceDeploy reports whether the command should deploy every app of the
+// workspace rather than a single app. That's the case when --all is set, or
+// when the working directory contains a workspace manifest but no app config
+// and no app has been named.
+func isWorkspaceDeploy(ctx context.Context) bool {
+	if flag.GetBool(ctx, "all") {
+		return true
+	}
+
+	if flag.GetApp(ctx) != "" || flag.GetAppConfigFilePath(ctx) != "" {
+		return false
+	}
+
+	root := state.WorkingDirectory(ctx)
+	if !fileExists(filepath.Join(root, WorkspaceFileName)) {
+		return false
+	}
+
+	return configPathIn(root) == ""
+}
+
+// requireAppNameUnlessWorkspace is a Preparer which embeds
+// command.RequireAppName for deployments of a single app. Deployments of git
+// refs require it once the ref has been checked out instead.
+func requireAppNameUnlessWorkspace(ctx context.Context) (context.Context, error) {
+	if isGitDeploy(ctx) || isWorkspaceDeploy(ctx) {
+		return ctx, nil
+	}
+
+	return command.RequireAppName(ctx)
+}
+
+// loadWorkspace reads the workspace manifest found in root. In case there's
+// none, the workspace consists of every directory under root which contains
+// an app config.
+func loadWorkspace(root string) (ws *workspace, err error) {
+	ws = new(workspace)
+
+	path := filepath.Join(root, WorkspaceFileName)
+	if !fileExists(path) {
+		ws.Apps, err = discoverApps(root)
+
+		return
+	}
+
+	if _, err = toml.DecodeFile(path, ws); err != nil {
+		err = fmt.Errorf("failed parsing %s: %w", path, err)
+
+		return
+	}
+
+	for i, a := range ws.Apps {
+		if a.Path == "" {
+			err = fmt.Errorf("%s: apps[%d] specifies no path", WorkspaceFileName, i)
+
+			return
+		}
+	}
+
+	return
+}
+
+// discoverApps returns the directories, relative to root, under root which
+// contain an app config. Hidden directories and the ones dependencies are
+// usually vendored into are skipped.
+func discoverApps(root string) (apps []workspaceApp, err error) {
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if path != root {
+			if name := d.Name(); strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" {
+				return filepath.SkipDir
+			}
+		}
+
+		if configPathIn(path) == "" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		apps = append(apps, workspaceApp{Path: rel})
+
+		return nil
+	})
+
+	return
+}
+
+// loadMembers loads the configs of the apps of ws, whose manifest is found in
+// root, and returns them in the order they should be deployed in.
+//
+// As with LoadAppConfigIfPresent, configs with invalid fields or unresolved
+// variable references are loaded nonetheless; they're rejected once their app
+// is deployed. Members whose configs fail to load record why, so that the
+// others are deployed regardless, and so do the ones without an overlay for
+// the selected environment, which aren't deployed.
+func loadMembers(ctx context.Context, root string, ws *workspace) ([]*member, error) {
+	var (
+		logger  = logger.FromContext(ctx)
+		opts    = command.AppConfigLoadOptions(ctx)
+		members = make([]*member, 0, len(ws.Apps))
+	)
+
+	for _, a := range ws.Apps {
+		m := &member{
+			workspaceApp: a,
+			dir:          filepath.Join(root, a.Path),
+		}
+		members = append(members, m)
+
+		path := configPathIn(m.dir)
+		switch {
+		case path == "":
+			m.err = fmt.Errorf("no app config found in %s", m.dir)
+
+			continue
+		case opts.Environment != "" && app.OverlayPath(path, opts.Environment) == "":
+			m.excluded = fmt.Sprintf("no overlay for environment %s", opts.Environment)
+			logger.Warnf("%s has no overlay for environment %q; skipped.", path, opts.Environment)
+
+			continue
+		}
+
+		cfg, err := app.LoadConfigWithOptions(path, opts)
+
+		var verr *app.ValidationError
+		if errors.As(err, &verr) {
+			err = nil
+		}
+
+		switch {
+		case err != nil:
+			m.err = fmt.Errorf("failed loading app config from %s: %w", path, err)
+		case cfg.AppName == "":
+			m.err = fmt.Errorf("%s specifies no app name", path)
+		default:
+			m.config = cfg
+		}
+	}
+
+	return orderMembers(members)
+}
+
+// orderMembers resolves the dependencies of members and orders them so that
+// every member follows the ones it depends on. Members which don't depend on
+// each other keep their relative order.
+func orderMembers(members []*member) ([]*member, error) {
+	byRef := map[string]*member{}
+	for _, m := range members {
+		if other, ok := byRef[m.name()]; ok {
+			return nil, fmt.Errorf("apps %s and %s are both named %s", other.Path, m.Path, m.name())
+		}
+
+		byRef[m.name()] = m
+		byRef[filepath.Clean(m.Path)] = m
+	}
+
+	for _, m := range members {
+		m.deps = nil
+
+		for _, ref := range m.DependsOn {
+			dep, ok := byRef[ref]
+			if !ok {
+				dep, ok = byRef[filepath.Clean(ref)]
+			}
+
+			if !ok {
+				return nil, fmt.Errorf("%s depends on %s, which isn't an app of the workspace", m.name(), ref)
+			}
+
+			m.deps = append(m.deps, dep)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var (
+		marks   = make(map[*member]int, len(members))
+		ordered = make([]*member, 0, len(members))
+		visit   func(*member, []string) error
+	)
+
+	visit = func(m *member, chain []string) error {
+		chain = append(chain, m.name())
+
+		switch marks[m] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(chain, " -> "))
+		}
+
+		marks[m] = visiting
+		for _, dep := range m.deps {
+			if err := visit(dep, chain); err != nil {
+				return err
+			}
+		}
+		marks[m] = visited
+
+		ordered = append(ordered, m)
+
+		return nil
+	}
+
+	for _, m := range members {
+		if err := visit(m, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// configPathIn returns the path to the app config in dir, or an empty string
+// in case there's none.
+func configPathIn(dir string) string {
+	for _, name := range app.ConfigFileNames {
+		if path := filepath.Join(dir, name); fileExists(path) {
+			return path
+		}
+	}
+
+	return ""
+}
+
+func fileExists(path string) bool {
+	fi, err := os.Stat(path)
+
+	return err == nil && !fi.IsDir()
+}
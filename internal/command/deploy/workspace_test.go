@@ -0,0 +1,208 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/flag"
+	"github.com/superfly/flyctl/internal/logger"
+)
+
+func TestDiscoverApps(t *testing.T) {
+	root := t.TempDir()
+
+	for _, path := range []string{
+		"fly.toml",
+		"services/api/fly.toml",
+		"services/web/fly.yaml",
+		"services/web/node_modules/dep/fly.toml",
+		".git/fly.toml",
+		"docs/README.md",
+	} {
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+	}
+
+	apps, err := discoverApps(root)
+	require.NoError(t, err)
+
+	assert.Equal(t, []workspaceApp{
+		{Path: "."},
+		{Path: filepath.Join("services", "api")},
+		{Path: filepath.Join("services", "web")},
+	}, apps)
+}
+
+func TestLoadWorkspaceManifest(t *testing.T) {
+	root := t.TempDir()
+
+	const manifest = `
+concurrency = 2
+
+[[apps]]
+path = "api"
+depends_on = ["db"]
+
+[[apps]]
+path = "db"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(root, WorkspaceFileName), []byte(manifest), 0o644))
+
+	ws, err := loadWorkspace(root)
+	require.NoError(t, err)
+
+	assert.Equal(t, &workspace{
+		Concurrency: 2,
+		Apps: []workspaceApp{
+			{Path: "api", DependsOn: []string{"db"}},
+			{Path: "db"},
+		},
+	}, ws)
+}
+
+func TestLoadMembers(t *testing.T) {
+	t.Setenv("FLY_ENV_FILE", "")
+	t.Setenv("FLY_ENVIRONMENT", "")
+
+	root := t.TempDir()
+	for path, contents := range map[string]string{
+		"api/fly.toml":            "app = \"api\"\n",
+		"api/fly.staging.toml":    "app = \"api-staging\"\n",
+		"web/fly.toml":            "app = \"web\"\nkill_signal = \"${KILL_SIGNAL}\"\n",
+		"web/fly.staging.toml":    "app = \"web-staging\"\n",
+		"docs/fly.toml":           "app = \"docs\"\n",
+		"worker/fly.toml":         "[env]\nQUEUE = \"default\"\n",
+		"worker/fly.staging.toml": "[env]\nQUEUE = \"staging\"\n",
+		"broken/fly.toml":         "app = \n",
+	} {
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	}
+
+	ws := &workspace{
+		Apps: []workspaceApp{
+			{Path: "api"},
+			{Path: "web", DependsOn: []string{"api"}},
+			{Path: "docs"},
+			{Path: "worker"},
+			{Path: "broken"},
+			{Path: "missing"},
+		},
+	}
+
+	load := func(environment string) map[string]*member {
+		t.Helper()
+
+		fs := pflag.NewFlagSet("deploy", pflag.ContinueOnError)
+		fs.String(flag.EnvironmentName, environment, "")
+
+		ctx := flag.NewContext(context.Background(), fs)
+		ctx = logger.NewContext(ctx, logger.FromEnv(io.Discard))
+
+		members, err := loadMembers(ctx, root, ws)
+		require.NoError(t, err)
+		require.Len(t, members, len(ws.Apps))
+
+		byPath := map[string]*member{}
+		for _, m := range members {
+			byPath[m.Path] = m
+		}
+
+		return byPath
+	}
+
+	members := load("")
+
+	// configs with unresolved references load, to be rejected once deployed
+	assert.Equal(t, "web", members["web"].name())
+	assert.NoError(t, members["web"].err)
+	assert.Error(t, members["web"].config.Err())
+
+	assert.EqualError(t, members["worker"].err, filepath.Join(root, "worker", "fly.toml")+" specifies no app name")
+	assert.Contains(t, members["broken"].err.Error(), "failed loading app config from "+filepath.Join(root, "broken", "fly.toml"))
+	assert.EqualError(t, members["missing"].err, "no app config found in "+filepath.Join(root, "missing"))
+	assert.Equal(t, "missing", members["missing"].name())
+
+	members = load("staging")
+
+	assert.Equal(t, "api-staging", members["api"].name())
+	assert.Equal(t, "web-staging", members["web"].name())
+	assert.Equal(t, "no overlay for environment staging", members["docs"].excluded)
+	assert.Nil(t, members["docs"].config)
+}
+
+func newMember(name, path string, dependsOn ...string) *member {
+	return &member{
+		workspaceApp: workspaceApp{Path: path, DependsOn: dependsOn},
+		config:       &app.Config{AppName: name},
+	}
+}
+
+func names(members []*member) (names []string) {
+	for _, m := range members {
+		names = append(names, m.name())
+	}
+
+	return
+}
+
+func TestOrderMembers(t *testing.T) {
+	ordered, err := orderMembers([]*member{
+		newMember("web", "web", "api"),
+		newMember("api", "api", "db", "./queue"),
+		newMember("db", "db"),
+		newMember("queue", "queue"),
+		newMember("docs", "docs"),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"db", "queue", "api", "web", "docs"}, names(ordered))
+}
+
+func TestOrderMembersRejectsCycles(t *testing.T) {
+	_, err := orderMembers([]*member{
+		newMember("a", "a", "b"),
+		newMember("b", "b", "c"),
+		newMember("c", "c", "a"),
+	})
+
+	assert.EqualError(t, err, "dependency cycle: a -> b -> c -> a")
+}
+
+func TestOrderMembersRejectsUnknownDependencies(t *testing.T) {
+	_, err := orderMembers([]*member{
+		newMember("a", "a", "z"),
+	})
+
+	assert.EqualError(t, err, "a depends on z, which isn't an app of the workspace")
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		buf bytes.Buffer
+	)
+
+	a := newPrefixWriter(&mu, &buf, "[a] ")
+	b := newPrefixWriter(&mu, &buf, "[b] ")
+
+	_, _ = a.Write([]byte("one\ntw"))
+	_, _ = b.Write([]byte("three\n"))
+	_, _ = a.Write([]byte("o\nfour"))
+	a.Flush()
+	b.Flush()
+
+	assert.Equal(t, "[a] one\n[b] three\n[a] two\n[a] four\n", buf.String())
+}
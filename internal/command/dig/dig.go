@@ -52,6 +52,7 @@ attached to the current app (you can pass an app in with -a <appname>).`
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Org(),
 		flag.Bool{
 			Name:        "short",
@@ -52,6 +52,7 @@ you might have associated with this application.
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Bool{
 			Name:        "force",
 			Default:     false,
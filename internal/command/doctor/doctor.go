@@ -41,6 +41,7 @@ func New() (cmd *cobra.Command) {
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Bool{
 			Name:        "verbose",
 			Shorthand:   "v",
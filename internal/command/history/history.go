@@ -36,6 +36,7 @@ events and their results.
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 	)
 
 	return
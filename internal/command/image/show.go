@@ -8,7 +8,10 @@ import (
 
 	"github.com/superfly/flyctl/pkg/iostreams"
 
+	"github.com/superfly/flyctl/api"
+	"github.com/superfly/flyctl/cmd/presenters"
 	"github.com/superfly/flyctl/internal/app"
+	"github.com/superfly/flyctl/internal/build/imgsrc"
 	"github.com/superfly/flyctl/internal/client"
 	"github.com/superfly/flyctl/internal/command"
 	"github.com/superfly/flyctl/internal/config"
@@ -34,6 +37,7 @@ func newShow() *cobra.Command {
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 	)
 
 	return cmd
@@ -53,8 +57,13 @@ func runShow(ctx context.Context) error {
 		return fmt.Errorf("failed to get image info: %w", err)
 	}
 
+	provenance := imgsrc.FetchProvenance(ctx, imageRef(app.ImageDetails))[imageRef(app.ImageDetails)]
+
 	if cfg.JSONOutput {
-		return render.JSON(io.Out, app.ImageDetails)
+		return render.JSON(io.Out, struct {
+			api.ImageVersion
+			Provenance *imgsrc.Provenance `json:",omitempty"`
+		}{app.ImageDetails, provenance})
 	}
 
 	if app.ImageVersionTrackingEnabled && app.ImageUpgradeAvailable {
@@ -91,11 +100,61 @@ func runShow(ctx context.Context) error {
 		},
 	}
 
-	return render.VerticalTable(io.Out, "Deployment Status", obj,
+	err = render.VerticalTable(io.Out, "Deployment Status", obj,
 		"Registry",
 		"Repository",
 		"Tag",
 		"Version",
 		"Digest",
 	)
+	if err != nil || provenance == nil {
+		return err
+	}
+
+	var duration string
+	if provenance.Duration > 0 {
+		duration = provenance.Duration.String()
+	}
+
+	var created string
+	if !provenance.Created.IsZero() {
+		created = presenters.FormatRelativeTime(provenance.Created)
+	}
+
+	obj = [][]string{
+		{
+			provenance.Revision,
+			provenance.Branch,
+			fmt.Sprintf("%t", provenance.Dirty),
+			provenance.Strategy,
+			provenance.FlyctlVersion,
+			created,
+			duration,
+		},
+	}
+
+	return render.VerticalTable(io.Out, "Provenance", obj,
+		"Commit",
+		"Branch",
+		"Dirty",
+		"Builder",
+		"Flyctl Version",
+		"Built",
+		"Build Duration",
+	)
+}
+
+// imageRef returns the reference to the given image, pinned to its digest
+// when it's known.
+func imageRef(image api.ImageVersion) string {
+	ref := image.Repository
+	if image.Registry != "" {
+		ref = image.Registry + "/" + ref
+	}
+
+	if image.Digest != "" {
+		return ref + "@" + image.Digest
+	}
+
+	return ref + ":" + image.Tag
 }
@@ -39,6 +39,7 @@ The update will perform a rolling restart against each VM, which may result in a
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Yes(),
 		flag.Bool{
 			Name:        "detach",
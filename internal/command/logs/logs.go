@@ -46,6 +46,7 @@ to all instances running in a specific region using the --region/-r flag.
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Region(),
 		flag.String{
 			Name:        "instance",
@@ -43,6 +43,7 @@ func newClone() *cobra.Command {
 		flag.Org(),
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Region(),
 		flag.String{
 			Name:        "name",
@@ -34,6 +34,7 @@ func newKill() *cobra.Command {
 		cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 	)
 
 	return cmd
@@ -35,6 +35,7 @@ func newList() *cobra.Command {
 		cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Bool{
 			Name:        "quiet",
 			Shorthand:   "q",
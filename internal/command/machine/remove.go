@@ -35,6 +35,7 @@ func newRemove() *cobra.Command {
 		cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Bool{
 			Name:        "force",
 			Shorthand:   "f",
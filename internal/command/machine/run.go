@@ -59,6 +59,7 @@ func newRun() *cobra.Command {
 		cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Region(),
 		flag.String{
 			Name:        "id",
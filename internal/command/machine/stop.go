@@ -37,6 +37,7 @@ func newStop() *cobra.Command {
 		cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.String{
 			Name:        "signal",
 			Shorthand:   "s",
@@ -22,13 +22,16 @@ func New() (cmd *cobra.Command) {
 
 	cmd = command.New("monitor", short, long, run,
 		command.RequireSession,
-		command.RequireAppName)
+		command.RequireAppName,
+		command.StreamEvents)
 
 	cmd.Args = cobra.NoArgs
 
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
+		flag.Output(),
 	)
 
 	return
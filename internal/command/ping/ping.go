@@ -51,6 +51,7 @@ The target argument can be either a ".internal" DNS name in our network
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Org(),
 		flag.String{
 			Name:        "interval",
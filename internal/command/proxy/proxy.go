@@ -28,6 +28,7 @@ func New() *cobra.Command {
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Org(),
 		flag.Bool{
 			Name:        "select",
@@ -14,6 +14,8 @@ import (
 	"github.com/superfly/flyctl/internal/command/apps"
 	"github.com/superfly/flyctl/internal/command/auth"
 	"github.com/superfly/flyctl/internal/command/builds"
+	"github.com/superfly/flyctl/internal/command/builtins"
+	"github.com/superfly/flyctl/internal/command/config"
 	"github.com/superfly/flyctl/internal/command/create"
 	"github.com/superfly/flyctl/internal/command/curl"
 	"github.com/superfly/flyctl/internal/command/deploy"
@@ -126,6 +128,7 @@ func New() *cobra.Command {
 		orgs.New(),
 		auth.New(),
 		builds.New(),
+		builtins.New(),
 		open.New(), // TODO: deprecate
 		curl.New(),
 		platform.New(),
@@ -144,6 +147,7 @@ func New() *cobra.Command {
 		proxy.New(),
 		machine.New(),
 		monitor.New(),
+		config.New(),
 	}
 
 	if os.Getenv("DEV") != "" {
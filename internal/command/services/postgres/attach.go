@@ -37,6 +37,7 @@ func newAttach() (cmd *cobra.Command) {
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		// flag.String{
 		// 	Name:        "postgres-app",
 		// 	Description: "The name of the postgres app we are looking to attach.",
@@ -65,6 +65,7 @@ func newConfigView() (cmd *cobra.Command) {
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 	)
 
 	return
@@ -172,6 +173,7 @@ func newConfigUpdate() (cmd *cobra.Command) {
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.String{
 			Name:        "max-connections",
 			Description: "Sets the maximum number of concurrent connections.",
@@ -32,6 +32,7 @@ func newConnect() (cmd *cobra.Command) {
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.String{
 			Name:        "database",
 			Shorthand:   "d",
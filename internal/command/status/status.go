@@ -22,6 +22,7 @@ import (
 	"github.com/superfly/flyctl/internal/client"
 	"github.com/superfly/flyctl/internal/command"
 	"github.com/superfly/flyctl/internal/config"
+	"github.com/superfly/flyctl/internal/event"
 	"github.com/superfly/flyctl/internal/flag"
 	"github.com/superfly/flyctl/internal/render"
 )
@@ -38,6 +39,7 @@ currently allocated.
 	cmd = command.New("status", short, long, run,
 		command.RequireSession,
 		command.RequireAppName,
+		command.StreamEvents,
 	)
 
 	cmd.Args = cobra.NoArgs
@@ -45,6 +47,7 @@ currently allocated.
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Bool{
 			Name:        "all",
 			Description: "Show completed instances",
@@ -62,6 +65,7 @@ currently allocated.
 			Description: "Refresh Rate for --watch",
 			Default:     5,
 		},
+		flag.Output(),
 	)
 
 	cmd.AddCommand(
@@ -77,11 +81,19 @@ func run(ctx context.Context) error {
 		return errors.New("--watch and --json are not supported together")
 	}
 
-	if !watch {
-		return runOnce(ctx)
+	streamEvents := event.FromContext(ctx) != nil
+	if streamEvents && !watch {
+		return fmt.Errorf("--output %s requires --watch", command.OutputJSONLines)
 	}
 
-	return runWatch(ctx)
+	switch {
+	case !watch:
+		return runOnce(ctx)
+	case streamEvents:
+		return runWatchEvents(ctx)
+	default:
+		return runWatch(ctx)
+	}
 }
 
 func runOnce(ctx context.Context) error {
@@ -181,6 +193,49 @@ func renderDeploymentStatus(w io.Writer, ds *api.DeploymentStatus) error {
 	)
 }
 
+func watchRate(ctx context.Context) (time.Duration, error) {
+	sleep := flag.GetInt(ctx, "rate")
+	if sleep < 1 || sleep > 3600 {
+		return 0, errors.New("--rate must be in the [1, 3600] range")
+	}
+
+	return time.Duration(sleep) * time.Second, nil
+}
+
+// runWatchEvents polls the status of the app and emits an event for each
+// transition of its deployment and of its instances.
+func runWatchEvents(ctx context.Context) error {
+	rate, err := watchRate(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		appName = app.NameFromContext(ctx)
+		client  = client.FromContext(ctx).API()
+		tracker = event.NewTracker(event.FromContext(ctx), appName)
+		all     = flag.GetBool(ctx, "all")
+	)
+
+	for seeded := false; ; seeded = true {
+		status, err := client.GetAppStatus(ctx, appName, all)
+		if err != nil {
+			return fmt.Errorf("failed retrieving app %s: %w", appName, err)
+		}
+
+		if seeded {
+			tracker.Deployment(status.DeploymentStatus)
+			tracker.Allocations(status.Allocations)
+		} else {
+			tracker.Seed(status.DeploymentStatus, status.Allocations)
+		}
+
+		if pause.For(ctx, rate); ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
 func runWatch(ctx context.Context) (err error) {
 	streams := iostreams.FromContext(ctx)
 	if !streams.IsInteractive() {
@@ -190,10 +245,8 @@ func runWatch(ctx context.Context) (err error) {
 	}
 	colorize := streams.ColorScheme()
 
-	sleep := flag.GetInt(ctx, "rate")
-	if sleep < 1 || sleep > 3600 {
-		err = errors.New("--rate must be in the [1, 3600] range")
-
+	var rate time.Duration
+	if rate, err = watchRate(ctx); err != nil {
 		return
 	}
 
@@ -218,7 +271,7 @@ func runWatch(ctx context.Context) (err error) {
 			&buf,
 		))
 
-		pause.For(ctx, time.Duration(sleep)*time.Second)
+		pause.For(ctx, rate)
 	}
 
 	return
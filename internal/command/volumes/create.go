@@ -39,6 +39,7 @@ sets the size as the number of gigabytes the volume will consume.`
 	flag.Add(cmd,
 		flag.App(),
 		flag.AppConfig(),
+		flag.Environment(),
 		flag.Region(),
 		flag.Int{
 			Name:        "size",
@@ -0,0 +1,40 @@
+package event
+
+import (
+	"context"
+	"time"
+)
+
+type contextKey struct{}
+
+// NewContext derives a context that carries emitter from ctx.
+func NewContext(ctx context.Context, emitter *Emitter) context.Context {
+	return context.WithValue(ctx, contextKey{}, emitter)
+}
+
+// FromContext returns the Emitter ctx carries or nil in case ctx carries no
+// Emitter.
+func FromContext(ctx context.Context) (e *Emitter) {
+	if v := ctx.Value(contextKey{}); v != nil {
+		e = v.(*Emitter)
+	}
+
+	return
+}
+
+// Emit emits ev via the Emitter ctx carries, if any.
+func Emit(ctx context.Context, ev Event) {
+	if e := FromContext(ctx); e != nil {
+		e.Emit(ev)
+	}
+}
+
+// Subscribe derives a context from ctx whose Emitter passes the events it's
+// given on to fn, before passing them on to the Emitter ctx carries, if any.
+func Subscribe(ctx context.Context, fn func(Event)) context.Context {
+	return NewContext(ctx, &Emitter{
+		fn:     fn,
+		parent: FromContext(ctx),
+		now:    time.Now,
+	})
+}
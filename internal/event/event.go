@@ -0,0 +1,110 @@
+// Package event implements streams of structured deployment events, which
+// commands emit as JSON lines for pipelines to react to.
+package event
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Type denotes the type of an Event.
+type Type string
+
+const (
+	// ReleaseCreated denotes that a release was created.
+	ReleaseCreated Type = "release_created"
+	// ReleaseCommandSucceeded denotes that the release command of a release
+	// succeeded.
+	ReleaseCommandSucceeded Type = "release_command_succeeded"
+	// ReleaseCommandFailed denotes that the release command of a release
+	// failed.
+	ReleaseCommandFailed Type = "release_command_failed"
+	// DeploymentStarted denotes that the deployment of a release started.
+	DeploymentStarted Type = "deployment_started"
+	// DeploymentSucceeded denotes that the deployment of a release succeeded.
+	DeploymentSucceeded Type = "deployment_succeeded"
+	// DeploymentFailed denotes that the deployment of a release failed.
+	DeploymentFailed Type = "deployment_failed"
+	// AllocationPlaced denotes that an instance was placed.
+	AllocationPlaced Type = "allocation_placed"
+	// AllocationUpdated denotes that the status of an instance changed.
+	AllocationUpdated Type = "allocation_updated"
+	// CheckPassed denotes that a health check of an instance started passing.
+	CheckPassed Type = "check_passed"
+	// CheckWarning denotes that a health check of an instance started warning.
+	CheckWarning Type = "check_warning"
+	// CheckFailed denotes that a health check of an instance started failing.
+	CheckFailed Type = "check_failed"
+	// AllocationFailed denotes that an instance failed the deployment it was
+	// part of. Such events carry the recent logs of the instance.
+	AllocationFailed Type = "allocation_failed"
+	// RollbackStarted denotes that a failed release is being rolled back.
+	RollbackStarted Type = "rollback_started"
+	// RollbackSucceeded denotes that a failed release was rolled back.
+	RollbackSucceeded Type = "rollback_succeeded"
+	// RollbackFailed denotes that rolling a failed release back failed.
+	RollbackFailed Type = "rollback_failed"
+)
+
+// Event describes a single state transition of a release or of its
+// deployment.
+type Event struct {
+	Type          Type      `json:"type"`
+	Time          time.Time `json:"time"`
+	App           string    `json:"app"`
+	Version       int       `json:"version,omitempty"`
+	Deployment    string    `json:"deployment,omitempty"`
+	Allocation    string    `json:"allocation,omitempty"`
+	Region        string    `json:"region,omitempty"`
+	Check         string    `json:"check,omitempty"`
+	Status        string    `json:"status,omitempty"`
+	Image         string    `json:"image,omitempty"`
+	Revision      string    `json:"revision,omitempty"`
+	BuildDuration string    `json:"build_duration,omitempty"`
+	Message       string    `json:"message,omitempty"`
+	Logs          []string  `json:"logs,omitempty"`
+}
+
+// Emitter writes events as JSON lines or passes them on to a subscriber.
+// It's safe for concurrent use.
+type Emitter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	fn     func(Event)
+	parent *Emitter
+	now    func() time.Time
+}
+
+// NewEmitter returns an Emitter which writes to w.
+func NewEmitter(w io.Writer) *Emitter {
+	return &Emitter{
+		enc: json.NewEncoder(w),
+		now: time.Now,
+	}
+}
+
+// Emit writes ev, timestamped with the current time unless it already
+// carries one.
+func (e *Emitter) Emit(ev Event) {
+	e.mu.Lock()
+
+	if ev.Time.IsZero() {
+		ev.Time = e.now().UTC()
+	}
+
+	if e.enc != nil {
+		_ = e.enc.Encode(ev)
+	}
+
+	if e.fn != nil {
+		e.fn(ev)
+	}
+
+	e.mu.Unlock()
+
+	if e.parent != nil {
+		e.parent.Emit(ev)
+	}
+}
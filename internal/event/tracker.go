@@ -0,0 +1,170 @@
+package event
+
+import (
+	"github.com/superfly/flyctl/api"
+)
+
+// Check statuses, as the API reports them.
+const (
+	checkPassing  = "passing"
+	checkWarning  = "warning"
+	checkCritical = "critical"
+)
+
+// Tracker turns successive observations of deployments and of their
+// instances into events for the transitions between them.
+type Tracker struct {
+	app  string
+	emit func(Event)
+
+	deployments map[string]string
+	allocations map[string]*api.AllocationStatus
+}
+
+// NewTracker returns a Tracker which emits the events of the named app via
+// emitter. Trackers of nil emitters track nothing.
+func NewTracker(emitter *Emitter, app string) *Tracker {
+	t := &Tracker{
+		app:         app,
+		emit:        func(Event) {},
+		deployments: map[string]string{},
+		allocations: map[string]*api.AllocationStatus{},
+	}
+
+	if emitter != nil {
+		t.emit = emitter.Emit
+	}
+
+	return t
+}
+
+// Seed records the given observation without emitting events for it, so that
+// only subsequent transitions are reported.
+func (t *Tracker) Seed(d *api.DeploymentStatus, allocs []*api.AllocationStatus) {
+	emit := t.emit
+	defer func() {
+		t.emit = emit
+	}()
+
+	t.emit = func(Event) {}
+	t.Deployment(d)
+	t.Allocations(allocs)
+}
+
+// Deployment observes d, emitting an event when it starts and when it
+// completes.
+func (t *Tracker) Deployment(d *api.DeploymentStatus) {
+	if d == nil {
+		return
+	}
+
+	state := deploymentState(d)
+
+	prev, seen := t.deployments[d.ID]
+	if seen && prev == state {
+		return
+	}
+	t.deployments[d.ID] = state
+
+	ev := Event{
+		App:        t.app,
+		Version:    d.Version,
+		Deployment: d.ID,
+		Status:     d.Status,
+		Message:    d.Description,
+	}
+
+	if !seen {
+		ev.Type = DeploymentStarted
+		t.emit(ev)
+	}
+
+	switch state {
+	case "successful":
+		ev.Type = DeploymentSucceeded
+	case "failed":
+		ev.Type = DeploymentFailed
+	default:
+		return
+	}
+
+	t.emit(ev)
+}
+
+func deploymentState(d *api.DeploymentStatus) string {
+	switch {
+	case d.InProgress:
+		return "running"
+	case d.Successful:
+		return "successful"
+	default:
+		return "failed"
+	}
+}
+
+// Allocations observes allocs, emitting events for the ones which were placed
+// or changed status and for the health checks of theirs which changed status.
+func (t *Tracker) Allocations(allocs []*api.AllocationStatus) {
+	for _, a := range allocs {
+		if a == nil {
+			continue
+		}
+
+		ev := Event{
+			App:        t.app,
+			Version:    a.Version,
+			Allocation: a.IDShort,
+			Region:     a.Region,
+			Status:     a.Status,
+		}
+		if ev.Allocation == "" {
+			ev.Allocation = a.ID
+		}
+
+		prev, seen := t.allocations[a.ID]
+		switch {
+		case !seen:
+			ev.Type = AllocationPlaced
+			t.emit(ev)
+		case prev.Status != a.Status:
+			ev.Type = AllocationUpdated
+			t.emit(ev)
+		}
+
+		for _, c := range a.Checks {
+			if seen && checkStatus(prev, c.Name) == c.Status {
+				continue
+			}
+
+			ev := ev
+			ev.Check = c.Name
+			ev.Status = c.Status
+			ev.Message = c.Output
+
+			switch c.Status {
+			case checkPassing:
+				ev.Type = CheckPassed
+			case checkWarning:
+				ev.Type = CheckWarning
+			case checkCritical:
+				ev.Type = CheckFailed
+			default:
+				continue
+			}
+
+			t.emit(ev)
+		}
+
+		t.allocations[a.ID] = a
+	}
+}
+
+func checkStatus(a *api.AllocationStatus, name string) string {
+	for _, c := range a.Checks {
+		if c.Name == name {
+			return c.Status
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,113 @@
+package event
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/api"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) (events []Event) {
+	t.Helper()
+
+	s := bufio.NewScanner(buf)
+	for s.Scan() {
+		var ev Event
+		require.NoError(t, json.Unmarshal(s.Bytes(), &ev))
+
+		events = append(events, ev)
+	}
+
+	return
+}
+
+func types(events []Event) (types []Type) {
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+
+	return
+}
+
+func TestEmitterTimestamps(t *testing.T) {
+	var buf bytes.Buffer
+
+	e := NewEmitter(&buf)
+	e.now = func() time.Time {
+		return time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	}
+	e.Emit(Event{Type: ReleaseCreated, App: "app", Version: 3})
+
+	assert.Equal(t, `{"type":"release_created","time":"2022-06-01T12:00:00Z","app":"app","version":3}`+"\n", buf.String())
+}
+
+func TestTrackerDeployment(t *testing.T) {
+	var buf bytes.Buffer
+	tracker := NewTracker(NewEmitter(&buf), "app")
+
+	d := &api.DeploymentStatus{ID: "d1", Version: 2, InProgress: true, Status: "running"}
+	tracker.Deployment(d)
+	tracker.Deployment(d)
+
+	alloc := &api.AllocationStatus{ID: "a1", IDShort: "a1", Version: 2, Region: "iad", Status: "pending"}
+	tracker.Allocations([]*api.AllocationStatus{alloc})
+
+	alloc = &api.AllocationStatus{ID: "a1", IDShort: "a1", Version: 2, Region: "iad", Status: "running",
+		Checks: []api.CheckState{{Name: "http", Status: "critical", Output: "timeout"}}}
+	tracker.Allocations([]*api.AllocationStatus{alloc})
+
+	alloc = &api.AllocationStatus{ID: "a1", IDShort: "a1", Version: 2, Region: "iad", Status: "running",
+		Checks: []api.CheckState{{Name: "http", Status: "passing"}}}
+	tracker.Allocations([]*api.AllocationStatus{alloc})
+
+	tracker.Deployment(&api.DeploymentStatus{ID: "d1", Version: 2, Successful: true, Status: "successful"})
+
+	events := decode(t, &buf)
+	assert.Equal(t, []Type{
+		DeploymentStarted,
+		AllocationPlaced,
+		AllocationUpdated,
+		CheckFailed,
+		CheckPassed,
+		DeploymentSucceeded,
+	}, types(events))
+
+	failed := events[3]
+	assert.Equal(t, "a1", failed.Allocation)
+	assert.Equal(t, "iad", failed.Region)
+	assert.Equal(t, "http", failed.Check)
+	assert.Equal(t, "timeout", failed.Message)
+}
+
+func TestTrackerSeed(t *testing.T) {
+	var buf bytes.Buffer
+	tracker := NewTracker(NewEmitter(&buf), "app")
+
+	d := &api.DeploymentStatus{ID: "d1", Version: 2, InProgress: true}
+	allocs := []*api.AllocationStatus{{ID: "a1", Status: "running"}}
+
+	tracker.Seed(d, allocs)
+	tracker.Deployment(d)
+	tracker.Allocations(allocs)
+	assert.Empty(t, buf.String())
+
+	tracker.Deployment(&api.DeploymentStatus{ID: "d1", Version: 2, Description: "failed health checks"})
+
+	events := decode(t, &buf)
+	require.Len(t, events, 1)
+	assert.Equal(t, DeploymentFailed, events[0].Type)
+	assert.Equal(t, "failed health checks", events[0].Message)
+}
+
+func TestTrackerWithoutEmitter(t *testing.T) {
+	tracker := NewTracker(nil, "app")
+
+	tracker.Deployment(&api.DeploymentStatus{ID: "d1", InProgress: true})
+	tracker.Allocations([]*api.AllocationStatus{{ID: "a1"}})
+}
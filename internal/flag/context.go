@@ -34,6 +34,12 @@ func FirstArg(ctx context.Context) string {
 	return ""
 }
 
+// IsSet reports whether the named flag ctx carries has been set on the
+// command line. It panics in case ctx carries no flags.
+func IsSet(ctx context.Context, name string) bool {
+	return FromContext(ctx).Changed(name)
+}
+
 // GetString returns the value of the named string flag ctx carries. It panics
 // in case ctx carries no flags or in case the named flag isn't a string one.
 func GetString(ctx context.Context, name string) string {
@@ -64,6 +70,17 @@ func GetStringSlice(ctx context.Context, name string) []string {
 	}
 }
 
+// GetStringArray returns the value of the named string array flag ctx
+// carries. It panics in case ctx carries no flags or in case the named flag
+// isn't a string array one.
+func GetStringArray(ctx context.Context, name string) []string {
+	if v, err := FromContext(ctx).GetStringArray(name); err != nil {
+		panic(err)
+	} else {
+		return v
+	}
+}
+
 // GetBool returns the value of the named boolean flag ctx carries. It panics
 // in case ctx carries no flags or in case the named flag isn't a boolean one.
 func GetBool(ctx context.Context, name string) bool {
@@ -98,3 +115,8 @@ func GetApp(ctx context.Context) string {
 func GetAppConfigFilePath(ctx context.Context) string {
 	return GetString(ctx, AppConfigFilePathName)
 }
+
+// GetEnvironment is shorthand for GetString(ctx, EnvironmentName).
+func GetEnvironment(ctx context.Context) string {
+	return GetString(ctx, EnvironmentName)
+}
@@ -35,6 +35,9 @@ const (
 	// AppConfigFilePathName denotes the name of the app config file path flag.
 	AppConfigFilePathName = "config"
 
+	// EnvironmentName denotes the name of the environment flag.
+	EnvironmentName = "environment"
+
 	// ImageName denotes the name of the image flag.
 	ImageName = "image"
 
@@ -151,6 +154,25 @@ func (ss StringSlice) addTo(cmd *cobra.Command) {
 	}
 }
 
+// StringArray wraps the set of string array flags. Unlike the values of
+// StringSlice flags, their values aren't split on commas.
+type StringArray struct {
+	Name        string
+	Shorthand   string
+	Description string
+	Default     []string
+}
+
+func (sa StringArray) addTo(cmd *cobra.Command) {
+	flags := cmd.Flags()
+
+	if sa.Shorthand != "" {
+		_ = flags.StringArrayP(sa.Name, sa.Shorthand, sa.Default, sa.Description)
+	} else {
+		_ = flags.StringArray(sa.Name, sa.Default, sa.Description)
+	}
+}
+
 // Org returns an org string flag.
 func Org() String {
 	return String{
@@ -196,6 +218,14 @@ func AppConfig() String {
 	}
 }
 
+// Environment returns an environment string flag.
+func Environment() String {
+	return String{
+		Name:        EnvironmentName,
+		Description: "Environment whose overlay, such as fly.<environment>.toml, to merge onto the application configuration file. Defaults to $FLY_ENVIRONMENT",
+	}
+}
+
 // Image returns a Docker image config string flag.
 func Image() String {
 	return String{
@@ -290,3 +320,18 @@ func Push() Bool {
 		Default:     false,
 	}
 }
+
+const outputName = "output"
+
+// Output returns a string flag for selecting the format of the output of
+// commands which stream events
+func Output() String {
+	return String{
+		Name:        outputName,
+		Description: "Output format. Use jsonl to stream events as JSON lines to stdout",
+	}
+}
+
+func GetOutput(ctx context.Context) string {
+	return GetString(ctx, outputName)
+}
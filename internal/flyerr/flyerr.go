@@ -11,6 +11,16 @@ import (
 // ErrAbort is an error for when the CLI aborts
 var ErrAbort = errors.New("abort")
 
+// ExitCode is an error which makes the CLI exit with the given code without
+// printing anything, for commands which report their outcome via their exit
+// code, such as the ones CI pipelines run.
+type ExitCode int
+
+// Error implements error for ExitCode.
+func (e ExitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
 // ErrorDescription is an error with a detailed description that will be printed before the CLI exits
 type ErrorDescription interface {
 	error
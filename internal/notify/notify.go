@@ -0,0 +1,307 @@
+// Package notify implements deployment notifications, which are posted to
+// webhooks as deployments start, succeed, fail and get rolled back.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/superfly/flyctl/internal/event"
+)
+
+// Kind denotes the kind of a Message.
+type Kind string
+
+const (
+	// KindStarted denotes messages about deployments which started.
+	KindStarted Kind = "started"
+	// KindSucceeded denotes messages about deployments which succeeded.
+	KindSucceeded Kind = "succeeded"
+	// KindFailed denotes messages about deployments which failed.
+	KindFailed Kind = "failed"
+	// KindRollback denotes messages about rollbacks of failed deployments.
+	KindRollback Kind = "rollback"
+)
+
+// Message is a single notification. It's what webhooks receive, encoded as
+// JSON.
+type Message struct {
+	Kind    Kind      `json:"kind"`
+	Event   string    `json:"event"`
+	Time    time.Time `json:"time"`
+	App     string    `json:"app"`
+	Version int       `json:"version,omitempty"`
+	Image   string    `json:"image,omitempty"`
+	// Revision is the commit the image was built from, for deployments of
+	// git refs.
+	Revision string `json:"revision,omitempty"`
+	Text     string `json:"text"`
+	// Logs maps the instances which failed the deployment to their recent
+	// logs.
+	Logs map[string][]string `json:"logs,omitempty"`
+}
+
+// sink is a destination messages are posted to.
+type sink interface {
+	post(ctx context.Context, client *http.Client, msg *Message) error
+	String() string
+}
+
+// Notifier turns the events of deployments into messages and posts them to
+// its sinks. Messages are posted in the order they occur, in the background.
+type Notifier struct {
+	sinks  []sink
+	client *http.Client
+
+	// image, revision and logs are collected from the events preceding the
+	// ones messages are sent for
+	image    string
+	revision string
+	logs     map[string][]string
+
+	queue chan *Message
+	done  chan struct{}
+
+	mu   sync.Mutex
+	errs error
+}
+
+// timeout bounds the time posting a single message may take.
+const timeout = 10 * time.Second
+
+// New returns a Notifier which posts to the given URLs. Supported are http://
+// and https:// URLs, which receive Messages encoded as JSON, and slack://
+// URLs, such as slack://hooks.slack.com/services/T000/B000/XXXX, which denote
+// Slack incoming webhooks. New returns nil in case no URLs are given.
+func New(urls []string) (*Notifier, error) {
+	var sinks []sink
+
+	seen := map[string]bool{}
+	for _, raw := range urls {
+		if raw = strings.TrimSpace(raw); raw == "" || seen[raw] {
+			continue
+		}
+		seen[raw] = true
+
+		s, err := parseSink(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, s)
+	}
+
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+
+	n := &Notifier{
+		sinks:  sinks,
+		client: &http.Client{Timeout: timeout},
+		logs:   map[string][]string{},
+		queue:  make(chan *Message, 64),
+		done:   make(chan struct{}),
+	}
+
+	go n.run()
+
+	return n, nil
+}
+
+func parseSink(raw string) (sink, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid notification URL %q", raw)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return &webhookSink{url: u.String()}, nil
+	case "slack":
+		u.Scheme = "https"
+
+		return &slackSink{url: u.String()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported notification URL %q: expected an http, https or slack URL", raw)
+	}
+}
+
+// Handle handles ev, queueing a message for it in case it's one which
+// notifications are sent for. It's meant to be subscribed to events.
+func (n *Notifier) Handle(ev event.Event) {
+	msg := &Message{
+		Event:    string(ev.Type),
+		Time:     ev.Time,
+		App:      ev.App,
+		Version:  ev.Version,
+		Image:    n.image,
+		Revision: n.revision,
+	}
+
+	switch ev.Type {
+	case event.ReleaseCreated:
+		n.image, n.revision = ev.Image, ev.Revision
+
+		return
+	case event.AllocationFailed:
+		n.logs[ev.Allocation] = ev.Logs
+
+		return
+	case event.DeploymentStarted:
+		msg.Kind = KindStarted
+		msg.Text = fmt.Sprintf("Deploying %s v%d", ev.App, ev.Version)
+	case event.DeploymentSucceeded:
+		msg.Kind = KindSucceeded
+		msg.Text = fmt.Sprintf("Deployed %s v%d successfully", ev.App, ev.Version)
+	case event.DeploymentFailed:
+		msg.Kind = KindFailed
+		msg.Text = fmt.Sprintf("Deployment of %s v%d failed", ev.App, ev.Version)
+		msg.Logs, n.logs = n.logs, map[string][]string{}
+	case event.ReleaseCommandFailed:
+		msg.Kind = KindFailed
+		msg.Text = fmt.Sprintf("Release command of %s v%d failed", ev.App, ev.Version)
+	case event.RollbackStarted:
+		msg.Kind = KindRollback
+		msg.Text = fmt.Sprintf("Rolling back %s v%d", ev.App, ev.Version)
+	case event.RollbackSucceeded:
+		msg.Kind = KindRollback
+		msg.Text = fmt.Sprintf("Rolled back %s as v%d", ev.App, ev.Version)
+		msg.Image, msg.Revision = ev.Image, ""
+	case event.RollbackFailed:
+		msg.Kind = KindRollback
+		msg.Text = fmt.Sprintf("Rolling back %s failed", ev.App)
+	default:
+		return
+	}
+
+	if ev.Message != "" {
+		msg.Text += ": " + ev.Message
+	}
+
+	n.queue <- msg
+}
+
+func (n *Notifier) run() {
+	defer close(n.done)
+
+	for msg := range n.queue {
+		for _, s := range n.sinks {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			err := s.post(ctx, n.client, msg)
+			cancel()
+
+			if err != nil {
+				n.mu.Lock()
+				n.errs = multierror.Append(n.errs, fmt.Errorf("failed notifying %s: %w", s, err))
+				n.mu.Unlock()
+			}
+		}
+	}
+}
+
+// Close waits for the queued messages to be posted and reports the ones which
+// couldn't be.
+func (n *Notifier) Close() error {
+	close(n.queue)
+	<-n.done
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.errs
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	return nil
+}
+
+// webhookSink posts messages, encoded as JSON, to a URL.
+type webhookSink struct {
+	url string
+}
+
+func (s *webhookSink) post(ctx context.Context, client *http.Client, msg *Message) error {
+	return postJSON(ctx, client, s.url, msg)
+}
+
+func (s *webhookSink) String() string {
+	return redact(s.url)
+}
+
+// slackSink posts messages to a Slack incoming webhook.
+type slackSink struct {
+	url string
+}
+
+func (s *slackSink) post(ctx context.Context, client *http.Client, msg *Message) error {
+	return postJSON(ctx, client, s.url, map[string]string{
+		"text": slackText(msg),
+	})
+}
+
+func (s *slackSink) String() string {
+	return redact(s.url)
+}
+
+func slackText(msg *Message) string {
+	var b strings.Builder
+
+	b.WriteString(msg.Text)
+	if msg.Image != "" {
+		fmt.Fprintf(&b, "\nImage: `%s`", msg.Image)
+	}
+	if msg.Revision != "" {
+		fmt.Fprintf(&b, "\nRevision: `%s`", msg.Revision)
+	}
+
+	for alloc, logs := range msg.Logs {
+		if len(logs) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\nLogs of instance %s:\n```\n%s\n```", alloc, strings.Join(logs, "\n"))
+	}
+
+	return b.String()
+}
+
+// redact strips the path off URLs, since webhook URLs usually embed their
+// credentials in it.
+func redact(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "webhook"
+	}
+
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/superfly/flyctl/internal/event"
+)
+
+// recorder records the bodies of the requests it receives.
+type recorder struct {
+	mu     sync.Mutex
+	bodies []map[string]interface{}
+	status int
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var body map[string]interface{}
+	_ = json.NewDecoder(req.Body).Decode(&body)
+
+	r.mu.Lock()
+	r.bodies = append(r.bodies, body)
+	r.mu.Unlock()
+
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+	}
+}
+
+func emitFailedDeployment(n *Notifier) {
+	at := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, ev := range []event.Event{
+		{Type: event.ReleaseCreated, App: "app", Version: 4, Image: "registry.fly.io/app:deployment-1"},
+		{Type: event.DeploymentStarted, App: "app", Version: 4},
+		{Type: event.AllocationPlaced, App: "app", Version: 4, Allocation: "a1"},
+		{Type: event.AllocationFailed, App: "app", Version: 4, Allocation: "a1", Logs: []string{"panic: boom"}},
+		{Type: event.DeploymentFailed, App: "app", Version: 4, Message: "failed health checks"},
+		{Type: event.RollbackStarted, App: "app", Version: 4},
+		{Type: event.RollbackSucceeded, App: "app", Version: 5, Image: "registry.fly.io/app:deployment-0"},
+	} {
+		ev.Time = at
+		n.Handle(ev)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	rec := new(recorder)
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n, err := New([]string{srv.URL + "/hooks/deploys"})
+	require.NoError(t, err)
+
+	emitFailedDeployment(n)
+	require.NoError(t, n.Close())
+
+	require.Len(t, rec.bodies, 4)
+
+	var kinds []interface{}
+	for _, body := range rec.bodies {
+		kinds = append(kinds, body["kind"])
+	}
+	assert.Equal(t, []interface{}{"started", "failed", "rollback", "rollback"}, kinds)
+
+	failed := rec.bodies[1]
+	assert.Equal(t, "deployment_failed", failed["event"])
+	assert.Equal(t, "app", failed["app"])
+	assert.Equal(t, float64(4), failed["version"])
+	assert.Equal(t, "registry.fly.io/app:deployment-1", failed["image"])
+	assert.Equal(t, "Deployment of app v4 failed: failed health checks", failed["text"])
+	assert.Equal(t, map[string]interface{}{"a1": []interface{}{"panic: boom"}}, failed["logs"])
+
+	rolledBack := rec.bodies[3]
+	assert.Equal(t, "Rolled back app as v5", rolledBack["text"])
+	assert.Equal(t, "registry.fly.io/app:deployment-0", rolledBack["image"])
+}
+
+func TestSlack(t *testing.T) {
+	rec := new(recorder)
+	srv := httptest.NewTLSServer(rec)
+	defer srv.Close()
+
+	n, err := New([]string{strings.Replace(srv.URL, "https://", "slack://", 1) + "/services/T0/B0/X"})
+	require.NoError(t, err)
+	n.client = srv.Client()
+
+	emitFailedDeployment(n)
+	require.NoError(t, n.Close())
+
+	require.Len(t, rec.bodies, 4)
+	assert.Equal(t, "Deployment of app v4 failed: failed health checks\n"+
+		"Image: `registry.fly.io/app:deployment-1`\n"+
+		"Logs of instance a1:\n```\npanic: boom\n```", rec.bodies[1]["text"])
+}
+
+func TestDeliveryFailures(t *testing.T) {
+	rec := &recorder{status: http.StatusInternalServerError}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n, err := New([]string{srv.URL + "/secret-token"})
+	require.NoError(t, err)
+
+	n.Handle(event.Event{Type: event.DeploymentStarted, App: "app", Version: 1})
+
+	err = n.Close()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed notifying "+srv.URL+": unexpected status 500")
+	assert.NotContains(t, err.Error(), "secret-token")
+}
+
+func TestNew(t *testing.T) {
+	n, err := New(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, n)
+
+	_, err = New([]string{"ftp://example.com"})
+	assert.EqualError(t, err, `unsupported notification URL "ftp://example.com": expected an http, https or slack URL`)
+
+	_, err = New([]string{"not a url"})
+	assert.EqualError(t, err, `invalid notification URL "not a url"`)
+}
@@ -2,6 +2,9 @@ package sourcecode
 
 import (
 	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -9,6 +12,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/BurntSushi/toml"
 )
 
 func fileExists(filenames ...string) checkFn {
@@ -147,3 +152,124 @@ func extractBundlerVersion(gemfileLockPath string) (string, error) {
 
 	return version, nil
 }
+
+// extractPHPVersion returns the highest major.minor version of PHP the
+// composer.json at path requires, if any.
+func extractPHPVersion(composerPath string) (string, error) {
+	data, err := os.ReadFile(composerPath)
+	if err != nil {
+		return "", err
+	}
+
+	var composer struct {
+		Require map[string]string `json:"require"`
+	}
+	if err := json.Unmarshal(data, &composer); err != nil {
+		return "", err
+	}
+
+	// constraints list their alternatives in ascending order, as in ^7.3|^8.0
+	versions := regexp.MustCompile(`\d+\.\d+`).FindAllString(composer.Require["php"], -1)
+	if len(versions) == 0 {
+		return "", nil
+	}
+
+	return versions[len(versions)-1], nil
+}
+
+// extractJavaVersion returns the version of Java the Maven or Gradle build
+// file at path targets, if any. Legacy versions, as in 1.8, are normalized to
+// their major version.
+func extractJavaVersion(buildPath string) (string, error) {
+	data, err := os.ReadFile(buildPath)
+	if err != nil {
+		return "", err
+	}
+
+	patterns := []string{
+		`<java\.version>(?:1\.)?(\d+)</java\.version>`,
+		`JavaLanguageVersion\.of\((\d+)\)`,
+		`JavaVersion\.VERSION_(?:1_)?(\d+)`,
+		`sourceCompatibility\s*=\s*['"]?(?:1\.)?(\d+)`,
+	}
+
+	for _, pattern := range patterns {
+		if m := regexp.MustCompile(pattern).FindSubmatch(data); m != nil {
+			return string(m[1]), nil
+		}
+	}
+
+	return "", nil
+}
+
+// extractDotnetVersion returns the version of .NET the project file at path
+// targets, as in 6.0 for net6.0, if any.
+func extractDotnetVersion(projectPath string) (string, error) {
+	data, err := os.ReadFile(projectPath)
+	if err != nil {
+		return "", err
+	}
+
+	re := regexp.MustCompile(`<TargetFrameworks?>(?:net|netcoreapp)(\d+\.\d+)`)
+	if m := re.FindSubmatch(data); m != nil {
+		return string(m[1]), nil
+	}
+
+	return "", nil
+}
+
+// extractCargoBinary returns the name of the binary the Cargo.toml at path
+// builds.
+func extractCargoBinary(cargoPath string) (name string, err error) {
+	var manifest struct {
+		Package struct {
+			Name string `toml:"name"`
+		} `toml:"package"`
+		Bin []struct {
+			Name string `toml:"name"`
+		} `toml:"bin"`
+	}
+
+	if _, err = toml.DecodeFile(cargoPath, &manifest); err != nil {
+		return
+	}
+
+	name = manifest.Package.Name
+	if len(manifest.Bin) > 0 && manifest.Bin[0].Name != "" {
+		name = manifest.Bin[0].Name
+	}
+
+	return
+}
+
+// dotenvValue returns the value the .env file at path assigns to key, if any.
+func dotenvValue(path, key string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, key+"=") {
+			continue
+		}
+
+		return strings.Trim(strings.TrimPrefix(line, key+"="), `"'`)
+	}
+
+	return ""
+}
+
+// laravelKey generates a random key in the format Laravel expects APP_KEY
+// in: the base64 encoding of 32 random bytes.
+func laravelKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return "base64:" + base64.StdEncoding.EncodeToString(key), nil
+}
@@ -0,0 +1,288 @@
+package sourcecode
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Rule is a scanner defined declaratively, in a rules file, rather than in
+// code. Rules files may define any number of rules, as in:
+//
+//	[[scanner]]
+//	family = "Acme"
+//	port = 8080
+//	release_cmd = "acme migrate"
+//	templates = "templates/acme"
+//
+//	  [[scanner.match]]
+//	  glob = "go.mod"
+//	  contains = "acme.dev/framework"
+//
+//	  [scanner.env]
+//	  ACME_ENV = "production"
+//
+//	  [[scanner.secrets]]
+//	  key = "ACME_SECRET"
+//	  help = "Acme needs a random secret"
+//	  generate = true
+//
+// A rule applies to the source directories any of its matches pass for; its
+// templates directory, relative to the rules file, holds the files launch
+// writes to the source directory.
+type Rule struct {
+	Family           string            `toml:"family"`
+	Version          string            `toml:"version"`
+	Match            []RuleMatch       `toml:"match"`
+	Port             int               `toml:"port"`
+	Builder          string            `toml:"builder"`
+	Buildpacks       []string          `toml:"buildpacks"`
+	BuildArgs        map[string]string `toml:"build_args"`
+	ReleaseCmd       string            `toml:"release_cmd"`
+	DockerCommand    string            `toml:"docker_command"`
+	DockerEntrypoint string            `toml:"docker_entrypoint"`
+	KillSignal       string            `toml:"kill_signal"`
+	Env              map[string]string `toml:"env"`
+	Secrets          []struct {
+		Key      string `toml:"key"`
+		Help     string `toml:"help"`
+		Value    string `toml:"value"`
+		Generate bool   `toml:"generate"`
+	} `toml:"secrets"`
+	Statics      []Static          `toml:"statics"`
+	Volumes      []Volume          `toml:"volumes"`
+	Processes    map[string]string `toml:"processes"`
+	InitCommands []struct {
+		Command     string   `toml:"command"`
+		Args        []string `toml:"args"`
+		Description string   `toml:"description"`
+	} `toml:"init_commands"`
+	DockerfileAppendix []string `toml:"dockerfile_appendix"`
+	Templates          string   `toml:"templates"`
+	Notice             string   `toml:"notice"`
+	DeployDocs         string   `toml:"deploy_docs"`
+	SkipDeploy         bool     `toml:"skip_deploy"`
+	SkipDatabase       bool     `toml:"skip_database"`
+
+	// Source is the rules file the rule is defined in.
+	Source string `toml:"-"`
+}
+
+// RuleMatch is a check of a Rule. It passes for the source directories which
+// contain a file matching Glob, which, when Contains is set, contains a line
+// matching the regular expression Contains.
+type RuleMatch struct {
+	Glob     string `toml:"glob"`
+	Contains string `toml:"contains"`
+}
+
+type rulesFile struct {
+	Rules []*Rule `toml:"scanner"`
+}
+
+// LoadRules loads the rules the given sources define. Sources are either
+// rules files or directories of them, of which the TOML files are loaded in
+// lexical order.
+func LoadRules(sources ...string) ([]*Rule, error) {
+	var rules []*Rule
+
+	for _, src := range sources {
+		fi, err := os.Stat(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading scanner rules from %s: %w", src, err)
+		}
+
+		files := []string{src}
+		if fi.IsDir() {
+			if files, err = filepath.Glob(filepath.Join(src, "*.toml")); err != nil {
+				return nil, err
+			}
+			sort.Strings(files)
+		}
+
+		for _, file := range files {
+			loaded, err := loadRulesFile(file)
+			if err != nil {
+				return nil, err
+			}
+
+			rules = append(rules, loaded...)
+		}
+	}
+
+	return rules, nil
+}
+
+func loadRulesFile(path string) ([]*Rule, error) {
+	var f rulesFile
+	if _, err := toml.DecodeFile(path, &f); err != nil {
+		return nil, fmt.Errorf("failed parsing scanner rules of %s: %w", path, err)
+	}
+
+	for i, r := range f.Rules {
+		r.Source = path
+
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("invalid scanner rule #%d of %s: %w", i+1, path, err)
+		}
+	}
+
+	return f.Rules, nil
+}
+
+func (r *Rule) validate() error {
+	switch {
+	case r.Family == "":
+		return fmt.Errorf("the family is missing")
+	case len(r.Match) == 0:
+		return fmt.Errorf("the rule matches nothing")
+	}
+
+	for _, m := range r.Match {
+		if m.Glob == "" {
+			return fmt.Errorf("a match is missing its glob")
+		}
+		if _, err := filepath.Match(m.Glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", m.Glob, err)
+		}
+		if _, err := regexp.Compile(m.Contains); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", m.Contains, err)
+		}
+	}
+
+	if r.Templates != "" {
+		if fi, err := os.Stat(r.templatesDir()); err != nil || !fi.IsDir() {
+			return fmt.Errorf("templates directory %s doesn't exist", r.templatesDir())
+		}
+	}
+
+	return nil
+}
+
+func (r *Rule) templatesDir() string {
+	if filepath.IsAbs(r.Templates) {
+		return r.Templates
+	}
+
+	return filepath.Join(filepath.Dir(r.Source), r.Templates)
+}
+
+// scanner returns the sourceScanner r defines.
+func (r *Rule) scanner() sourceScanner {
+	return func(sourceDir string) (*SourceInfo, error) {
+		checks := make([]checkFn, 0, len(r.Match))
+		for _, m := range r.Match {
+			if m.Contains == "" {
+				checks = append(checks, globExists(m.Glob))
+			} else {
+				checks = append(checks, dirContains(m.Glob, m.Contains))
+			}
+		}
+
+		if !checksPass(sourceDir, checks...) {
+			return nil, nil
+		}
+
+		return r.sourceInfo()
+	}
+}
+
+func (r *Rule) sourceInfo() (*SourceInfo, error) {
+	s := &SourceInfo{
+		Family:             r.Family,
+		Version:            r.Version,
+		Port:               r.Port,
+		Builder:            r.Builder,
+		Buildpacks:         r.Buildpacks,
+		BuildArgs:          r.BuildArgs,
+		ReleaseCmd:         r.ReleaseCmd,
+		DockerCommand:      r.DockerCommand,
+		DockerEntrypoint:   r.DockerEntrypoint,
+		KillSignal:         r.KillSignal,
+		Env:                r.Env,
+		Statics:            r.Statics,
+		Volumes:            r.Volumes,
+		Processes:          r.Processes,
+		DockerfileAppendix: r.DockerfileAppendix,
+		Notice:             r.Notice,
+		DeployDocs:         r.DeployDocs,
+		SkipDeploy:         r.SkipDeploy,
+		SkipDatabase:       r.SkipDatabase,
+	}
+
+	for _, secret := range r.Secrets {
+		s.Secrets = append(s.Secrets, Secret{
+			Key:      secret.Key,
+			Help:     secret.Help,
+			Value:    secret.Value,
+			Generate: secret.Generate,
+		})
+	}
+
+	for _, cmd := range r.InitCommands {
+		s.InitCommands = append(s.InitCommands, InitCommand{
+			Command:     cmd.Command,
+			Args:        cmd.Args,
+			Description: cmd.Description,
+			Condition:   true,
+		})
+	}
+
+	if r.Templates != "" {
+		files, err := templatesFS(os.DirFS(r.templatesDir()), ".")
+		if err != nil {
+			return nil, fmt.Errorf("failed reading templates of scanner rule %s of %s: %w", r.Family, r.Source, err)
+		}
+		s.Files = files
+	}
+
+	return s, nil
+}
+
+// globExists returns a checkFn which passes for the directories containing a
+// file matching glob.
+func globExists(glob string) checkFn {
+	return func(dir string) bool {
+		filenames, _ := filepath.Glob(filepath.Join(dir, glob))
+		for _, filename := range filenames {
+			if info, err := os.Stat(filename); err == nil && !info.IsDir() {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// templatesFS returns the files of the named directory of fsys, with their
+// paths relative to it.
+func templatesFS(fsys fs.FS, name string) (files []SourceFile, err error) {
+	err = fs.WalkDir(fsys, name, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		relPath, err := filepath.Rel(name, path)
+		if err != nil {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, SourceFile{
+			Path:     relPath,
+			Contents: data,
+		})
+
+		return nil
+	})
+
+	return
+}
@@ -0,0 +1,136 @@
+package sourcecode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const acmeRules = `
+[[scanner]]
+family = "Acme"
+port = 8080
+release_cmd = "acme migrate"
+templates = "acme"
+
+  [[scanner.match]]
+  glob = "go.mod"
+  contains = "acme.dev/framework"
+
+  [[scanner.match]]
+  glob = "*.acme"
+
+  [scanner.env]
+  ACME_ENV = "production"
+
+  [[scanner.secrets]]
+  key = "ACME_SECRET"
+  generate = true
+
+  [[scanner.statics]]
+  guest_path = "/app/public"
+  url_prefix = "/"
+`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+}
+
+func TestScanWithRules(t *testing.T) {
+	rulesDir := t.TempDir()
+	writeFiles(t, rulesDir, map[string]string{
+		"acme.toml":             acmeRules,
+		"acme/Dockerfile":       "FROM acme\n",
+		"acme/config/acme.yaml": "env: production\n",
+	})
+
+	rules, err := LoadRules(rulesDir)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+
+	// rules are tried before the scanners flyctl ships with
+	srcDir := t.TempDir()
+	writeFiles(t, srcDir, map[string]string{
+		"go.mod": "module example.com/app\n\nrequire acme.dev/framework v1.0.0\n",
+	})
+
+	si, err := Scan(srcDir, rules...)
+	require.NoError(t, err)
+	require.NotNil(t, si)
+
+	assert.Equal(t, "Acme", si.Family)
+	assert.Equal(t, 8080, si.Port)
+	assert.Equal(t, "acme migrate", si.ReleaseCmd)
+	assert.Equal(t, map[string]string{"ACME_ENV": "production"}, si.Env)
+	assert.Equal(t, []Secret{{Key: "ACME_SECRET", Generate: true}}, si.Secrets)
+	assert.Equal(t, []Static{{GuestPath: "/app/public", UrlPrefix: "/"}}, si.Statics)
+	assert.ElementsMatch(t, []SourceFile{
+		{Path: "Dockerfile", Contents: []byte("FROM acme\n")},
+		{Path: filepath.Join("config", "acme.yaml"), Contents: []byte("env: production\n")},
+	}, si.Files)
+
+	// go modules not using the framework fall through to the Go scanner
+	srcDir = t.TempDir()
+	writeFiles(t, srcDir, map[string]string{
+		"go.mod": "module example.com/app\n",
+	})
+
+	si, err = Scan(srcDir, rules...)
+	require.NoError(t, err)
+	require.NotNil(t, si)
+	assert.Equal(t, "Go", si.Family)
+
+	srcDir = t.TempDir()
+	writeFiles(t, srcDir, map[string]string{
+		"app.acme": "",
+	})
+
+	si, err = Scan(srcDir, rules...)
+	require.NoError(t, err)
+	require.NotNil(t, si)
+	assert.Equal(t, "Acme", si.Family)
+}
+
+func TestLoadRulesInvalid(t *testing.T) {
+	cases := map[string]struct {
+		rules string
+		err   string
+	}{
+		"missing family": {
+			rules: "[[scanner]]\n[[scanner.match]]\nglob = 'acme.yml'\n",
+			err:   "the family is missing",
+		},
+		"no matches": {
+			rules: "[[scanner]]\nfamily = 'Acme'\n",
+			err:   "the rule matches nothing",
+		},
+		"invalid pattern": {
+			rules: "[[scanner]]\nfamily = 'Acme'\n[[scanner.match]]\nglob = 'go.mod'\ncontains = '('\n",
+			err:   `invalid pattern "("`,
+		},
+		"missing templates": {
+			rules: "[[scanner]]\nfamily = 'Acme'\ntemplates = 'missing'\n[[scanner.match]]\nglob = 'acme.yml'\n",
+			err:   "templates directory",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.toml")
+			require.NoError(t, os.WriteFile(path, []byte(c.rules), 0644))
+
+			_, err := LoadRules(path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.err)
+		})
+	}
+}
@@ -69,8 +69,16 @@ type Volume struct {
 	Destination string `toml:"destination" json:"destination"`
 }
 
-func Scan(sourceDir string) (*SourceInfo, error) {
-	scanners := []sourceScanner{
+// Scan detects the runtime or framework of the source code in sourceDir. The
+// given rules are tried first, in order, before the scanners flyctl ships
+// with.
+func Scan(sourceDir string, rules ...*Rule) (*SourceInfo, error) {
+	var scanners []sourceScanner
+	for _, rule := range rules {
+		scanners = append(scanners, rule.scanner())
+	}
+
+	scanners = append(scanners,
 		configureRedwood,
 		configureDjango,
 		/* frameworks scanners are placed before generic scanners,
@@ -83,13 +91,17 @@ func Scan(sourceDir string) (*SourceInfo, error) {
 		configureGo,
 		configurePhoenix,
 		configureElixir,
+		configureLaravel,
+		configureSpringBoot,
+		configureDotnet,
+		configureRust,
 		configurePython,
 		configureDeno,
 		configureRemix,
 		configureNuxt,
 		configureNode,
 		configureStatic,
-	}
+	)
 
 	for _, scanner := range scanners {
 		si, err := scanner(sourceDir)
@@ -601,6 +613,221 @@ For detailed documentation, see https://fly.dev/docs/django/
 	return s, nil
 }
 
+func configureLaravel(sourceDir string) (*SourceInfo, error) {
+	if !checksPass(sourceDir, fileExists("artisan")) || !checksPass(sourceDir, dirContains("composer.json", "laravel/framework")) {
+		return nil, nil
+	}
+
+	s := &SourceInfo{
+		Family: "Laravel",
+		Files:  templates("templates/laravel"),
+		Port:   8080,
+		Env: map[string]string{
+			"APP_ENV":     "production",
+			"LOG_CHANNEL": "stderr",
+		},
+		Statics: []Static{
+			{
+				GuestPath: "/var/www/html/public",
+				UrlPrefix: "/",
+			},
+		},
+	}
+
+	phpVersion, err := extractPHPVersion(filepath.Join(sourceDir, "composer.json"))
+	if err != nil || phpVersion == "" {
+		phpVersion = "8.1"
+	}
+
+	s.BuildArgs = map[string]string{
+		"PHP_VERSION":  phpVersion,
+		"NODE_VERSION": "16",
+	}
+
+	// reuse the key of the app, in case it has one, so that data it encrypted
+	// remains readable
+	appKey := dotenvValue(filepath.Join(sourceDir, ".env"), "APP_KEY")
+	if appKey == "" {
+		if appKey, err = laravelKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.Secrets = []Secret{
+		{
+			Key:   "APP_KEY",
+			Help:  "Laravel needs a random, secret key to encrypt data with.",
+			Value: appKey,
+		},
+	}
+
+	if dotenvValue(filepath.Join(sourceDir, ".env"), "DB_CONNECTION") == "sqlite" {
+		s.Env["DB_CONNECTION"] = "sqlite"
+		s.Env["DB_DATABASE"] = "/data/database.sqlite"
+		s.Env["MIGRATE_ON_BOOT"] = "true"
+		s.Volumes = []Volume{
+			{
+				Source:      "data",
+				Destination: "/data",
+			},
+		}
+		s.SkipDatabase = true
+		s.Notice = "\nThis deployment will run an SQLite on a single dedicated volume. The app can't scale beyond a single instance. Look into 'fly postgres' for a more robust production database that supports scaling up. \n"
+	} else if checksPass(filepath.Join(sourceDir, "database", "migrations"), globExists("*.php")) {
+		s.ReleaseCmd = "php artisan migrate --force"
+	}
+
+	s.DeployDocs = fmt.Sprintf(`
+Your Laravel app is prepared for deployment with PHP %s.
+
+You can configure the version of PHP in the [build] section in the generated fly.toml.
+
+Now: run 'fly deploy --remote-only' to deploy your Laravel app.
+`, phpVersion)
+
+	return s, nil
+}
+
+func configureSpringBoot(sourceDir string) (*SourceInfo, error) {
+	var buildFile string
+	switch {
+	case checksPass(sourceDir, dirContains("pom.xml", "spring-boot")):
+		buildFile = "pom.xml"
+	case checksPass(sourceDir, dirContains("build.gradle", "org.springframework.boot")):
+		buildFile = "build.gradle"
+	case checksPass(sourceDir, dirContains("build.gradle.kts", "org.springframework.boot")):
+		buildFile = "build.gradle.kts"
+	default:
+		return nil, nil
+	}
+
+	s := &SourceInfo{
+		Family:     "Spring Boot",
+		Port:       8080,
+		KillSignal: "SIGTERM",
+		Env: map[string]string{
+			"SERVER_PORT": "8080",
+		},
+	}
+
+	if buildFile == "pom.xml" {
+		s.Files = templates("templates/spring_maven")
+	} else {
+		s.Files = templates("templates/spring_gradle")
+	}
+
+	javaVersion, err := extractJavaVersion(filepath.Join(sourceDir, buildFile))
+	if err != nil || javaVersion == "" {
+		javaVersion = "17"
+	}
+
+	s.BuildArgs = map[string]string{
+		"JAVA_VERSION": javaVersion,
+	}
+
+	s.DeployDocs = fmt.Sprintf(`
+Your Spring Boot app is prepared for deployment with Java %s.
+
+Spring Boot reads JDBC URLs rather than the postgres:// one in DATABASE_URL. In case you
+attach a Postgres database, set SPRING_DATASOURCE_URL, SPRING_DATASOURCE_USERNAME and
+SPRING_DATASOURCE_PASSWORD with 'fly secrets set'.
+
+Now: run 'fly deploy --remote-only' to deploy your Spring Boot app.
+`, javaVersion)
+
+	return s, nil
+}
+
+func configureDotnet(sourceDir string) (*SourceInfo, error) {
+	projects, _ := filepath.Glob(filepath.Join(sourceDir, "*.csproj"))
+	nested, _ := filepath.Glob(filepath.Join(sourceDir, "*", "*.csproj"))
+	projects = append(projects, nested...)
+
+	if len(projects) == 0 {
+		return nil, nil
+	}
+
+	// prefer web projects over the libraries and tests next to them
+	project := projects[0]
+	for _, p := range projects {
+		if fileContains(p, "Microsoft.NET.Sdk.Web") {
+			project = p
+
+			break
+		}
+	}
+
+	s := &SourceInfo{
+		Family: ".NET",
+		Files:  templates("templates/dotnet"),
+		Port:   8080,
+	}
+
+	if fileContains(project, "Microsoft.NET.Sdk.Web") {
+		s.Family = "ASP.NET"
+	}
+
+	dotnetVersion, err := extractDotnetVersion(project)
+	if err != nil || dotnetVersion == "" {
+		dotnetVersion = "6.0"
+	}
+	s.Version = dotnetVersion
+
+	rel, err := filepath.Rel(sourceDir, project)
+	if err != nil {
+		return nil, err
+	}
+
+	s.BuildArgs = map[string]string{
+		"DOTNET_VERSION": dotnetVersion,
+		"PROJECT":        filepath.ToSlash(rel),
+	}
+
+	return s, nil
+}
+
+func configureRust(sourceDir string) (*SourceInfo, error) {
+	if !checksPass(sourceDir, fileExists("Cargo.toml")) {
+		return nil, nil
+	}
+
+	s := &SourceInfo{
+		Family: "Rust",
+		Files:  templates("templates/rust"),
+		Port:   8080,
+		Env: map[string]string{
+			"PORT": "8080",
+		},
+	}
+
+	binName, err := extractCargoBinary(filepath.Join(sourceDir, "Cargo.toml"))
+	if err != nil {
+		return nil, err
+	}
+
+	s.BuildArgs = map[string]string{
+		"RUST_VERSION": "1",
+		"BIN_NAME":     binName,
+	}
+
+	// Rocket binds to localhost by default
+	if checksPass(sourceDir, dirContains("Cargo.toml", `^rocket\b`)) {
+		s.Env["ROCKET_ADDRESS"] = "0.0.0.0"
+		s.Env["ROCKET_PORT"] = "8080"
+	}
+
+	if binName == "" {
+		s.SkipDeploy = true
+		s.DeployDocs = `
+We couldn't tell which binary of your Cargo workspace to deploy. Set BIN_NAME in the
+[build.args] section of the generated fly.toml to its name and run 'fly deploy' to
+deploy your Rust app.
+`
+	}
+
+	return s, nil
+}
+
 // templates recursively returns files from the templates directory within the named directory
 // will panic on errors since these files are embedded and should work
 func templates(name string) (files []SourceFile) {
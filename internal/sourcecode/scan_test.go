@@ -0,0 +1,111 @@
+package sourcecode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scan(t *testing.T, files map[string]string) *SourceInfo {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+
+	si, err := Scan(dir)
+	require.NoError(t, err)
+	require.NotNil(t, si)
+
+	return si
+}
+
+// fileContents returns the contents of the file of si at path.
+func fileContents(si *SourceInfo, path string) string {
+	for _, f := range si.Files {
+		if f.Path == path {
+			return string(f.Contents)
+		}
+	}
+
+	return ""
+}
+
+func TestScanLaravel(t *testing.T) {
+	si := scan(t, map[string]string{
+		"artisan":                              "#!/usr/bin/env php\n",
+		"composer.json":                        `{"require": {"php": "^7.3|^8.0", "laravel/framework": "^9.0"}}`,
+		"package.json":                         "{}",
+		".env":                                 "APP_KEY=base64:c2VjcmV0\nDB_CONNECTION=mysql\n",
+		"database/migrations/create_users.php": "<?php\n",
+	})
+
+	assert.Equal(t, "Laravel", si.Family)
+	assert.Equal(t, 8080, si.Port)
+	assert.Equal(t, "8.0", si.BuildArgs["PHP_VERSION"])
+	assert.Equal(t, []Secret{{Key: "APP_KEY", Help: "Laravel needs a random, secret key to encrypt data with.", Value: "base64:c2VjcmV0"}}, si.Secrets)
+	assert.Equal(t, "php artisan migrate --force", si.ReleaseCmd)
+	assert.Empty(t, si.Volumes)
+	assert.NotEmpty(t, fileContents(si, "Dockerfile"))
+	assert.NotEmpty(t, fileContents(si, ".fly/start.sh"))
+
+	si = scan(t, map[string]string{
+		"artisan":       "#!/usr/bin/env php\n",
+		"composer.json": `{"require": {"laravel/framework": "^9.0"}}`,
+		".env":          "DB_CONNECTION=sqlite\n",
+	})
+
+	assert.Equal(t, "8.1", si.BuildArgs["PHP_VERSION"])
+	assert.True(t, strings.HasPrefix(si.Secrets[0].Value, "base64:"))
+	assert.Empty(t, si.ReleaseCmd)
+	assert.Equal(t, "true", si.Env["MIGRATE_ON_BOOT"])
+	assert.Equal(t, []Volume{{Source: "data", Destination: "/data"}}, si.Volumes)
+}
+
+func TestScanSpringBoot(t *testing.T) {
+	si := scan(t, map[string]string{
+		"pom.xml": "<project>\n<properties>\n<java.version>11</java.version>\n</properties>\n<artifactId>spring-boot-starter-web</artifactId>\n</project>\n",
+	})
+
+	assert.Equal(t, "Spring Boot", si.Family)
+	assert.Equal(t, "11", si.BuildArgs["JAVA_VERSION"])
+	assert.Contains(t, fileContents(si, "Dockerfile"), "mvn -B package")
+
+	si = scan(t, map[string]string{
+		"build.gradle": "plugins {\n  id 'org.springframework.boot' version '2.7.0'\n}\nsourceCompatibility = '1.8'\n",
+	})
+
+	assert.Equal(t, "Spring Boot", si.Family)
+	assert.Equal(t, "8", si.BuildArgs["JAVA_VERSION"])
+	assert.Contains(t, fileContents(si, "Dockerfile"), "gradle bootJar")
+}
+
+func TestScanDotnet(t *testing.T) {
+	si := scan(t, map[string]string{
+		"Lib/Lib.csproj": `<Project Sdk="Microsoft.NET.Sdk"><PropertyGroup><TargetFramework>net6.0</TargetFramework></PropertyGroup></Project>`,
+		"Web/Web.csproj": `<Project Sdk="Microsoft.NET.Sdk.Web"><PropertyGroup><TargetFramework>net7.0</TargetFramework></PropertyGroup></Project>`,
+	})
+
+	assert.Equal(t, "ASP.NET", si.Family)
+	assert.Equal(t, map[string]string{"DOTNET_VERSION": "7.0", "PROJECT": "Web/Web.csproj"}, si.BuildArgs)
+	assert.NotEmpty(t, fileContents(si, "Dockerfile"))
+}
+
+func TestScanRust(t *testing.T) {
+	si := scan(t, map[string]string{
+		"Cargo.toml": "[package]\nname = \"server\"\nrust-version = \"1.65\"\n\n[dependencies]\nrocket = \"0.5\"\n",
+	})
+
+	assert.Equal(t, "Rust", si.Family)
+	assert.Equal(t, map[string]string{"RUST_VERSION": "1", "BIN_NAME": "server"}, si.BuildArgs)
+	assert.Equal(t, "0.0.0.0", si.Env["ROCKET_ADDRESS"])
+	assert.False(t, si.SkipDeploy)
+
+	si = scan(t, map[string]string{
+		"Cargo.toml": "[workspace]\nmembers = [\"api\"]\n",
+	})
+
+	assert.Equal(t, "1", si.BuildArgs["RUST_VERSION"])
+	assert.True(t, si.SkipDeploy)
+}
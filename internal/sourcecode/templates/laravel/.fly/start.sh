@@ -0,0 +1,18 @@
+#!/bin/sh
+set -e
+
+# SQLite databases live on a volume which is mounted at boot, so migrations
+# run here rather than as a release command
+if [ -n "$MIGRATE_ON_BOOT" ]; then
+  mkdir -p "$(dirname "$DB_DATABASE")"
+  touch "$DB_DATABASE"
+  chown -R www-data:www-data "$(dirname "$DB_DATABASE")"
+  php artisan migrate --force
+fi
+
+php artisan config:cache
+php artisan route:cache
+php artisan view:cache
+chown -R www-data:www-data storage bootstrap/cache
+
+exec apache2-foreground
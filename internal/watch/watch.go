@@ -19,6 +19,7 @@ import (
 	"github.com/superfly/flyctl/internal/client"
 	"github.com/superfly/flyctl/internal/config"
 	"github.com/superfly/flyctl/internal/deployment"
+	"github.com/superfly/flyctl/internal/event"
 	"github.com/superfly/flyctl/internal/flyerr"
 	"github.com/superfly/flyctl/internal/format"
 	"github.com/superfly/flyctl/internal/render"
@@ -34,8 +35,11 @@ func Deployment(ctx context.Context, evaluationID string) error {
 	endmessage := ""
 
 	monitor := deployment.NewDeploymentMonitor(client, appName, evaluationID)
+	tracker := event.NewTracker(event.FromContext(ctx), appName)
 
 	monitor.DeploymentStarted = func(idx int, d *api.DeploymentStatus) error {
+		tracker.Deployment(d)
+
 		if idx > 0 {
 			tb.Println()
 		}
@@ -46,6 +50,8 @@ func Deployment(ctx context.Context, evaluationID string) error {
 
 	// TODO check we aren't asking for JSON
 	monitor.DeploymentUpdated = func(d *api.DeploymentStatus, updatedAllocs []*api.AllocationStatus) error {
+		tracker.Allocations(updatedAllocs)
+
 		if io.IsInteractive() {
 			tb.Overwrite()
 
@@ -60,6 +66,9 @@ func Deployment(ctx context.Context, evaluationID string) error {
 	}
 
 	monitor.DeploymentFailed = func(d *api.DeploymentStatus, failedAllocs []*api.AllocationStatus) error {
+		// report the failing instances before the failure of the deployment
+		defer tracker.Deployment(d)
+
 		// cmdCtx.Statusf("deploy", cmdctx.SDETAIL, "v%d %s - %s\n", d.Version, d.Status, d.Description)
 
 		if endmessage == "" && d.Status == "failed" {
@@ -113,6 +122,8 @@ func Deployment(ctx context.Context, evaluationID string) error {
 				}
 
 				renderLogs(ctx, alloc)
+
+				event.Emit(ctx, failedAllocationEvent(appName, alloc))
 			}
 		}
 
@@ -120,6 +131,8 @@ func Deployment(ctx context.Context, evaluationID string) error {
 	}
 
 	monitor.DeploymentSucceeded = func(d *api.DeploymentStatus) error {
+		tracker.Deployment(d)
+
 		tb.Donef("v%d deployed successfully\n", d.Version)
 
 		return nil
@@ -263,6 +276,23 @@ func ReleaseCommand(ctx context.Context, id string) error {
 	return g.Wait()
 }
 
+func failedAllocationEvent(appName string, alloc *api.AllocationStatus) event.Event {
+	ev := event.Event{
+		Type:       event.AllocationFailed,
+		App:        appName,
+		Version:    alloc.Version,
+		Allocation: alloc.IDShort,
+		Region:     alloc.Region,
+		Status:     alloc.Status,
+	}
+
+	for _, e := range alloc.RecentLogs {
+		ev.Logs = append(ev.Logs, e.Message)
+	}
+
+	return ev
+}
+
 func renderLogs(ctx context.Context, alloc *api.AllocationStatus) {
 	out := iostreams.FromContext(ctx).Out
 	cfg := config.FromContext(ctx)